@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2, 0)
+
+	c.Add("one", 1)
+	c.Add("two", 2)
+	if _, ok := c.Get("one"); !ok {
+		t.Fatal("failed to get one")
+	}
+	c.Add("three", 3)
+
+	if _, ok := c.Get("two"); ok {
+		t.Fatal("two was not evicted")
+	}
+	for _, k := range []string{"one", "three"} {
+		if _, ok := c.Get(k); !ok {
+			t.Fatalf("%s was evicted", k)
+		}
+	}
+	if l := c.Len(); l != 2 {
+		t.Fatalf("got %d entries, want 2", l)
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	now := time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)
+	c := New[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("one", 1)
+	if v, ok := c.Get("one"); !ok || v != 1 {
+		t.Fatalf("got %v, %v, want 1, true", v, ok)
+	}
+
+	now = now.Add(time.Minute * 2)
+	if _, ok := c.Get("one"); ok {
+		t.Fatal("entry did not expire")
+	}
+	if l := c.Len(); l != 0 {
+		t.Fatalf("got %d entries, want 0", l)
+	}
+}
+
+func TestLRURemoveFunc(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Add("repo1#main", 1)
+	c.Add("repo1#dev", 2)
+	c.Add("repo2#main", 3)
+
+	c.RemoveFunc(func(k string) bool {
+		return strings.HasPrefix(k, "repo1#")
+	})
+
+	if l := c.Len(); l != 1 {
+		t.Fatalf("got %d entries, want 1", l)
+	}
+	if _, ok := c.Get("repo2#main"); !ok {
+		t.Fatal("repo2#main was removed")
+	}
+}
+
+func TestLRUValues(t *testing.T) {
+	now := time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)
+	c := New[string, int](10, time.Minute)
+	c.now = func() time.Time { return now }
+	c.Add("one", 1)
+	now = now.Add(time.Minute * 2)
+	c.Add("two", 2)
+	c.Add("three", 3)
+
+	got := c.Values()
+
+	if len(got) != 2 || got[0] != 3 || got[1] != 2 {
+		t.Fatalf("got %v, want [3 2]", got)
+	}
+}
+
+func TestLRUKeys(t *testing.T) {
+	c := New[string, int](10, 0)
+	c.Add("one", 1)
+	c.Add("two", 2)
+	c.Add("three", 3)
+	c.Get("one")
+
+	got := c.Keys()
+
+	if len(got) != 3 || got[0] != "one" || got[1] != "three" || got[2] != "two" {
+		t.Fatalf("got %v, want [one three two]", got)
+	}
+}
@@ -0,0 +1,250 @@
+// Package v2 contains the response types for version 2 of the HTTP API,
+// served under /api/v2.
+//
+// These types are the contract with clients of the API, fields can be added,
+// but not removed or renamed without introducing a new version.
+package v2
+
+import (
+	"time"
+)
+
+// ApplicationList is the response for listing the applications in a GitOps
+// repository.
+//
+// If there are more applications, Continue is the token to request the next
+// page with.
+type ApplicationList struct {
+	Applications []Application `json:"applications"`
+	Continue     string        `json:"continue,omitempty"`
+}
+
+// Application is an application and the environments that it is deployed to.
+type Application struct {
+	Name         string                   `json:"name"`
+	RepoURL      string                   `json:"repoURL"`
+	Environments []ApplicationEnvironment `json:"environments"`
+}
+
+// ApplicationEnvironment is the state of an application in a specific
+// environment.
+//
+// The SyncStatus and LastDeployed fields are only populated when the state
+// comes from Argo CD.
+type ApplicationEnvironment struct {
+	Name         string     `json:"name"`
+	SyncStatus   string     `json:"syncStatus,omitempty"`
+	LastDeployed *time.Time `json:"lastDeployed,omitempty"`
+}
+
+// EnvironmentApplication is an application rendered from the GitOps
+// repository for a specific environment.
+//
+// Commit is the SHA of the commit in the GitOps repository that it was
+// rendered from.
+type EnvironmentApplication struct {
+	Environment string    `json:"environment"`
+	Cluster     string    `json:"cluster"`
+	Commit      string    `json:"commit"`
+	Services    []Service `json:"services"`
+}
+
+// Service is a service that is part of an application, with the resources
+// and images that make it up.
+type Service struct {
+	Name      string     `json:"name"`
+	Source    *Source    `json:"source,omitempty"`
+	Images    []string   `json:"images,omitempty"`
+	Resources []Resource `json:"resources,omitempty"`
+}
+
+// Source is the repository that a service is built from.
+type Source struct {
+	URL  string `json:"url"`
+	Type string `json:"type"`
+}
+
+// Resource identifies a Kubernetes resource, with the images of its
+// containers.
+type Resource struct {
+	Group     string  `json:"group"`
+	Version   string  `json:"version"`
+	Kind      string  `json:"kind"`
+	Name      string  `json:"name"`
+	Namespace string  `json:"namespace"`
+	Images    []Image `json:"images,omitempty"`
+}
+
+// Image is an image in a resource, normalized as Docker does, e.g.
+// redis:6-alpine has the registry docker.io and the repository
+// library/redis.
+//
+// Reference is the image as it is in the resource, if it can't be parsed,
+// the other fields are empty. ContainerType is "init" for init containers,
+// "regular" for other containers, and empty if the container isn't known.
+type Image struct {
+	Reference     string `json:"reference"`
+	Registry      string `json:"registry,omitempty"`
+	Repository    string `json:"repository,omitempty"`
+	Tag           string `json:"tag,omitempty"`
+	Digest        string `json:"digest,omitempty"`
+	Container     string `json:"container,omitempty"`
+	ContainerType string `json:"containerType,omitempty"`
+}
+
+// EnvironmentComparison is the difference between an application rendered
+// from the GitOps repository for two environments.
+//
+// Only the services and resources that are different are included, Identical
+// is true if there are no differences.
+type EnvironmentComparison struct {
+	Application string               `json:"application"`
+	From        string               `json:"from"`
+	To          string               `json:"to"`
+	Commit      string               `json:"commit"`
+	Identical   bool                 `json:"identical"`
+	Services    []ServiceDifference  `json:"services"`
+	Resources   []ResourceDifference `json:"resources"`
+}
+
+// ServiceDifference is a service that has different images in the two
+// environments, the images are empty if the service isn't in an environment.
+type ServiceDifference struct {
+	Name       string   `json:"name"`
+	FromImages []string `json:"fromImages"`
+	ToImages   []string `json:"toImages"`
+}
+
+// ResourceDifference is a resource that is only in one of the environments,
+// or that has a different namespace or labels.
+//
+// Labels only has the labels with different values, a value is empty if the
+// label isn't set in the environment.
+type ResourceDifference struct {
+	Group     string                     `json:"group"`
+	Kind      string                     `json:"kind"`
+	Name      string                     `json:"name"`
+	InFrom    bool                       `json:"inFrom"`
+	InTo      bool                       `json:"inTo"`
+	Namespace *ValueDifference           `json:"namespace,omitempty"`
+	Labels    map[string]ValueDifference `json:"labels,omitempty"`
+}
+
+// ValueDifference is the values of a field in the two environments.
+type ValueDifference struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ApplicationDetails is the state of an Argo CD Application deployed to an
+// environment.
+//
+// Resources are grouped by kind, e.g. "services" or "deployments".
+type ApplicationDetails struct {
+	Environment  string                      `json:"environment"`
+	Cluster      string                      `json:"cluster"`
+	LastDeployed *time.Time                  `json:"lastDeployed,omitempty"`
+	Status       string                      `json:"status"`
+	Revision     RevisionMeta                `json:"revision"`
+	Resources    map[string][]ResourceHealth `json:"resources"`
+}
+
+// RevisionMeta describes the commit that an application was deployed from.
+type RevisionMeta struct {
+	Author   string `json:"author"`
+	Message  string `json:"message"`
+	Revision string `json:"revision"`
+}
+
+// ResourceHealth is the sync and health status of a resource managed by
+// Argo CD.
+//
+// Health is not populated for resources where health is not meaningful.
+type ResourceHealth struct {
+	Name   string `json:"name"`
+	Health string `json:"health,omitempty"`
+	Status string `json:"status,omitempty"`
+}
+
+// HistoryEntry is a deployment of an application to an environment.
+type HistoryEntry struct {
+	Author      string     `json:"author,omitempty"`
+	Message     string     `json:"message,omitempty"`
+	Revision    string     `json:"revision"`
+	Environment string     `json:"environment"`
+	RepoURL     string     `json:"repoURL"`
+	DeployedAt  *time.Time `json:"deployedAt,omitempty"`
+}
+
+// The types of ApplicationEvent.
+const (
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// ApplicationEvent is a change to an Argo CD Application, sent by the
+// streaming route.
+//
+// Only the fields that changed since the previous event for the same
+// application are populated, Resources contains the complete list of
+// resources for each group that changed, and History contains the new
+// deployments, most recent first.
+type ApplicationEvent struct {
+	Type        string                      `json:"type"`
+	Application string                      `json:"application"`
+	Environment string                      `json:"environment"`
+	RepoURL     string                      `json:"repoURL"`
+	SyncStatus  string                      `json:"syncStatus,omitempty"`
+	Resources   map[string][]ResourceHealth `json:"resources,omitempty"`
+	History     []HistoryEntry              `json:"history,omitempty"`
+}
+
+// Promotion is a pull request that promotes an application from one
+// environment to another.
+type Promotion struct {
+	Application string         `json:"application"`
+	From        string         `json:"from"`
+	To          string         `json:"to"`
+	Branch      string         `json:"branch"`
+	PullRequest PullRequest    `json:"pullRequest"`
+	Files       []PromotedFile `json:"files"`
+}
+
+// PullRequest is a pull request in the GitOps repository.
+type PullRequest struct {
+	Number int    `json:"number"`
+	URL    string `json:"url"`
+}
+
+// PromotedFile is a file in the target environment that is changed by a
+// promotion, with the images that are changed in it.
+type PromotedFile struct {
+	Path   string        `json:"path"`
+	Images []ImageChange `json:"images"`
+}
+
+// ImageChange is a change to an image in a kustomization.yaml, From is empty
+// if the image was added, and To is empty if it was removed.
+type ImageChange struct {
+	Name string `json:"name"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Commit is a commit in the GitOps repository that changed an application in
+// an environment, with the files within the application that it changed.
+type Commit struct {
+	SHA         string        `json:"sha"`
+	Author      string        `json:"author"`
+	AuthorEmail string        `json:"authorEmail,omitempty"`
+	Date        time.Time     `json:"date"`
+	Message     string        `json:"message"`
+	Files       []string      `json:"files"`
+	Images      []ImageChange `json:"images"`
+	// FilesTruncated is true if the commit changed more files than the Git
+	// host listed, only the images in the listed files are compared.
+	FilesTruncated bool `json:"filesTruncated,omitempty"`
+	// Baseline is true if there's no commit before the commit, the images
+	// that it set are reported as added.
+	Baseline bool `json:"baseline,omitempty"`
+}
@@ -0,0 +1,199 @@
+package argocd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ Client = (*APIClient)(nil)
+
+// APIClient is an implementation of the Client interface that makes requests
+// to the Argo CD API server.
+//
+// A session is created when the first request is made, and the session token
+// is reused until the API server rejects it.
+type APIClient struct {
+	baseURL     string
+	client      *http.Client
+	credentials CredentialsFunc
+
+	mu    sync.Mutex
+	token string
+}
+
+// New creates and returns an APIClient that makes requests to the Argo CD API
+// server at baseURL, using the credentials to create sessions.
+func New(baseURL string, c *http.Client, creds CredentialsFunc) *APIClient {
+	return &APIClient{baseURL: baseURL, client: c, credentials: creds}
+}
+
+// NewHTTPClient creates and returns an HTTP client that verifies the Argo CD
+// API server's certificate with the PEM encoded certificates in caBundle.
+//
+// If caBundle is empty, the certificate is not verified.
+func NewHTTPClient(caBundle []byte) (*http.Client, error) {
+	if len(caBundle) == 0 {
+		log.Println("WARNING: no CA bundle provided, the Argo CD server certificate will not be verified")
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}, nil
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caBundle) {
+		return nil, errors.New("failed to parse any certificates from the CA bundle")
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}, nil
+}
+
+// Error is returned when the Argo CD API server responds with an unsuccessful
+// status.
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("argocd API error %d: %s", e.Status, e.Message)
+}
+
+// RevisionMetadata implements the Client interface.
+func (c *APIClient) RevisionMetadata(ctx context.Context, app types.NamespacedName, revision string) (*argoV1aplha1.RevisionMetadata, error) {
+	metadata := &argoV1aplha1.RevisionMetadata{}
+	err := c.get(ctx, applicationPath(app, "revisions/"+url.PathEscape(revision)+"/metadata"), metadata)
+	if err != nil {
+		return nil, err
+	}
+	return metadata, nil
+}
+
+// ManagedResources implements the Client interface.
+func (c *APIClient) ManagedResources(ctx context.Context, app types.NamespacedName) ([]*argoV1aplha1.ResourceDiff, error) {
+	resources := struct {
+		Items []*argoV1aplha1.ResourceDiff `json:"items"`
+	}{}
+	if err := c.get(ctx, applicationPath(app, "managed-resources"), &resources); err != nil {
+		return nil, err
+	}
+	return resources.Items, nil
+}
+
+// ResourceTree implements the Client interface.
+func (c *APIClient) ResourceTree(ctx context.Context, app types.NamespacedName) (*argoV1aplha1.ApplicationTree, error) {
+	tree := &argoV1aplha1.ApplicationTree{}
+	if err := c.get(ctx, applicationPath(app, "resource-tree"), tree); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// applicationPath returns the path to a resource of an Application.
+//
+// Argo CD only finds Applications outside of its own namespace if the
+// appNamespace parameter is set.
+func applicationPath(app types.NamespacedName, resource string) string {
+	path := fmt.Sprintf("/api/v1/applications/%s/%s", url.PathEscape(app.Name), resource)
+	if app.Namespace == "" {
+		return path
+	}
+	return path + "?" + url.Values{"appNamespace": []string{app.Namespace}}.Encode()
+}
+
+// Session creates a new session with the API server, and returns the session
+// token.
+func (c *APIClient) Session(ctx context.Context) (string, error) {
+	username, password, err := c.credentials(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the Argo CD credentials: %w", err)
+	}
+	body, err := json.Marshal(map[string]string{"username": username, "password": password})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/api/v1/session", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	session := struct {
+		Token string `json:"token"`
+	}{}
+	if err := c.do(req, &session); err != nil {
+		return "", err
+	}
+	if session.Token == "" {
+		return "", errors.New("failed to retrieve JWT from the api-server")
+	}
+	return session.Token, nil
+}
+
+// get makes a request to the API server with the session token, creating a
+// new session if there is no token, or the token has expired.
+func (c *APIClient) get(ctx context.Context, path string, v interface{}) error {
+	token, err := c.sessionToken(ctx, "")
+	if err != nil {
+		return err
+	}
+	err = c.getWithToken(ctx, path, token, v)
+	var apiErr Error
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized {
+		token, err = c.sessionToken(ctx, token)
+		if err != nil {
+			return err
+		}
+		return c.getWithToken(ctx, path, token, v)
+	}
+	return err
+}
+
+func (c *APIClient) getWithToken(ctx context.Context, path, token string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return c.do(req, v)
+}
+
+// sessionToken returns the current session token, creating a new session if
+// there is no token, or the current token is the rejected token.
+func (c *APIClient) sessionToken(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && c.token != rejected {
+		return c.token, nil
+	}
+	token, err := c.Session(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.token = token
+	return token, nil
+}
+
+func (c *APIClient) do(req *http.Request, v interface{}) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return Error{Status: resp.StatusCode, Message: strings.TrimSpace(string(body))}
+	}
+	return json.Unmarshal(body, v)
+}
@@ -0,0 +1,208 @@
+package argocd
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRevisionMetadata(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), stubCredentials)
+
+	metadata, err := client.RevisionMetadata(context.TODO(), testApp, "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &argoV1aplha1.RevisionMetadata{Author: "test", Message: "testMessage"}
+	if diff := cmp.Diff(want, metadata); diff != "" {
+		t.Fatalf("incorrect metadata:\n%s", diff)
+	}
+}
+
+func TestManagedResources(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), stubCredentials)
+
+	resources, err := client.ManagedResources(context.TODO(), testApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*argoV1aplha1.ResourceDiff{{Kind: "Deployment", Name: "taxi", Namespace: "dev"}}
+	if diff := cmp.Diff(want, resources); diff != "" {
+		t.Fatalf("incorrect resources:\n%s", diff)
+	}
+}
+
+func TestResourceTree(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), stubCredentials)
+
+	tree, err := client.ResourceTree(context.TODO(), testApp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if l := len(tree.Nodes); l != 1 {
+		t.Fatalf("got %d nodes, want 1", l)
+	}
+	if n := tree.Nodes[0].Name; n != "taxi" {
+		t.Fatalf("got node %q, want taxi", n)
+	}
+}
+
+func TestClientReusesSession(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), stubCredentials)
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.RevisionMetadata(context.TODO(), testApp, "123456789"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if as.sessions != 1 {
+		t.Fatalf("got %d sessions, want 1", as.sessions)
+	}
+}
+
+func TestClientRefreshesExpiredSession(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), stubCredentials)
+	if _, err := client.RevisionMetadata(context.TODO(), testApp, "123456789"); err != nil {
+		t.Fatal(err)
+	}
+
+	as.validToken = "new-token"
+	if _, err := client.RevisionMetadata(context.TODO(), testApp, "123456789"); err != nil {
+		t.Fatal(err)
+	}
+
+	if as.sessions != 2 {
+		t.Fatalf("got %d sessions, want 2", as.sessions)
+	}
+}
+
+func TestClientWithBadCredentials(t *testing.T) {
+	as := newFakeArgoCD(t)
+	client := New(as.server.URL, as.server.Client(), func(ctx context.Context) (string, string, error) {
+		return "admin", "wrong", nil
+	})
+
+	_, err := client.RevisionMetadata(context.TODO(), testApp, "123456789")
+
+	want := Error{Status: http.StatusUnauthorized, Message: "invalid username or password"}
+	if diff := cmp.Diff(want, err); diff != "" {
+		t.Fatalf("incorrect error:\n%s", diff)
+	}
+}
+
+func TestNewHTTPClient(t *testing.T) {
+	as := newFakeArgoCD(t)
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: as.server.Certificate().Raw})
+	httpClient, err := NewHTTPClient(caBundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(as.server.URL, httpClient, stubCredentials)
+
+	if _, err := client.RevisionMetadata(context.TODO(), testApp, "123456789"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewHTTPClientWithInvalidBundle(t *testing.T) {
+	_, err := NewHTTPClient([]byte("not a certificate"))
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestSecretCredentials(t *testing.T) {
+	kc := fake.NewClientBuilder().WithObjects(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "openshift-gitops-cluster", Namespace: "openshift-gitops"},
+		Data:       map[string][]byte{"admin.password": []byte("abc")},
+	}).Build()
+
+	username, password, err := SecretCredentials(kc, DefaultInstance.AdminSecret())(context.TODO())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if username != "admin" || password != "abc" {
+		t.Fatalf("got %q/%q, want admin/abc", username, password)
+	}
+}
+
+var testApp = types.NamespacedName{Name: "test-app", Namespace: "test-namespace"}
+
+func stubCredentials(ctx context.Context) (string, string, error) {
+	return "admin", "abc", nil
+}
+
+// fakeArgoCD is a fake Argo CD API server that issues validToken for the
+// admin user.
+type fakeArgoCD struct {
+	server     *httptest.Server
+	validToken string
+	sessions   int
+}
+
+func newFakeArgoCD(t *testing.T) *fakeArgoCD {
+	f := &fakeArgoCD{validToken: "testing"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/session", func(w http.ResponseWriter, r *http.Request) {
+		creds := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
+			t.Fatal(err)
+		}
+		if creds["username"] != "admin" || creds["password"] != "abc" {
+			http.Error(w, "invalid username or password", http.StatusUnauthorized)
+			return
+		}
+		f.sessions++
+		writeJSON(w, map[string]string{"token": f.validToken})
+	})
+	mux.HandleFunc("GET /api/v1/applications/test-app/revisions/123456789/metadata", f.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]string{"author": "test", "message": "testMessage"})
+	}))
+	mux.HandleFunc("GET /api/v1/applications/test-app/managed-resources", f.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"items": []map[string]string{{"kind": "Deployment", "name": "taxi", "namespace": "dev"}}})
+	}))
+	mux.HandleFunc("GET /api/v1/applications/test-app/resource-tree", f.authenticated(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]interface{}{"nodes": []map[string]string{{"kind": "Deployment", "name": "taxi", "namespace": "dev"}}})
+	}))
+	f.server = httptest.NewTLSServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+func (f *fakeArgoCD) authenticated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ns := r.URL.Query().Get("appNamespace"); ns != testApp.Namespace {
+			http.Error(w, "application not found in namespace "+ns, http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+f.validToken {
+			http.Error(w, "invalid session", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,74 @@
+package argocd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// DefaultInstance is the Argo CD instance installed by the OpenShift GitOps
+// operator.
+var DefaultInstance = Instance{Name: "openshift-gitops", Namespace: "openshift-gitops"}
+
+// Instance identifies an Argo CD instance managed by the OpenShift GitOps
+// operator.
+type Instance struct {
+	Name      string
+	Namespace string
+	// Server is the URL of the API server, if it isn't the instance's
+	// Service.
+	Server string
+}
+
+// ParseInstance parses an instance in the form "namespace/name", optionally
+// followed by "=<server URL>".
+func ParseInstance(s string) (Instance, error) {
+	ref, server, _ := strings.Cut(s, "=")
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return Instance{}, fmt.Errorf("invalid Argo CD instance %q, must be namespace/name", s)
+	}
+	if server != "" {
+		u, err := url.Parse(server)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return Instance{}, fmt.Errorf("invalid Argo CD server URL %q", server)
+		}
+	}
+	return Instance{Name: name, Namespace: namespace, Server: server}, nil
+}
+
+// String returns the instance in the form "namespace/name".
+func (i Instance) String() string {
+	return i.Namespace + "/" + i.Name
+}
+
+// URL returns the URL of the instance's API server.
+func (i Instance) URL() string {
+	if i.Server != "" {
+		return i.Server
+	}
+	return fmt.Sprintf("https://%s-server.%s.svc.cluster.local", i.Name, i.Namespace)
+}
+
+// AdminSecret returns the name of the Secret with the instance's admin
+// password.
+func (i Instance) AdminSecret() types.NamespacedName {
+	return types.NamespacedName{Name: i.Name + "-cluster", Namespace: i.Namespace}
+}
+
+// SecretCredentials returns a CredentialsFunc that logs in as the admin user,
+// with the password from the "admin.password" key in the Secret.
+func SecretCredentials(kc ctrlclient.Client, secret types.NamespacedName) CredentialsFunc {
+	return func(ctx context.Context) (string, string, error) {
+		s := &corev1.Secret{}
+		if err := kc.Get(ctx, secret, s); err != nil {
+			return "", "", err
+		}
+		return "admin", string(s.Data["admin.password"]), nil
+	}
+}
@@ -0,0 +1,98 @@
+package argocd
+
+import (
+	"context"
+	"errors"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ Client = (*Instances)(nil)
+
+var errNoInstances = errors.New("no Argo CD instances are configured")
+
+// Instances is an implementation of the Client interface that sends requests
+// for each Application to the Argo CD instance that manages it.
+//
+// Namespace-scoped instances manage the Applications in their own namespace,
+// Applications in any other namespace are managed by the default instance,
+// which is the first instance that was added.
+type Instances struct {
+	instances []Instance
+	clients   map[string]Client
+}
+
+// NewInstances creates and returns an empty set of instances.
+func NewInstances() *Instances {
+	return &Instances{clients: map[string]Client{}}
+}
+
+// Add adds an instance with the client for its API server, an instance that
+// has already been added is replaced.
+func (s *Instances) Add(i Instance, c Client) {
+	if _, ok := s.clients[i.String()]; !ok {
+		s.instances = append(s.instances, i)
+	}
+	s.clients[i.String()] = c
+}
+
+// Lookup returns the instance with the namespace and name, and false if it
+// hasn't been added.
+func (s *Instances) Lookup(namespace, name string) (Instance, bool) {
+	for _, i := range s.instances {
+		if i.Namespace == namespace && i.Name == name {
+			return i, true
+		}
+	}
+	return Instance{}, false
+}
+
+// ForApplication returns the instance that manages the Application, and false
+// if there are no instances.
+func (s *Instances) ForApplication(app types.NamespacedName) (Instance, bool) {
+	if len(s.instances) == 0 {
+		return Instance{}, false
+	}
+	for _, i := range s.instances {
+		if i.Namespace == app.Namespace {
+			return i, true
+		}
+	}
+	return s.instances[0], true
+}
+
+// RevisionMetadata implements the Client interface.
+func (s *Instances) RevisionMetadata(ctx context.Context, app types.NamespacedName, revision string) (*argoV1aplha1.RevisionMetadata, error) {
+	c, err := s.client(app)
+	if err != nil {
+		return nil, err
+	}
+	return c.RevisionMetadata(ctx, app, revision)
+}
+
+// ManagedResources implements the Client interface.
+func (s *Instances) ManagedResources(ctx context.Context, app types.NamespacedName) ([]*argoV1aplha1.ResourceDiff, error) {
+	c, err := s.client(app)
+	if err != nil {
+		return nil, err
+	}
+	return c.ManagedResources(ctx, app)
+}
+
+// ResourceTree implements the Client interface.
+func (s *Instances) ResourceTree(ctx context.Context, app types.NamespacedName) (*argoV1aplha1.ApplicationTree, error) {
+	c, err := s.client(app)
+	if err != nil {
+		return nil, err
+	}
+	return c.ResourceTree(ctx, app)
+}
+
+func (s *Instances) client(app types.NamespacedName) (Client, error) {
+	i, ok := s.ForApplication(app)
+	if !ok {
+		return nil, errNoInstances
+	}
+	return s.clients[i.String()], nil
+}
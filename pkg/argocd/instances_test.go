@@ -0,0 +1,107 @@
+package argocd
+
+import (
+	"context"
+	"testing"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseInstance(t *testing.T) {
+	parseTests := []struct {
+		s       string
+		want    Instance
+		wantErr string
+	}{
+		{"openshift-gitops/openshift-gitops", DefaultInstance, ""},
+		{"team-a/argocd", Instance{Name: "argocd", Namespace: "team-a"}, ""},
+		{"team-a/argocd=https://argocd.example.com", Instance{Name: "argocd", Namespace: "team-a", Server: "https://argocd.example.com"}, ""},
+		{"argocd", Instance{}, `invalid Argo CD instance "argocd", must be namespace/name`},
+		{"team-a/", Instance{}, `invalid Argo CD instance "team-a/", must be namespace/name`},
+		{"a/b/c", Instance{}, `invalid Argo CD instance "a/b/c", must be namespace/name`},
+		{"team-a/argocd=argocd", Instance{}, `invalid Argo CD server URL "argocd"`},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, err := ParseInstance(tt.s)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("incorrect instance:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestInstanceURL(t *testing.T) {
+	if u := DefaultInstance.URL(); u != "https://openshift-gitops-server.openshift-gitops.svc.cluster.local" {
+		t.Fatalf("got %q", u)
+	}
+	i := Instance{Name: "argocd", Namespace: "team-a", Server: "https://argocd.example.com"}
+	if u := i.URL(); u != "https://argocd.example.com" {
+		t.Fatalf("got %q", u)
+	}
+}
+
+func TestInstancesForApplication(t *testing.T) {
+	teamA := Instance{Name: "argocd", Namespace: "team-a"}
+	instances := NewInstances()
+	instances.Add(DefaultInstance, NewMock())
+	instances.Add(teamA, NewMock())
+
+	appTests := []struct {
+		app  types.NamespacedName
+		want Instance
+	}{
+		{types.NamespacedName{Name: "dev-taxi", Namespace: "team-a"}, teamA},
+		{types.NamespacedName{Name: "dev-taxi", Namespace: "openshift-gitops"}, DefaultInstance},
+		{types.NamespacedName{Name: "dev-taxi", Namespace: "team-b"}, DefaultInstance},
+	}
+
+	for _, tt := range appTests {
+		got, ok := instances.ForApplication(tt.app)
+		if !ok || got != tt.want {
+			t.Errorf("ForApplication(%s) got %v, want %v", tt.app, got, tt.want)
+		}
+	}
+}
+
+func TestInstancesSendsRequestsToManagingInstance(t *testing.T) {
+	teamA := Instance{Name: "argocd", Namespace: "team-a"}
+	defaultClient, teamAClient := NewMock(), NewMock()
+	instances := NewInstances()
+	instances.Add(DefaultInstance, defaultClient)
+	instances.Add(teamA, teamAClient)
+	app := types.NamespacedName{Name: "dev-taxi", Namespace: "team-a"}
+	teamAClient.AddRevisionMetadata(app, "123456789", &argoV1aplha1.RevisionMetadata{Author: "test"})
+
+	metadata, err := instances.RevisionMetadata(context.TODO(), app, "123456789")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Author != "test" {
+		t.Fatalf("got author %q, want test", metadata.Author)
+	}
+
+	_, err = instances.RevisionMetadata(context.TODO(), types.NamespacedName{Name: "dev-taxi", Namespace: "team-b"}, "123456789")
+	if e, ok := err.(Error); !ok || e.Status != 404 {
+		t.Fatalf("got error %v, want not found from the default instance", err)
+	}
+}
+
+func TestInstancesWithNoInstances(t *testing.T) {
+	_, err := NewInstances().ResourceTree(context.TODO(), testApp)
+	if err != errNoInstances {
+		t.Fatalf("got error %v, want %v", err, errNoInstances)
+	}
+}
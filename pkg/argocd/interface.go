@@ -0,0 +1,30 @@
+package argocd
+
+import (
+	"context"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Client is an interface for the Argo CD API.
+//
+// Applications are identified by their name and namespace, Applications
+// outside of the Argo CD namespace are only accessible if the instance is
+// configured with the namespace as a source namespace.
+type Client interface {
+	// RevisionMetadata returns the author and message of a revision of an
+	// application's source.
+	RevisionMetadata(ctx context.Context, app types.NamespacedName, revision string) (*argoV1aplha1.RevisionMetadata, error)
+
+	// ManagedResources returns the live and target state of the resources
+	// managed by an application.
+	ManagedResources(ctx context.Context, app types.NamespacedName) ([]*argoV1aplha1.ResourceDiff, error)
+
+	// ResourceTree returns the resources managed by an application, and the
+	// resources that they own.
+	ResourceTree(ctx context.Context, app types.NamespacedName) (*argoV1aplha1.ApplicationTree, error)
+}
+
+// CredentialsFunc returns the username and password to log in to Argo CD.
+type CredentialsFunc func(ctx context.Context) (username, password string, err error)
@@ -0,0 +1,78 @@
+package argocd
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ Client = (*MockClient)(nil)
+
+// MockClient implements the Client interface for test purposes, it returns
+// the responses that have been added, and a not found Error for anything else.
+type MockClient struct {
+	metadata  map[string]*argoV1aplha1.RevisionMetadata
+	resources map[types.NamespacedName][]*argoV1aplha1.ResourceDiff
+	trees     map[types.NamespacedName]*argoV1aplha1.ApplicationTree
+}
+
+// NewMock creates and returns a MockClient.
+func NewMock() *MockClient {
+	return &MockClient{
+		metadata:  map[string]*argoV1aplha1.RevisionMetadata{},
+		resources: map[types.NamespacedName][]*argoV1aplha1.ResourceDiff{},
+		trees:     map[types.NamespacedName]*argoV1aplha1.ApplicationTree{},
+	}
+}
+
+// RevisionMetadata implements the Client interface.
+func (m *MockClient) RevisionMetadata(ctx context.Context, app types.NamespacedName, revision string) (*argoV1aplha1.RevisionMetadata, error) {
+	metadata, ok := m.metadata[mockKey(app.Namespace, app.Name, revision)]
+	if !ok {
+		return nil, Error{Status: http.StatusNotFound, Message: "mock not found"}
+	}
+	return metadata, nil
+}
+
+// ManagedResources implements the Client interface.
+func (m *MockClient) ManagedResources(ctx context.Context, app types.NamespacedName) ([]*argoV1aplha1.ResourceDiff, error) {
+	resources, ok := m.resources[app]
+	if !ok {
+		return nil, Error{Status: http.StatusNotFound, Message: "mock not found"}
+	}
+	return resources, nil
+}
+
+// ResourceTree implements the Client interface.
+func (m *MockClient) ResourceTree(ctx context.Context, app types.NamespacedName) (*argoV1aplha1.ApplicationTree, error) {
+	tree, ok := m.trees[app]
+	if !ok {
+		return nil, Error{Status: http.StatusNotFound, Message: "mock not found"}
+	}
+	return tree, nil
+}
+
+// AddRevisionMetadata is a mock method that sets up the metadata to be
+// returned for a revision.
+func (m *MockClient) AddRevisionMetadata(app types.NamespacedName, revision string, metadata *argoV1aplha1.RevisionMetadata) {
+	m.metadata[mockKey(app.Namespace, app.Name, revision)] = metadata
+}
+
+// AddManagedResources is a mock method that sets up the resources to be
+// returned for an application.
+func (m *MockClient) AddManagedResources(app types.NamespacedName, resources []*argoV1aplha1.ResourceDiff) {
+	m.resources[app] = resources
+}
+
+// AddResourceTree is a mock method that sets up the tree to be returned for
+// an application.
+func (m *MockClient) AddResourceTree(app types.NamespacedName, tree *argoV1aplha1.ApplicationTree) {
+	m.trees[app] = tree
+}
+
+func mockKey(s ...string) string {
+	return strings.Join(s, "#")
+}
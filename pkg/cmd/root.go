@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strings"
+	"time"
 
 	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -14,20 +17,37 @@ import (
 	"k8s.io/client-go/rest"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+	"github.com/redhat-developer/gitops-backend/pkg/credentials"
 	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/gitfs"
 	"github.com/redhat-developer/gitops-backend/pkg/health"
 	"github.com/redhat-developer/gitops-backend/pkg/httpapi"
 	"github.com/redhat-developer/gitops-backend/pkg/httpapi/secrets"
+	"github.com/redhat-developer/gitops-backend/pkg/kinds"
 	"github.com/redhat-developer/gitops-backend/pkg/metrics"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+	"github.com/redhat-developer/gitops-backend/pkg/webhooks"
 )
 
 const (
-	portFlag     = "port"
-	insecureFlag = "insecure"
-	tlsCertFlag  = "tls-cert"
-	tlsKeyFlag   = "tls-key"
-	noTLSFlag    = "no-tls"
-	enableHTTP2  = "enable-http2"
+	portFlag      = "port"
+	insecureFlag  = "insecure"
+	tlsCertFlag   = "tls-cert"
+	tlsKeyFlag    = "tls-key"
+	noTLSFlag     = "no-tls"
+	enableHTTP2   = "enable-http2"
+	cacheSizeFlag = "cache-size"
+	cacheTTLFlag  = "cache-ttl"
+	argoCDCAFlag  = "argocd-ca-file"
+	argoCDFlag    = "argocd-instance"
+	kindsFlag     = "resource-kinds"
+	imagePathFlag = "image-paths"
+	gitHostsFlag  = "git-hosts"
+	repoCacheFlag = "repository-cache-size"
+	repoBytesFlag = "repository-cache-bytes"
+	sparseFlag    = "sparse-fetch"
+	webhookFlag   = "webhook-secret-file"
 )
 
 func init() {
@@ -56,13 +76,27 @@ func makeHTTPCmd() *cobra.Command {
 
 			http.Handle("/metrics", promhttp.Handler())
 			http.HandleFunc("/health", health.Handler)
+			http.HandleFunc("/openapi.json", httpapi.OpenAPIHandler)
 
-			router, err := makeAPIRouter(m)
+			router, cf, err := makeAPIRouter(m)
 			if err != nil {
 				return err
 			}
 			http.Handle("/", httpapi.AuthenticationMiddleware(router))
 
+			if filename := viper.GetString(webhookFlag); filename != "" {
+				secret, err := readWebhookSecret(filename)
+				if err != nil {
+					return err
+				}
+				// Webhooks are authenticated with the secret, rather than a
+				// bearer token.
+				invalidate := webhooks.ListenerFunc(func(p *webhooks.Push) {
+					cf.Invalidate(p.Host, p.Repo, p.Updates)
+				})
+				http.Handle("/webhooks/", webhooks.NewHandler(secret, invalidate, router))
+			}
+
 			listen := fmt.Sprintf(":%d", viper.GetInt(portFlag))
 			log.Printf("listening on %s", listen)
 
@@ -150,6 +184,83 @@ func makeHTTPCmd() *cobra.Command {
 		"comma-separated list of TLS cipher suites",
 	)
 	logIfError(viper.BindPFlag(tlsCipherSuitesFlag, cmd.Flags().Lookup(tlsCipherSuitesFlag)))
+
+	cmd.Flags().Int(
+		cacheSizeFlag,
+		1000,
+		"maximum number of resolved refs and files to cache from Git repositories",
+	)
+	logIfError(viper.BindPFlag(cacheSizeFlag, cmd.Flags().Lookup(cacheSizeFlag)))
+
+	cmd.Flags().Duration(
+		cacheTTLFlag,
+		time.Minute,
+		"how long a resolved ref is cached before it is resolved again",
+	)
+	logIfError(viper.BindPFlag(cacheTTLFlag, cmd.Flags().Lookup(cacheTTLFlag)))
+
+	cmd.Flags().String(
+		argoCDCAFlag,
+		"",
+		"filename for the CA bundle to verify the Argo CD server certificate, if not provided the certificate is not verified",
+	)
+	logIfError(viper.BindPFlag(argoCDCAFlag, cmd.Flags().Lookup(argoCDCAFlag)))
+
+	cmd.Flags().StringSlice(
+		argoCDFlag,
+		[]string{argocd.DefaultInstance.String()},
+		"Argo CD instances as namespace/name[=server URL], the first is the default for Applications outside of the instance namespaces",
+	)
+	logIfError(viper.BindPFlag(argoCDFlag, cmd.Flags().Lookup(argoCDFlag)))
+
+	cmd.Flags().String(
+		kindsFlag,
+		"",
+		"filename for a YAML list of resource kinds to report in application details, in addition to the default kinds",
+	)
+	logIfError(viper.BindPFlag(kindsFlag, cmd.Flags().Lookup(kindsFlag)))
+
+	cmd.Flags().String(
+		imagePathFlag,
+		"",
+		"filename for a YAML list of kinds and the JSONPath expressions for the images in their resources",
+	)
+	logIfError(viper.BindPFlag(imagePathFlag, cmd.Flags().Lookup(imagePathFlag)))
+
+	cmd.Flags().String(
+		gitHostsFlag,
+		"",
+		"filename for a YAML mapping of self-hosted Git hosts to drivers, server URLs and CA bundles",
+	)
+	logIfError(viper.BindPFlag(gitHostsFlag, cmd.Flags().Lookup(gitHostsFlag)))
+
+	cmd.Flags().Int(
+		repoCacheFlag,
+		10,
+		"maximum number of cloned GitOps repositories to keep in memory and fetch changes into, 0 clones the repository for each request",
+	)
+	logIfError(viper.BindPFlag(repoCacheFlag, cmd.Flags().Lookup(repoCacheFlag)))
+
+	cmd.Flags().Int64(
+		repoBytesFlag,
+		512<<20,
+		"maximum number of bytes of objects to keep in the cached GitOps repositories, 0 limits only the number of repositories",
+	)
+	logIfError(viper.BindPFlag(repoBytesFlag, cmd.Flags().Lookup(repoBytesFlag)))
+
+	cmd.Flags().Bool(
+		sparseFlag,
+		false,
+		"fetch only the files that are read to render an application from GitOps repositories, rather than cloning them",
+	)
+	logIfError(viper.BindPFlag(sparseFlag, cmd.Flags().Lookup(sparseFlag)))
+
+	cmd.Flags().String(
+		webhookFlag,
+		"",
+		"filename for the secret that authenticates push webhooks from Git hosts, webhooks are disabled if not provided",
+	)
+	logIfError(viper.BindPFlag(webhookFlag, cmd.Flags().Lookup(webhookFlag)))
 	return cmd
 }
 
@@ -168,19 +279,114 @@ func makeClusterConfig() (*rest.Config, error) {
 	return clusterConfig, nil
 }
 
-func makeAPIRouter(m metrics.Interface) (*httpapi.APIRouter, error) {
+func makeAPIRouter(m metrics.Interface) (*httpapi.APIRouter, *git.CachingClientFactory, error) {
 	config, err := makeClusterConfig()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	cf := git.NewClientFactory(m)
+	var hosts git.Hosts
+	if filename := viper.GetString(gitHostsFlag); filename != "" {
+		hosts, err = git.LoadHosts(filename)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	cf := git.NewCachingClientFactory(git.NewClientFactory(m, hosts), viper.GetInt(cacheSizeFlag), viper.GetDuration(cacheTTLFlag))
 	secretGetter := secrets.NewFromConfig(
 		&rest.Config{Host: config.Host},
 		viper.GetBool(insecureFlag))
-	k8sClient, err := ctrlclient.New(config, ctrlclient.Options{})
+	k8sClient, err := ctrlclient.NewWithWatch(config, ctrlclient.Options{})
+	if err != nil {
+		return nil, nil, err
+	}
+	argoCDInstances, err := makeArgoCDInstances(k8sClient)
+	if err != nil {
+		return nil, nil, err
+	}
+	resourceKinds, err := makeResourceKinds()
+	if err != nil {
+		return nil, nil, err
+	}
+	resourceParser, err := makeResourceParser()
+	if err != nil {
+		return nil, nil, err
+	}
+	router := httpapi.NewRouter(cf, secretGetter, credentials.NewResolver(hosts.HTTPClient, hosts.CABundle), resourceParser, k8sClient, argoCDInstances, resourceKinds)
+	return router, cf, nil
+}
+
+func makeResourceParser() (parser.ResourceParser, error) {
+	var imagePaths *parser.ImagePaths
+	if filename := viper.GetString(imagePathFlag); filename != "" {
+		loaded, err := parser.LoadImagePaths(filename)
+		if err != nil {
+			return nil, err
+		}
+		imagePaths = loaded
+	}
+	p := parser.New(imagePaths)
+	size := viper.GetInt(repoCacheFlag)
+	maxBytes := viper.GetInt64(repoBytesFlag)
+	sparse := viper.GetBool(sparseFlag)
+	switch {
+	case size <= 0 && sparse:
+		return p.ParseFromSparseFetch, nil
+	case size <= 0:
+		return p.ParseFromGit, nil
+	case sparse:
+		return p.ParseFromCache(gitfs.NewSparseRepositoryCache(size, maxBytes)), nil
+	}
+	return p.ParseFromCache(gitfs.NewRepositoryCache(size, maxBytes)), nil
+}
+
+func makeResourceKinds() (*kinds.Registry, error) {
+	registry := kinds.Default()
+	filename := viper.GetString(kindsFlag)
+	if filename == "" {
+		return registry, nil
+	}
+	loaded, err := kinds.LoadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	router := httpapi.NewRouter(cf, secretGetter, k8sClient)
-	return router, nil
+	for _, k := range loaded {
+		registry.Register(k)
+	}
+	return registry, nil
+}
+
+func readWebhookSecret(filename string) (string, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the webhook secret: %w", err)
+	}
+	secret := strings.TrimSpace(string(b))
+	if secret == "" {
+		return "", fmt.Errorf("the webhook secret in %s is empty", filename)
+	}
+	return secret, nil
+}
+
+func makeArgoCDInstances(kc ctrlclient.Client) (*argocd.Instances, error) {
+	var caBundle []byte
+	if caFile := viper.GetString(argoCDCAFlag); caFile != "" {
+		b, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the Argo CD CA bundle: %w", err)
+		}
+		caBundle = b
+	}
+	httpClient, err := argocd.NewHTTPClient(caBundle)
+	if err != nil {
+		return nil, err
+	}
+	instances := argocd.NewInstances()
+	for _, s := range viper.GetStringSlice(argoCDFlag) {
+		instance, err := argocd.ParseInstance(s)
+		if err != nil {
+			return nil, err
+		}
+		instances.Add(instance, argocd.New(instance.URL(), httpClient, argocd.SecretCredentials(kc, instance.AdminSecret())))
+	}
+	return instances, nil
 }
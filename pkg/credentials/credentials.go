@@ -0,0 +1,226 @@
+// Package credentials provides the credentials for accessing Git repositories,
+// from the contents of a secret.
+package credentials
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+)
+
+// The keys in a secret that are used to find the credentials.
+//
+// These are compatible with Argo CD repository secrets, and the
+// kubernetes.io/basic-auth and kubernetes.io/ssh-auth secret types.
+const (
+	tokenKey                   = "token"
+	usernameKey                = "username"
+	passwordKey                = "password"
+	sshPrivateKeyKey           = "sshPrivateKey"
+	sshAuthPrivateKeyKey       = "ssh-privatekey"
+	knownHostsKey              = "knownHosts"
+	githubAppIDKey             = "githubAppID"
+	githubAppInstallationIDKey = "githubAppInstallationID"
+	githubAppPrivateKeyKey     = "githubAppPrivateKey"
+	githubAppBaseURLKey        = "githubAppEnterpriseBaseUrl"
+)
+
+// defaultUsername is used to clone with a token over HTTPS, hosts ignore the
+// username when a token is used as the password.
+const defaultUsername = "gitops"
+
+// ErrNoAPIToken is returned by APIToken if the credentials only have an SSH
+// private key, which can clone repositories, but can't authenticate requests
+// to the Git host's API.
+var ErrNoAPIToken = errors.New("the secret has an SSH private key, but no token, username and password or GitHub App credentials for the Git host's API")
+
+// Credentials authenticate access to a Git repository.
+type Credentials struct {
+	// Username is used with the Token to clone over HTTPS.
+	Username string
+	// Token authenticates requests to the Git host's API, and is the password
+	// to clone over HTTPS.
+	Token string
+	// SSHPrivateKey if provided, repositories are cloned over SSH with this
+	// key.
+	SSHPrivateKey []byte
+	// KnownHosts are the host keys that SSH hosts are verified with, if not
+	// provided, the host keys are verified with the default known_hosts
+	// files.
+	KnownHosts []byte
+
+	// caBundle returns the CA bundle that a host's certificate is verified
+	// with when cloning over HTTPS.
+	caBundle func(host string) []byte
+}
+
+// Resolver finds the credentials in the contents of a secret, exchanging
+// GitHub App credentials for an installation token.
+type Resolver struct {
+	httpClient func(host string) *http.Client
+	caBundle   func(host string) []byte
+	tokens     *tokenCache
+}
+
+// NewResolver creates and returns a Resolver, the httpClient function returns
+// the client used to make requests to the GitHub API on a host, and the
+// caBundle function returns the CA bundle that a host's certificate is
+// verified with when repositories are cloned over HTTPS, or nil.
+func NewResolver(httpClient func(host string) *http.Client, caBundle func(host string) []byte) *Resolver {
+	return &Resolver{httpClient: httpClient, caBundle: caBundle, tokens: newTokenCache()}
+}
+
+// FromSecret returns the credentials for accessing the repository at repoURL
+// from the data in a secret.
+//
+// GitHub App credentials take precedence, followed by a username and
+// password, and then a token. An SSH private key can be provided alongside
+// these, to clone over SSH, while the token or password is used for the Git
+// host's API.
+func (r *Resolver) FromSecret(ctx context.Context, repoURL string, data map[string][]byte) (*Credentials, error) {
+	c := &Credentials{
+		Username:      defaultUsername,
+		Token:         string(data[tokenKey]),
+		SSHPrivateKey: data[sshPrivateKeyKey],
+		KnownHosts:    data[knownHostsKey],
+		caBundle:      r.caBundle,
+	}
+	if len(c.SSHPrivateKey) == 0 {
+		c.SSHPrivateKey = data[sshAuthPrivateKeyKey]
+	}
+	if username, ok := data[usernameKey]; ok {
+		password, ok := data[passwordKey]
+		if !ok {
+			return nil, fmt.Errorf("secret has a %s but no %s", usernameKey, passwordKey)
+		}
+		c.Username, c.Token = string(username), string(password)
+	}
+	if _, ok := data[githubAppIDKey]; ok {
+		app, err := parseGitHubApp(repoURL, data)
+		if err != nil {
+			return nil, err
+		}
+		token, err := r.installationToken(ctx, app)
+		if err != nil {
+			return nil, err
+		}
+		c.Username, c.Token = installationTokenUsername, token
+	}
+	if c.Token == "" && len(c.SSHPrivateKey) == 0 {
+		return nil, errors.New("secret has no token, username and password, SSH private key or GitHub App credentials")
+	}
+	return c, nil
+}
+
+// APIToken returns the token that authenticates requests to the Git host's
+// API, or ErrNoAPIToken if there is only an SSH private key.
+//
+// Requests without a token would only succeed for public repositories, so
+// they're not made.
+func (c *Credentials) APIToken() (string, error) {
+	if c.Token == "" {
+		return "", ErrNoAPIToken
+	}
+	return c.Token, nil
+}
+
+// CloneOptions returns the options to clone the repository at repoURL with
+// the credentials.
+//
+// If an SSH private key is provided, HTTPS URLs are converted to SSH URLs
+// for the same host and path, otherwise the host's certificate is verified
+// with the CA bundle that is configured for the host.
+func (c *Credentials) CloneOptions(repoURL string) (*git.CloneOptions, error) {
+	if len(c.SSHPrivateKey) == 0 {
+		opts := &git.CloneOptions{
+			URL:  repoURL,
+			Auth: &githttp.BasicAuth{Username: c.Username, Password: c.Token},
+		}
+		if c.caBundle != nil {
+			if parsed, err := url.Parse(repoURL); err == nil {
+				opts.CABundle = c.caBundle(parsed.Host)
+			}
+		}
+		return opts, nil
+	}
+	sshURL, hostWithPort, err := toSSHURL(repoURL)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ssh.NewPublicKeys("git", c.SSHPrivateKey, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the SSH private key: %w", err)
+	}
+	if len(c.KnownHosts) > 0 {
+		if err := configureKnownHosts(&keys.HostKeyCallbackHelper, c.KnownHosts, hostWithPort); err != nil {
+			return nil, err
+		}
+	}
+	return &git.CloneOptions{URL: sshURL, Auth: keys}, nil
+}
+
+// toSSHURL converts an HTTPS repository URL to an SSH URL, and returns it with
+// the host and port that are connected to.
+//
+// SSH URLs, including the scp-like git@host:path form, are returned
+// unchanged.
+func toSSHURL(repoURL string) (string, string, error) {
+	if !strings.Contains(repoURL, "://") {
+		if i := strings.Index(repoURL, ":"); i > 0 {
+			host := repoURL[strings.Index(repoURL, "@")+1 : i]
+			return repoURL, net.JoinHostPort(host, "22"), nil
+		}
+	}
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse %#v: %w", repoURL, err)
+	}
+	switch parsed.Scheme {
+	case "ssh":
+		port := parsed.Port()
+		if port == "" {
+			port = "22"
+		}
+		return repoURL, net.JoinHostPort(parsed.Hostname(), port), nil
+	case "http", "https":
+		u := url.URL{Scheme: "ssh", User: url.User("git"), Host: parsed.Hostname(), Path: parsed.Path}
+		return u.String(), net.JoinHostPort(parsed.Hostname(), "22"), nil
+	}
+	return "", "", fmt.Errorf("can't clone %#v over SSH", repoURL)
+}
+
+// configureKnownHosts configures the SSH host keys to be verified with the
+// known hosts.
+//
+// The known hosts are written to a temporary file, as they can only be read
+// from files, the file is read when the database is created.
+func configureKnownHosts(h *ssh.HostKeyCallbackHelper, knownHosts []byte, hostWithPort string) error {
+	f, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return fmt.Errorf("failed to write the known hosts: %w", err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(knownHosts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write the known hosts: %w", err)
+	}
+	db, err := ssh.NewKnownHostsDb(f.Name())
+	if err != nil {
+		return fmt.Errorf("failed to parse the known hosts: %w", err)
+	}
+	h.HostKeyCallback = db.HostKeyCallback()
+	h.HostKeyAlgorithms = db.HostKeyAlgorithms(hostWithPort)
+	return nil
+}
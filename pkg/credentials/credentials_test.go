@@ -0,0 +1,222 @@
+package credentials
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+const (
+	testRepoURL    = "https://github.com/example/gitops.git"
+	testKnownHosts = "github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"
+)
+
+func TestFromSecret(t *testing.T) {
+	key := testPrivateKey(t)
+	secretTests := []struct {
+		name string
+		data map[string][]byte
+		want *Credentials
+	}{
+		{
+			"token",
+			map[string][]byte{"token": []byte("test-token")},
+			&Credentials{Username: "gitops", Token: "test-token"},
+		},
+		{
+			"basic auth",
+			map[string][]byte{"username": []byte("deploy"), "password": []byte("test-password")},
+			&Credentials{Username: "deploy", Token: "test-password"},
+		},
+		{
+			"SSH private key",
+			map[string][]byte{"sshPrivateKey": key, "knownHosts": []byte(testKnownHosts)},
+			&Credentials{Username: "gitops", SSHPrivateKey: key, KnownHosts: []byte(testKnownHosts)},
+		},
+		{
+			"SSH auth secret with a token",
+			map[string][]byte{"ssh-privatekey": key, "token": []byte("test-token")},
+			&Credentials{Username: "gitops", Token: "test-token", SSHPrivateKey: key},
+		},
+	}
+
+	r := NewResolver(func(string) *http.Client { return http.DefaultClient }, nil)
+	for _, tt := range secretTests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.FromSecret(context.TODO(), testRepoURL, tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got, cmpopts.IgnoreUnexported(Credentials{})); diff != "" {
+				t.Fatalf("incorrect credentials:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestFromSecretWithInvalidSecrets(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		data    map[string][]byte
+		wantErr string
+	}{
+		{"empty", map[string][]byte{}, "secret has no token"},
+		{"missing password", map[string][]byte{"username": []byte("deploy")}, "secret has a username but no password"},
+		{"invalid app ID", map[string][]byte{"githubAppID": []byte("app")}, "invalid githubAppID in secret"},
+		{"missing installation ID", map[string][]byte{"githubAppID": []byte("1")}, "invalid githubAppInstallationID in secret"},
+		{
+			"missing app private key",
+			map[string][]byte{"githubAppID": []byte("1"), "githubAppInstallationID": []byte("2")},
+			"secret has a githubAppID but no githubAppPrivateKey",
+		},
+	}
+
+	r := NewResolver(func(string) *http.Client { return http.DefaultClient }, nil)
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := r.FromSecret(context.TODO(), testRepoURL, tt.data)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	token, err := (&Credentials{Token: "test-token"}).APIToken()
+	if err != nil || token != "test-token" {
+		t.Fatalf("got %q and %v, want the token", token, err)
+	}
+
+	_, err = (&Credentials{SSHPrivateKey: testPrivateKey(t)}).APIToken()
+	if err != ErrNoAPIToken {
+		t.Fatalf("got %v, want ErrNoAPIToken", err)
+	}
+}
+
+func TestCloneOptions(t *testing.T) {
+	c := &Credentials{Username: "deploy", Token: "test-password"}
+
+	got, err := c.CloneOptions(testRepoURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &git.CloneOptions{
+		URL:  testRepoURL,
+		Auth: &githttp.BasicAuth{Username: "deploy", Password: "test-password"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect clone options:\n%s", diff)
+	}
+}
+
+func TestCloneOptionsWithCABundle(t *testing.T) {
+	ca := []byte("-----BEGIN CERTIFICATE-----\n")
+	r := NewResolver(func(string) *http.Client { return http.DefaultClient }, func(host string) []byte {
+		if host == "git.example.com:8443" {
+			return ca
+		}
+		return nil
+	})
+	c, err := r.FromSecret(context.TODO(), testRepoURL, map[string][]byte{"token": []byte("test-token")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := c.CloneOptions("https://git.example.com:8443/example/gitops.git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(ca, got.CABundle); diff != "" {
+		t.Fatalf("incorrect CA bundle:\n%s", diff)
+	}
+
+	got, err = c.CloneOptions(testRepoURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CABundle != nil {
+		t.Fatalf("got CA bundle %q for a host without one", got.CABundle)
+	}
+}
+
+func TestCloneOptionsWithSSHPrivateKey(t *testing.T) {
+	c := &Credentials{Token: "test-token", SSHPrivateKey: testPrivateKey(t), KnownHosts: []byte(testKnownHosts)}
+
+	got, err := c.CloneOptions(testRepoURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.URL != "ssh://git@github.com/example/gitops.git" {
+		t.Fatalf("got URL %q, want an SSH URL", got.URL)
+	}
+	keys, ok := got.Auth.(*ssh.PublicKeys)
+	if !ok {
+		t.Fatalf("got auth %T, want SSH public keys", got.Auth)
+	}
+	if keys.User != "git" {
+		t.Fatalf("got user %q, want git", keys.User)
+	}
+	if keys.HostKeyCallback == nil {
+		t.Fatal("host keys are not verified with the known hosts")
+	}
+	if diff := cmp.Diff([]string{"ssh-ed25519"}, keys.HostKeyAlgorithms); diff != "" {
+		t.Fatalf("incorrect host key algorithms:\n%s", diff)
+	}
+}
+
+func TestCloneOptionsWithInvalidSSHPrivateKey(t *testing.T) {
+	c := &Credentials{SSHPrivateKey: []byte("not a key")}
+
+	_, err := c.CloneOptions(testRepoURL)
+	if err == nil || !strings.Contains(err.Error(), "failed to parse the SSH private key") {
+		t.Fatalf("got error %v, want a parse error", err)
+	}
+}
+
+func TestToSSHURL(t *testing.T) {
+	urlTests := []struct {
+		repoURL  string
+		wantURL  string
+		wantHost string
+	}{
+		{"https://github.com/example/gitops.git", "ssh://git@github.com/example/gitops.git", "github.com:22"},
+		{"https://gitlab.example.com:8443/example/gitops.git", "ssh://git@gitlab.example.com/example/gitops.git", "gitlab.example.com:22"},
+		{"ssh://git@gitlab.example.com:2222/example/gitops.git", "ssh://git@gitlab.example.com:2222/example/gitops.git", "gitlab.example.com:2222"},
+		{"git@github.com:example/gitops.git", "git@github.com:example/gitops.git", "github.com:22"},
+	}
+
+	for _, tt := range urlTests {
+		t.Run(tt.repoURL, func(t *testing.T) {
+			gotURL, gotHost, err := toSSHURL(tt.repoURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if gotURL != tt.wantURL || gotHost != tt.wantHost {
+				t.Fatalf("got %q and %q, want %q and %q", gotURL, gotHost, tt.wantURL, tt.wantHost)
+			}
+		})
+	}
+}
+
+func testPrivateKey(t *testing.T) []byte {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+}
@@ -0,0 +1,178 @@
+package credentials
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// installationTokenUsername is the username to clone over HTTPS with a GitHub
+// App installation token.
+const installationTokenUsername = "x-access-token"
+
+// tokenExpiryMargin is how long before an installation token expires that it
+// is replaced, so that a token doesn't expire during a request.
+const tokenExpiryMargin = 5 * time.Minute
+
+// githubApp is the credentials for a GitHub App installation.
+type githubApp struct {
+	baseURL        string
+	appID          int64
+	installationID int64
+	privateKey     []byte
+}
+
+func parseGitHubApp(repoURL string, data map[string][]byte) (*githubApp, error) {
+	app := &githubApp{privateKey: data[githubAppPrivateKeyKey]}
+	var err error
+	if app.appID, err = parseID(data, githubAppIDKey); err != nil {
+		return nil, err
+	}
+	if app.installationID, err = parseID(data, githubAppInstallationIDKey); err != nil {
+		return nil, err
+	}
+	if len(app.privateKey) == 0 {
+		return nil, fmt.Errorf("secret has a %s but no %s", githubAppIDKey, githubAppPrivateKeyKey)
+	}
+	if app.baseURL, err = githubAPIURL(repoURL, string(data[githubAppBaseURLKey])); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+func parseID(data map[string][]byte, key string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimSpace(string(data[key])), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s in secret: %w", key, err)
+	}
+	return id, nil
+}
+
+// githubAPIURL returns the URL of the GitHub API for the repository, this is
+// the public API for github.com, and the GitHub Enterprise API for other
+// hosts.
+func githubAPIURL(repoURL, enterpriseURL string) (string, error) {
+	if enterpriseURL != "" {
+		return strings.TrimSuffix(enterpriseURL, "/"), nil
+	}
+	parsed, err := url.Parse(repoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %#v: %w", repoURL, err)
+	}
+	if parsed.Host == "github.com" {
+		return "https://api.github.com", nil
+	}
+	return "https://" + parsed.Host + "/api/v3", nil
+}
+
+// key identifies the installation token, the private key is included so
+// that a token is only shared by secrets with the same credentials.
+func (a *githubApp) key() string {
+	h := sha256.Sum256(a.privateKey)
+	return fmt.Sprintf("%s#%d#%d#%s", a.baseURL, a.appID, a.installationID, hex.EncodeToString(h[:]))
+}
+
+// installationToken exchanges the GitHub App credentials for an installation
+// token, tokens are cached until shortly before they expire.
+func (r *Resolver) installationToken(ctx context.Context, app *githubApp) (string, error) {
+	if token, ok := r.tokens.get(app.key()); ok {
+		return token, nil
+	}
+	signed, err := appJWT(app)
+	if err != nil {
+		return "", err
+	}
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", app.baseURL, app.installationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+signed)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	res, err := r.httpClient(req.URL.Host).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a GitHub App installation token: %w", err)
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to get a GitHub App installation token: %w", err)
+	}
+	if res.StatusCode != http.StatusCreated {
+		return "", fmt.Errorf("failed to get a GitHub App installation token, got status %d: %s", res.StatusCode, body)
+	}
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("failed to parse the GitHub App installation token: %w", err)
+	}
+	r.tokens.add(app.key(), token.Token, token.ExpiresAt.Add(-tokenExpiryMargin))
+	return token.Token, nil
+}
+
+// appJWT returns a JWT that authenticates as the GitHub App.
+//
+// The issued time is in the past to allow for clock drift, as recommended by
+// GitHub.
+func appJWT(app *githubApp) (string, error) {
+	key, err := jwt.ParseRSAPrivateKeyFromPEM(app.privateKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the GitHub App private key: %w", err)
+	}
+	now := time.Now()
+	claims := jwt.RegisteredClaims{
+		Issuer:    strconv.FormatInt(app.appID, 10),
+		IssuedAt:  jwt.NewNumericDate(now.Add(-time.Minute)),
+		ExpiresAt: jwt.NewNumericDate(now.Add(9 * time.Minute)),
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign the GitHub App JWT: %w", err)
+	}
+	return signed, nil
+}
+
+// tokenCache holds installation tokens until they expire.
+type tokenCache struct {
+	sync.Mutex
+	tokens map[string]cachedToken
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{tokens: map[string]cachedToken{}}
+}
+
+func (c *tokenCache) get(key string) (string, bool) {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.tokens[key]
+	if !ok || !time.Now().Before(t.expires) {
+		delete(c.tokens, key)
+		return "", false
+	}
+	return t.token, true
+}
+
+func (c *tokenCache) add(key, token string, expires time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	c.tokens[key] = cachedToken{token: token, expires: expires}
+}
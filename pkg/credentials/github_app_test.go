@@ -0,0 +1,107 @@
+package credentials
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestFromSecretWithGitHubApp(t *testing.T) {
+	key := testPrivateKey(t)
+	block, _ := pem.Decode(key)
+	parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/5678/access_tokens" {
+			http.NotFound(w, r)
+			return
+		}
+		claims := &jwt.RegisteredClaims{}
+		_, err := jwt.ParseWithClaims(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), claims, func(*jwt.Token) (interface{}, error) {
+			return &parsed.PublicKey, nil
+		}, jwt.WithValidMethods([]string{"RS256"}))
+		if err != nil || claims.Issuer != "1234" {
+			t.Errorf("invalid JWT: %v, issuer %q", err, claims.Issuer)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_installation", "expires_at": %q}`, time.Now().Add(time.Hour).Format(time.RFC3339))
+	}))
+	defer ts.Close()
+	data := map[string][]byte{
+		"githubAppID":                []byte("1234"),
+		"githubAppInstallationID":    []byte("5678"),
+		"githubAppPrivateKey":        key,
+		"githubAppEnterpriseBaseUrl": []byte(ts.URL + "/api/v3/"),
+	}
+	r := NewResolver(func(string) *http.Client { return ts.Client() }, nil)
+
+	for i := 0; i < 2; i++ {
+		got, err := r.FromSecret(context.TODO(), "https://gh.example.com/example/gitops.git", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Username != "x-access-token" || got.Token != "ghs_installation" {
+			t.Fatalf("got %#v, want the installation token", got)
+		}
+	}
+	if requests != 1 {
+		t.Fatalf("got %d token requests, want 1", requests)
+	}
+}
+
+func TestFromSecretWithFailedGitHubAppExchange(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Integration not found"}`, http.StatusNotFound)
+	}))
+	defer ts.Close()
+	data := map[string][]byte{
+		"githubAppID":                []byte("1234"),
+		"githubAppInstallationID":    []byte("5678"),
+		"githubAppPrivateKey":        testPrivateKey(t),
+		"githubAppEnterpriseBaseUrl": []byte(ts.URL),
+	}
+	r := NewResolver(func(string) *http.Client { return ts.Client() }, nil)
+
+	_, err := r.FromSecret(context.TODO(), testRepoURL, data)
+	if err == nil || !strings.Contains(err.Error(), "got status 404") {
+		t.Fatalf("got error %v, want a failed exchange", err)
+	}
+}
+
+func TestGitHubAPIURL(t *testing.T) {
+	urlTests := []struct {
+		repoURL       string
+		enterpriseURL string
+		want          string
+	}{
+		{"https://github.com/example/gitops.git", "", "https://api.github.com"},
+		{"https://gh.example.com/example/gitops.git", "", "https://gh.example.com/api/v3"},
+		{"https://gh.example.com/example/gitops.git", "https://api.gh.example.com/", "https://api.gh.example.com"},
+	}
+
+	for _, tt := range urlTests {
+		t.Run(tt.repoURL, func(t *testing.T) {
+			got, err := githubAPIURL(tt.repoURL, tt.enterpriseURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package git
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/redhat-developer/gitops-backend/internal/cache"
+)
+
+// CachingClientFactory is an implementation of the ClientFactory interface
+// that wraps another factory, the clients that it creates share a cache.
+//
+// Refs are resolved to commits and cached for a TTL, file contents are cached
+// by the commit SHA they were read at, so a cached file is never stale for the
+// commit.
+type CachingClientFactory struct {
+	factory ClientFactory
+	refs    *cache.LRU[string, *Commit]
+	files   *cache.LRU[string, []byte]
+}
+
+// NewCachingClientFactory creates and returns a CachingClientFactory that
+// caches up to size refs and files, resolved refs expire after the ttl.
+func NewCachingClientFactory(f ClientFactory, size int, ttl time.Duration) *CachingClientFactory {
+	return &CachingClientFactory{
+		factory: f,
+		refs:    cache.New[string, *Commit](size, ttl),
+		files:   cache.New[string, []byte](size, 0),
+	}
+}
+
+// Create implements the ClientFactory interface.
+func (f *CachingClientFactory) Create(url, token string) (SCM, error) {
+	client, err := f.factory.Create(url, token)
+	if err != nil {
+		return nil, err
+	}
+	host, err := hostFromURL(url)
+	if err != nil {
+		return nil, err
+	}
+	return &cachingClient{SCM: client, host: host, identity: identity(host, token), factory: f}, nil
+}
+
+// cachingClient is an SCM that reads through the caches in the factory.
+type cachingClient struct {
+	SCM
+	host     string
+	identity string
+	factory  *CachingClientFactory
+}
+
+// ResolveRef implements the SCM interface.
+//
+// Resolved refs are cached per token, so that a token that can't access the
+// repository can't see a ref resolved with another token. A rotated token,
+// e.g. a GitHub App installation token, resolves the ref again once.
+func (c *cachingClient) ResolveRef(ctx context.Context, repo, ref string) (*Commit, error) {
+	key := cacheKey(c.host, repo, c.identity, ref)
+	if commit, ok := c.factory.refs.Get(key); ok {
+		return commit, nil
+	}
+	commit, err := c.SCM.ResolveRef(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	c.factory.refs.Add(key, commit)
+	return commit, nil
+}
+
+// FileContents implements the SCM interface.
+//
+// The ref is always resolved with the client's token before the file is
+// fetched, this ensures that the token has access to the repository, as the
+// file contents are cached by the resolved commit SHA and shared between
+// tokens.
+func (c *cachingClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	commit, err := c.ResolveRef(ctx, repo, ref)
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey(c.host, repo, path, commit.SHA)
+	if body, ok := c.factory.files.Get(key); ok {
+		return body, nil
+	}
+	body, err := c.SCM.FileContents(ctx, repo, path, commit.SHA)
+	if err != nil {
+		return nil, err
+	}
+	c.factory.files.Add(key, body)
+	return body, nil
+}
+
+// Invalidate removes the refs in the repository on the host that were
+// updated from the cache, so that they're resolved again, e.g. when a push to
+// the repository is received.
+//
+// File contents are cached by the commit SHA, so they're never stale.
+func (f *CachingClientFactory) Invalidate(host, repo string, updated func(ref string) bool) {
+	f.refs.RemoveFunc(func(key string) bool {
+		// The key is the host, repo, identity and ref.
+		parts := strings.SplitN(key, "#", 4)
+		return len(parts) == 4 &&
+			strings.EqualFold(parts[0], host) &&
+			strings.EqualFold(parts[1], repo) &&
+			updated(parts[3])
+	})
+}
+
+func cacheKey(s ...string) string {
+	return strings.Join(s, "#")
+}
+
+// identity returns a value that identifies the credentials used to access a
+// host, without keeping the token in memory.
+func identity(host, token string) string {
+	h := sha256.Sum256([]byte(host + "#" + token))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+var _ ClientFactory = (*CachingClientFactory)(nil)
+
+func TestCachingClientCachesFileContents(t *testing.T) {
+	stub := newStubSCM()
+	stub.refs["main"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	stub.files["pipelines.yaml#6dcb09b5b57875f334f61aebed695e2e4193db5e"] = []byte("testing")
+	f := NewCachingClientFactory(stubFactory{client: stub}, 10, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		client, err := f.Create("https://github.com/example/gitops.git", "test-token")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := client.FileContents(context.TODO(), "example/gitops", "pipelines.yaml", "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]byte("testing"), body); diff != "" {
+			t.Fatalf("incorrect body:\n%s", diff)
+		}
+	}
+
+	if stub.fileCalls != 1 {
+		t.Fatalf("got %d file fetches, want 1", stub.fileCalls)
+	}
+	if stub.refCalls != 1 {
+		t.Fatalf("got %d ref resolutions, want 1", stub.refCalls)
+	}
+}
+
+func TestCachingClientResolvesInvalidatedRefsAgain(t *testing.T) {
+	stub := newStubSCM()
+	stub.refs["main"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	stub.refs["HEAD"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	stub.refs["v1"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	f := NewCachingClientFactory(stubFactory{client: stub}, 10, time.Minute)
+	client, err := f.Create("https://github.com/example/gitops.git", "test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolve := func(ref string) {
+		t.Helper()
+		if _, err := client.ResolveRef(context.TODO(), "example/gitops", ref); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, ref := range []string{"main", "HEAD", "v1"} {
+		resolve(ref)
+	}
+
+	f.Invalidate("github.com", "Example/GitOps", func(ref string) bool {
+		return ref == "main" || ref == "HEAD"
+	})
+	for _, ref := range []string{"main", "HEAD", "v1"} {
+		resolve(ref)
+	}
+
+	if stub.refCalls != 5 {
+		t.Fatalf("got %d ref resolutions, want 5", stub.refCalls)
+	}
+}
+
+func TestCachingClientIsolatesRefsBetweenTokens(t *testing.T) {
+	stub := newStubSCM()
+	stub.refs["main"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	stub.files["pipelines.yaml#6dcb09b5b57875f334f61aebed695e2e4193db5e"] = []byte("testing")
+	denied := newStubSCM()
+	f := NewCachingClientFactory(tokenFactory{"token1": stub, "token2": denied}, 10, time.Minute)
+
+	client, err := f.Create("https://github.com/example/gitops.git", "token1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.FileContents(context.TODO(), "example/gitops", "pipelines.yaml", "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err = f.Create("https://github.com/example/gitops.git", "token2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ResolveRef(context.TODO(), "example/gitops", "main"); !IsNotFound(err) {
+		t.Fatalf("got %#v, want a not found error resolving the ref", err)
+	}
+	if _, err := client.FileContents(context.TODO(), "example/gitops", "pipelines.yaml", "main"); !IsNotFound(err) {
+		t.Fatalf("got %#v, want a not found error reading the file", err)
+	}
+	if denied.refCalls != 2 {
+		t.Fatalf("got %d ref resolutions with the second token, want 2", denied.refCalls)
+	}
+}
+
+func TestCachingClientExpiresRefs(t *testing.T) {
+	stub := newStubSCM()
+	stub.refs["main"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	f := NewCachingClientFactory(stubFactory{client: stub}, 10, time.Nanosecond)
+	client, err := f.Create("https://github.com/example/gitops.git", "test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		time.Sleep(time.Millisecond)
+		if _, err := client.ResolveRef(context.TODO(), "example/gitops", "main"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if stub.refCalls != 2 {
+		t.Fatalf("got %d ref resolutions, want 2", stub.refCalls)
+	}
+}
+
+func TestCachingClientDoesNotCacheErrors(t *testing.T) {
+	stub := newStubSCM()
+	stub.refs["main"] = "6dcb09b5b57875f334f61aebed695e2e4193db5e"
+	f := NewCachingClientFactory(stubFactory{client: stub}, 10, time.Minute)
+	client, err := f.Create("https://github.com/example/gitops.git", "test-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := client.FileContents(context.TODO(), "example/gitops", "pipelines.yaml", "main")
+		if !IsNotFound(err) {
+			t.Fatalf("got %#v, want a not found error", err)
+		}
+	}
+	if stub.fileCalls != 2 {
+		t.Fatalf("got %d file fetches, want 2", stub.fileCalls)
+	}
+}
+
+type stubFactory struct {
+	client SCM
+}
+
+func (s stubFactory) Create(url, token string) (SCM, error) {
+	return s.client, nil
+}
+
+// tokenFactory creates the client for each token.
+type tokenFactory map[string]SCM
+
+func (s tokenFactory) Create(url, token string) (SCM, error) {
+	return s[token], nil
+}
+
+func newStubSCM() *stubSCM {
+	return &stubSCM{refs: map[string]string{}, files: map[string][]byte{}}
+}
+
+// stubSCM implements the read methods, the write methods aren't cached, and
+// aren't called in these tests.
+type stubSCM struct {
+	SCM
+	refs      map[string]string
+	files     map[string][]byte
+	refCalls  int
+	fileCalls int
+}
+
+func (s *stubSCM) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	s.fileCalls++
+	body, ok := s.files[path+"#"+ref]
+	if !ok {
+		return nil, SCMError{Status: http.StatusNotFound}
+	}
+	return body, nil
+}
+
+func (s *stubSCM) ResolveRef(ctx context.Context, repo, ref string) (*Commit, error) {
+	s.refCalls++
+	sha, ok := s.refs[ref]
+	if !ok {
+		return nil, SCMError{Status: http.StatusNotFound}
+	}
+	return &Commit{SHA: sha}, nil
+}
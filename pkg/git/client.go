@@ -3,6 +3,8 @@ package git
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"time"
 
 	"github.com/jenkins-x/go-scm/scm"
 	"github.com/redhat-developer/gitops-backend/pkg/metrics"
@@ -10,13 +12,21 @@ import (
 
 // New creates and returns a new SCMClient.
 func New(c *scm.Client, m metrics.Interface) *SCMClient {
-	return &SCMClient{Client: c, m: m}
+	return &SCMClient{Client: c, m: m, retries: defaultRetries, backoff: defaultBackoff}
 }
 
 // SCMClient is a wrapper for the go-scm scm.Client with a simplified API.
+//
+// Requests that read from the upstream service are retried if they fail with
+// a network error or a server error, and the remaining rate limit from each
+// response is recorded in the metrics.
+//
+// If the rate limit has been exceeded, a RateLimitError is returned.
 type SCMClient struct {
-	Client *scm.Client
-	m      metrics.Interface
+	Client  *scm.Client
+	m       metrics.Interface
+	retries int
+	backoff time.Duration
 }
 
 // FileContents reads the specific revision of a file from a repository.
@@ -25,10 +35,14 @@ type SCMClient struct {
 // response status code is returned.
 func (c *SCMClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
 	c.m.CountAPICall("file_contents")
-	content, r, err := c.Client.Contents.Find(ctx, repo, path, ref)
+	var content *scm.Content
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		content, r, err = c.Client.Contents.Find(ctx, repo, path, ref)
+		return r, err
+	})
 	if r != nil && isErrorStatus(r.Status) {
 		c.m.CountFailedAPICall("file_contents")
-		return nil, SCMError{msg: fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref), Status: r.Status}
+		return nil, responseError(r, fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, ref))
 	}
 	if err != nil {
 		c.m.CountFailedAPICall("file_contents")
@@ -38,6 +52,325 @@ func (c *SCMClient) FileContents(ctx context.Context, repo, path, ref string) ([
 	return content.Data, nil
 }
 
+// ResolveRef finds the commit that a branch, tag or commit SHA refers to.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ResolveRef(ctx context.Context, repo, ref string) (*Commit, error) {
+	c.m.CountAPICall("resolve_ref")
+	var commit *scm.Commit
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		commit, r, err = c.Client.Git.FindCommit(ctx, repo, ref)
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("resolve_ref")
+		return nil, responseError(r, fmt.Sprintf("failed to resolve ref %s in repo %s", ref, repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("resolve_ref")
+		return nil, err
+	}
+	return convertCommit(commit), nil
+}
+
+// ListDirectory lists the entries in a directory at a specific revision of a
+// repository.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListDirectory(ctx context.Context, repo, path, ref string) ([]DirectoryEntry, error) {
+	c.m.CountAPICall("list_directory")
+	var files []*scm.FileEntry
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		files, r, err = c.Client.Contents.List(ctx, repo, path, ref)
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("list_directory")
+		return nil, responseError(r, fmt.Sprintf("failed to list directory %s in repo %s ref %s", path, repo, ref))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("list_directory")
+		return nil, err
+	}
+	entries := make([]DirectoryEntry, len(files))
+	for i, f := range files {
+		entries[i] = DirectoryEntry{Name: f.Name, Path: f.Path, Type: f.Type, SHA: f.Sha}
+	}
+	return entries, nil
+}
+
+// ListCommits lists the commits that changed a path, starting from the ref,
+// only the most recent 100 commits that changed the path are returned.
+//
+// If the upstream service can't filter the commits by the path, the commits
+// are searched with at most maxCommitSearchCalls API calls, and the commits
+// that were found are returned.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListCommits(ctx context.Context, repo, path, ref string) ([]*Commit, error) {
+	c.m.CountAPICall("list_commits")
+	commits, err := c.listCommits(ctx, repo, path, ref)
+	if err != nil {
+		c.m.CountFailedAPICall("list_commits")
+		return nil, err
+	}
+	result := make([]*Commit, len(commits))
+	for i, commit := range commits {
+		result[i] = convertCommit(commit)
+	}
+	return result, nil
+}
+
+// GetCommit finds a commit, and the paths of the files that it changed.
+//
+// The pages of changed files are followed, up to maxChangePages pages, the
+// commit's FilesTruncated is set if it changed more files.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) GetCommit(ctx context.Context, repo, sha string) (*Commit, error) {
+	c.m.CountAPICall("get_commit")
+	var commit *scm.Commit
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		commit, r, err = c.Client.Git.FindCommit(ctx, repo, sha)
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("get_commit")
+		return nil, responseError(r, fmt.Sprintf("failed to get commit %s in repo %s", sha, repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("get_commit")
+		return nil, err
+	}
+	result := convertCommit(commit)
+	result.Files = []string{}
+	opts := &scm.ListOptions{Size: commitsPageSize}
+	for page := 0; ; page++ {
+		if page == maxChangePages {
+			result.FilesTruncated = true
+			return result, nil
+		}
+		var changes []*scm.Change
+		r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+			changes, r, err = c.Client.Git.ListChanges(ctx, repo, commit.Sha, opts)
+			return r, err
+		})
+		if r != nil && isErrorStatus(r.Status) {
+			c.m.CountFailedAPICall("get_commit")
+			return nil, responseError(r, fmt.Sprintf("failed to list the changes in commit %s in repo %s", sha, repo))
+		}
+		if err != nil {
+			c.m.CountFailedAPICall("get_commit")
+			return nil, err
+		}
+		for _, change := range changes {
+			result.Files = append(result.Files, change.Path)
+		}
+		if r == nil || r.Page.Next == 0 {
+			return result, nil
+		}
+		opts.Page = r.Page.Next
+	}
+}
+
+// ListBranches lists all the branches in a repository, following the pages
+// of results.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) ListBranches(ctx context.Context, repo string) ([]Branch, error) {
+	c.m.CountAPICall("list_branches")
+	var branches []Branch
+	opts := &scm.ListOptions{Size: 100}
+	for {
+		var refs []*scm.Reference
+		r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+			refs, r, err = c.Client.Git.ListBranches(ctx, repo, opts)
+			return r, err
+		})
+		if r != nil && isErrorStatus(r.Status) {
+			c.m.CountFailedAPICall("list_branches")
+			return nil, responseError(r, fmt.Sprintf("failed to list branches in repo %s", repo))
+		}
+		if err != nil {
+			c.m.CountFailedAPICall("list_branches")
+			return nil, err
+		}
+		for _, ref := range refs {
+			branches = append(branches, Branch{Name: ref.Name, SHA: ref.Sha})
+		}
+		if r == nil || r.Page.Next == 0 {
+			return branches, nil
+		}
+		opts.Page = r.Page.Next
+	}
+}
+
+// DefaultBranch finds the name of the default branch of a repository.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) DefaultBranch(ctx context.Context, repo string) (string, error) {
+	c.m.CountAPICall("default_branch")
+	var r *scm.Repository
+	res, err := c.retry(ctx, func() (res *scm.Response, err error) {
+		r, res, err = c.Client.Repositories.Find(ctx, repo)
+		return res, err
+	})
+	if res != nil && isErrorStatus(res.Status) {
+		c.m.CountFailedAPICall("default_branch")
+		return "", responseError(res, fmt.Sprintf("failed to find repo %s", repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("default_branch")
+		return "", err
+	}
+	return r.Branch, nil
+}
+
+// CreateBranch creates a branch at the commit SHA.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	c.m.CountAPICall("create_branch")
+	// The GitHub driver creates the ref as it is named, the other drivers
+	// expect the name of the branch.
+	ref := branch
+	if c.Client.Driver == scm.DriverGithub {
+		ref = "refs/heads/" + branch
+	}
+	r, err := c.call(func() (*scm.Response, error) {
+		_, r, err := c.Client.Git.CreateRef(ctx, repo, ref, sha)
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("create_branch")
+		return responseError(r, fmt.Sprintf("failed to create branch %s in repo %s", branch, repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("create_branch")
+		return err
+	}
+	return nil
+}
+
+// UpdateFile commits new contents for a file to a branch.
+//
+// The file is read from the branch first, as the current blob SHA is
+// required to update it.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) UpdateFile(ctx context.Context, repo, path, branch, message string, content []byte) error {
+	c.m.CountAPICall("update_file")
+	var current *scm.Content
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		current, r, err = c.Client.Contents.Find(ctx, repo, path, branch)
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("update_file")
+		return responseError(r, fmt.Sprintf("failed to get file %s from repo %s ref %s", path, repo, branch))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("update_file")
+		return err
+	}
+	r, err = c.call(func() (*scm.Response, error) {
+		return c.Client.Contents.Update(ctx, repo, path, &scm.ContentParams{
+			Branch:  branch,
+			Message: message,
+			Data:    content,
+			Sha:     current.Sha,
+		})
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("update_file")
+		return responseError(r, fmt.Sprintf("failed to update file %s in repo %s branch %s", path, repo, branch))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("update_file")
+		return err
+	}
+	return nil
+}
+
+// CreatePullRequest opens a pull request.
+//
+// If an HTTP error is returned by the upstream service, an error with the
+// response status code is returned.
+func (c *SCMClient) CreatePullRequest(ctx context.Context, repo string, pr *NewPullRequest) (*PullRequest, error) {
+	c.m.CountAPICall("create_pull_request")
+	var created *scm.PullRequest
+	r, err := c.call(func() (r *scm.Response, err error) {
+		created, r, err = c.Client.PullRequests.Create(ctx, repo, &scm.PullRequestInput{
+			Title: pr.Title,
+			Body:  pr.Body,
+			Head:  pr.Head,
+			Base:  pr.Base,
+		})
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("create_pull_request")
+		return nil, responseError(r, fmt.Sprintf("failed to create a pull request in repo %s", repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("create_pull_request")
+		return nil, err
+	}
+	return &PullRequest{Number: created.Number, URL: created.Link}, nil
+}
+
+// FindPullRequest finds the open pull request from the head branch, following
+// the pages of open pull requests.
+//
+// If there is no open pull request from the branch, an error with the
+// NotFound status code is returned.
+func (c *SCMClient) FindPullRequest(ctx context.Context, repo, head string) (*PullRequest, error) {
+	c.m.CountAPICall("find_pull_request")
+	opts := &scm.PullRequestListOptions{Size: 100, Open: true}
+	for {
+		var prs []*scm.PullRequest
+		r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+			prs, r, err = c.Client.PullRequests.List(ctx, repo, opts)
+			return r, err
+		})
+		if r != nil && isErrorStatus(r.Status) {
+			c.m.CountFailedAPICall("find_pull_request")
+			return nil, responseError(r, fmt.Sprintf("failed to list pull requests in repo %s", repo))
+		}
+		if err != nil {
+			c.m.CountFailedAPICall("find_pull_request")
+			return nil, err
+		}
+		for _, pr := range prs {
+			if !pr.Closed && (pr.Head.Ref == head || pr.Source == head) {
+				return &PullRequest{Number: pr.Number, URL: pr.Link}, nil
+			}
+		}
+		if r == nil || r.Page.Next == 0 {
+			return nil, SCMError{msg: fmt.Sprintf("no open pull request from branch %s in repo %s", head, repo), Status: http.StatusNotFound}
+		}
+		opts.Page = r.Page.Next
+	}
+}
+
+func convertCommit(c *scm.Commit) *Commit {
+	return &Commit{
+		SHA:         c.Sha,
+		Date:        c.Committer.Date,
+		Message:     c.Message,
+		Author:      c.Author.Name,
+		AuthorEmail: c.Author.Email,
+	}
+}
+
 func isErrorStatus(i int) bool {
 	return i >= 400
 }
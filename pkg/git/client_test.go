@@ -2,10 +2,16 @@ package git
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/jenkins-x/go-scm/scm/factory"
@@ -63,6 +69,7 @@ func TestFileContentsUnableToConnect(t *testing.T) {
 		t.Fatal(err)
 	}
 	client := New(scmClient, m)
+	client.backoff = time.Millisecond
 
 	_, err = client.FileContents(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "main")
 	if !test.MatchError(t, "connection refused", err) {
@@ -73,6 +80,54 @@ func TestFileContentsUnableToConnect(t *testing.T) {
 	}
 }
 
+func TestResolveRef(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/commits/main", "", "testdata/commit.json")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commit, err := client.ResolveRef(context.TODO(), "Codertocat/Hello-World", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Commit{
+		SHA:         "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		Date:        time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		Message:     "Update the image for the taxi service",
+		Author:      "Monalisa Octocat",
+		AuthorEmail: "support@github.com",
+	}
+	if diff := cmp.Diff(want, commit); diff != "" {
+		t.Fatalf("got a different commit back: %s\n", diff)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestResolveRefWithNotFoundResponse(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/commits/main", "", "")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	_, err = client.ResolveRef(context.TODO(), "Codertocat/Hello-World", "main")
+	if !IsNotFound(err) {
+		t.Fatalf("failed with %#v", err)
+	}
+	if m.FailedAPICalls != 1 {
+		t.Fatalf("metrics count of failed API calls, got %d, want 1", m.FailedAPICalls)
+	}
+}
+
 func makeAPIServer(t *testing.T, urlPath, ref, fixture string) *httptest.Server {
 	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		t.Helper()
@@ -99,3 +154,555 @@ func makeAPIServer(t *testing.T, urlPath, ref, fixture string) *httptest.Server
 		}
 	}))
 }
+
+func TestDefaultBranch(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World", "", "testdata/repo.json")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	branch, err := client.DefaultBranch(context.TODO(), "Codertocat/Hello-World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if branch != "main" {
+		t.Fatalf("got branch %q, want main", branch)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestCreateBranch(t *testing.T) {
+	m := metrics.NewMock()
+	var got map[string]string
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/repos/Codertocat/Hello-World/git/refs" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("failed to decode the request: %s", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"ref": %q, "object": {"sha": %q}}`, got["ref"], got["sha"])
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	if err := client.CreateBranch(context.TODO(), "Codertocat/Hello-World", "promote-taxi", "7638417db6d59f3c431d3e1f261cc637155684cd"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"ref": "refs/heads/promote-taxi", "sha": "7638417db6d59f3c431d3e1f261cc637155684cd"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect request:\n%s", diff)
+	}
+}
+
+func TestUpdateFile(t *testing.T) {
+	m := metrics.NewMock()
+	var got map[string]string
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/Codertocat/Hello-World/contents/pipelines.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			if ref := r.URL.Query().Get("ref"); ref != "promote-taxi" {
+				t.Errorf("got ref %q, want promote-taxi", ref)
+			}
+			b, err := ioutil.ReadFile("testdata/content.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = w.Write(b)
+		case http.MethodPut:
+			if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+				t.Errorf("failed to decode the request: %s", err)
+			}
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	err = client.UpdateFile(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "promote-taxi", "Update pipelines", []byte("updated\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"branch":  "promote-taxi",
+		"message": "Update pipelines",
+		"content": "dXBkYXRlZAo=",
+		"sha":     "980a0d5f19a64b4b30a87d4206aade58726b60e3",
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect request:\n%s", diff)
+	}
+}
+
+func TestUpdateFileWithNotFoundResponse(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/contents/pipelines.yaml", "promote-taxi", "")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	err = client.UpdateFile(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "promote-taxi", "Update pipelines", []byte("updated\n"))
+	if !IsNotFound(err) {
+		t.Fatalf("failed with %#v", err)
+	}
+	if m.FailedAPICalls != 1 {
+		t.Fatalf("metrics count of failed API calls, got %d, want 1", m.FailedAPICalls)
+	}
+}
+
+func TestCreatePullRequest(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/pulls", "", "testdata/pull_request.json")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	pr, err := client.CreatePullRequest(context.TODO(), "Codertocat/Hello-World", &NewPullRequest{
+		Title: "Promote taxi from dev to stage",
+		Head:  "promote-taxi",
+		Base:  "main",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PullRequest{Number: 42, URL: "https://github.com/Codertocat/Hello-World/pull/42"}
+	if diff := cmp.Diff(want, pr); diff != "" {
+		t.Fatalf("incorrect pull request:\n%s", diff)
+	}
+}
+
+func TestFindPullRequest(t *testing.T) {
+	m := metrics.NewMock()
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/Codertocat/Hello-World/pulls" {
+			t.Errorf("request path got %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<https://%s/api/v3/repos/Codertocat/Hello-World/pulls?page=2>; rel="next"`, r.Host))
+			fmt.Fprint(w, `[{"number": 41, "head": {"ref": "other"}}, {"number": 40, "state": "closed", "head": {"ref": "promote-taxi"}}]`)
+			return
+		}
+		b, err := ioutil.ReadFile("testdata/pull_request.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(w, "[%s]", b)
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	pr, err := client.FindPullRequest(context.TODO(), "Codertocat/Hello-World", "promote-taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &PullRequest{Number: 42, URL: "https://github.com/Codertocat/Hello-World/pull/42"}
+	if diff := cmp.Diff(want, pr); diff != "" {
+		t.Fatalf("incorrect pull request:\n%s", diff)
+	}
+
+	_, err = client.FindPullRequest(context.TODO(), "Codertocat/Hello-World", "promote-bus")
+	if !IsNotFound(err) {
+		t.Fatalf("got %#v, want a not found error", err)
+	}
+}
+
+func TestListDirectory(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/contents/environments/dev/apps/taxi", "main", "testdata/contents.json")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	entries, err := client.ListDirectory(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DirectoryEntry{
+		{Name: "kustomization.yaml", Path: "environments/dev/apps/taxi/kustomization.yaml", Type: "file", SHA: "3d21ec53a331a6f037a91c368710b99387d012c1"},
+		{Name: "base", Path: "environments/dev/apps/taxi/base", Type: "dir", SHA: "a84d88e7554fc1fa21bcbc4efae3c782a70d2b9d"},
+	}
+	if diff := cmp.Diff(want, entries); diff != "" {
+		t.Fatalf("incorrect entries:\n%s", diff)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestListDirectoryWithNotFoundResponse(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/contents/environments/dev/apps/taxi", "main", "")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	_, err = client.ListDirectory(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if !IsNotFound(err) {
+		t.Fatalf("failed with %#v", err)
+	}
+	if m.FailedAPICalls != 1 {
+		t.Fatalf("metrics count of failed API calls, got %d, want 1", m.FailedAPICalls)
+	}
+}
+
+func TestListCommits(t *testing.T) {
+	m := metrics.NewMock()
+	var query url.Values
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/Codertocat/Hello-World/commits" {
+			t.Errorf("request path got %s", r.URL.Path)
+		}
+		query = r.URL.Query()
+		http.ServeFile(w, r, "testdata/commits.json")
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commits, err := client.ListCommits(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Commit{
+		{
+			SHA:         "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+			Date:        time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+			Message:     "Update the image for the taxi service",
+			Author:      "Monalisa Octocat",
+			AuthorEmail: "support@github.com",
+		},
+		{
+			SHA:         "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e",
+			Date:        time.Date(2021, time.May, 14, 10, 3, 4, 0, time.UTC),
+			Message:     "Add the taxi service",
+			Author:      "Hubot",
+			AuthorEmail: "hubot@github.com",
+		},
+	}
+	if diff := cmp.Diff(want, commits); diff != "" {
+		t.Fatalf("incorrect commits:\n%s", diff)
+	}
+	if query.Get("path") != "environments/dev/apps/taxi" || query.Get("sha") != "main" || query.Get("per_page") != "100" {
+		t.Fatalf("incorrect query: %v", query)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestListCommitsFromGitLab(t *testing.T) {
+	m := metrics.NewMock()
+	var query url.Values
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/Codertocat%2FHello-World/repository/commits" {
+			t.Errorf("request path got %s", r.URL.EscapedPath())
+		}
+		query = r.URL.Query()
+		fmt.Fprint(w, `[{"id": "6dcb09b5b57875f334f61aebed695e2e4193db5e", "message": "Update the image for the taxi service", "author_name": "Monalisa Octocat", "author_email": "support@github.com", "committed_date": "2021-05-15T02:12:13Z"}]`)
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("gitlab", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commits, err := client.ListCommits(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*Commit{
+		{
+			SHA:         "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+			Date:        time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+			Message:     "Update the image for the taxi service",
+			Author:      "Monalisa Octocat",
+			AuthorEmail: "support@github.com",
+		},
+	}
+	if diff := cmp.Diff(want, commits); diff != "" {
+		t.Fatalf("incorrect commits:\n%s", diff)
+	}
+	if query.Get("path") != "environments/dev/apps/taxi" || query.Get("ref_name") != "main" || query.Get("per_page") != "100" {
+		t.Fatalf("incorrect query: %v", query)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestListCommitsFilteredByChanges(t *testing.T) {
+	m := metrics.NewMock()
+	var pages []string
+	changes := map[string]string{
+		"6dcb09b5b57875f334f61aebed695e2e4193db5e": "environments/dev/apps/taxi/kustomization.yaml",
+		"553c2077f0edc3d5dc5d17262f6aa498e69d6f8e": "README.md",
+		"7638417db6d59f3c431d3e1f261cc637155684cd": "environments/dev/apps/taxi/deployment.yaml",
+	}
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/2.0/repositories/Codertocat/Hello-World/commits/main":
+			page := r.URL.Query().Get("page")
+			pages = append(pages, page)
+			if page == "" {
+				fmt.Fprintf(w, `{"next": "https://%s/2.0/repositories/Codertocat/Hello-World/commits/main?page=2", "values": [{"hash": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}, {"hash": "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e"}]}`, r.Host)
+				return
+			}
+			fmt.Fprint(w, `{"values": [{"hash": "7638417db6d59f3c431d3e1f261cc637155684cd"}]}`)
+		case strings.HasPrefix(r.URL.Path, "/2.0/repositories/Codertocat/Hello-World/diffstat/"):
+			path := changes[strings.TrimPrefix(r.URL.Path, "/2.0/repositories/Codertocat/Hello-World/diffstat/")]
+			fmt.Fprintf(w, `{"values": [{"status": "modified", "new": {"path": %q}, "old": {"path": %q}}]}`, path, path)
+		default:
+			t.Errorf("request path got %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("bitbucketcloud", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commits, err := client.ListCommits(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, c := range commits {
+		got = append(got, c.SHA)
+	}
+	want := []string{"6dcb09b5b57875f334f61aebed695e2e4193db5e", "7638417db6d59f3c431d3e1f261cc637155684cd"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect commits:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", "2"}, pages); diff != "" {
+		t.Fatalf("incorrect pages requested:\n%s", diff)
+	}
+	if m.APICalls != 4 {
+		t.Fatalf("metrics count of API calls, got %d, want 4", m.APICalls)
+	}
+}
+
+func TestGetCommit(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/commits/6dcb09b5b57875f334f61aebed695e2e4193db5e", "", "testdata/commit.json")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commit, err := client.GetCommit(context.TODO(), "Codertocat/Hello-World", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Commit{
+		SHA:         "6dcb09b5b57875f334f61aebed695e2e4193db5e",
+		Date:        time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		Message:     "Update the image for the taxi service",
+		Author:      "Monalisa Octocat",
+		AuthorEmail: "support@github.com",
+		Files:       []string{"environments/dev/apps/taxi/kustomization.yaml"},
+	}
+	if diff := cmp.Diff(want, commit); diff != "" {
+		t.Fatalf("got a different commit back: %s\n", diff)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestGetCommitWithNotFoundResponse(t *testing.T) {
+	m := metrics.NewMock()
+	as := makeAPIServer(t, "/api/v3/repos/Codertocat/Hello-World/commits/6dcb09b5b57875f334f61aebed695e2e4193db5e", "", "")
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	_, err = client.GetCommit(context.TODO(), "Codertocat/Hello-World", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+	if !IsNotFound(err) {
+		t.Fatalf("failed with %#v", err)
+	}
+	if m.FailedAPICalls != 1 {
+		t.Fatalf("metrics count of failed API calls, got %d, want 1", m.FailedAPICalls)
+	}
+}
+
+func TestListBranches(t *testing.T) {
+	m := metrics.NewMock()
+	var pages []string
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v3/repos/Codertocat/Hello-World/branches" {
+			t.Errorf("request path got %s", r.URL.Path)
+		}
+		page := r.URL.Query().Get("page")
+		pages = append(pages, page)
+		if page == "" {
+			w.Header().Set("Link", fmt.Sprintf(`<https://%s/api/v3/repos/Codertocat/Hello-World/branches?page=2>; rel="next"`, r.Host))
+			http.ServeFile(w, r, "testdata/branches.json")
+			return
+		}
+		fmt.Fprint(w, `[{"name": "stage", "commit": {"sha": "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e"}}]`)
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	branches, err := client.ListBranches(context.TODO(), "Codertocat/Hello-World")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Branch{
+		{Name: "main", SHA: "6dcb09b5b57875f334f61aebed695e2e4193db5e"},
+		{Name: "stage", SHA: "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e"},
+	}
+	if diff := cmp.Diff(want, branches); diff != "" {
+		t.Fatalf("incorrect branches:\n%s", diff)
+	}
+	if diff := cmp.Diff([]string{"", "2"}, pages); diff != "" {
+		t.Fatalf("incorrect pages requested:\n%s", diff)
+	}
+	if m.APICalls != 1 {
+		t.Fatalf("metrics count of API calls, got %d, want 1", m.APICalls)
+	}
+}
+
+func TestListCommitsFilteredByChangesStopsAtTheCallLimit(t *testing.T) {
+	m := metrics.NewMock()
+	requests := 0
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/2.0/repositories/Codertocat/Hello-World/commits/main":
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			var values []string
+			for i := 0; i < commitsPageSize; i++ {
+				values = append(values, fmt.Sprintf(`{"hash": "%040d"}`, page*commitsPageSize+i))
+			}
+			fmt.Fprintf(w, `{"next": "https://%s/2.0/repositories/Codertocat/Hello-World/commits/main?page=%d", "values": [%s]}`, r.Host, page+1, strings.Join(values, ","))
+		case strings.HasPrefix(r.URL.Path, "/2.0/repositories/Codertocat/Hello-World/diffstat/"):
+			path := "README.md"
+			if strings.HasSuffix(r.URL.Path, "0000000000000000000000000000000000000000") {
+				path = "environments/dev/apps/taxi/kustomization.yaml"
+			}
+			fmt.Fprintf(w, `{"values": [{"status": "modified", "new": {"path": %q}, "old": {"path": %q}}]}`, path, path)
+		default:
+			t.Errorf("request path got %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	defer as.Close()
+	scmClient, err := factory.NewClient("bitbucketcloud", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+
+	commits, err := client.ListCommits(context.TODO(), "Codertocat/Hello-World", "environments/dev/apps/taxi", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(commits) != 1 || commits[0].SHA != fmt.Sprintf("%040d", 0) {
+		t.Fatalf("got commits %#v, want the commit that changed the path", commits)
+	}
+	if requests != maxCommitSearchCalls {
+		t.Fatalf("got %d requests, want %d", requests, maxCommitSearchCalls)
+	}
+}
+
+func TestGetCommitFollowsPagesOfChanges(t *testing.T) {
+	pageTests := []struct {
+		name          string
+		pages         int
+		wantFiles     int
+		wantTruncated bool
+	}{
+		{"two pages", 2, 2, false},
+		{"more pages than are listed", maxChangePages + 1, maxChangePages, true},
+	}
+
+	for _, tt := range pageTests {
+		t.Run(tt.name, func(t *testing.T) {
+			as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/2.0/repositories/Codertocat/Hello-World/commit/6dcb09b5b57875f334f61aebed695e2e4193db5e":
+					fmt.Fprint(w, `{"hash": "6dcb09b5b57875f334f61aebed695e2e4193db5e"}`)
+				case "/2.0/repositories/Codertocat/Hello-World/diffstat/6dcb09b5b57875f334f61aebed695e2e4193db5e":
+					page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+					if page == 0 {
+						page = 1
+					}
+					next := ""
+					if page < tt.pages {
+						next = fmt.Sprintf("https://%s%s?page=%d", r.Host, r.URL.Path, page+1)
+					}
+					fmt.Fprintf(w, `{"next": %q, "values": [{"status": "modified", "new": {"path": "file-%d.yaml"}}]}`, next, page)
+				default:
+					t.Errorf("request path got %s", r.URL.Path)
+					http.NotFound(w, r)
+				}
+			}))
+			defer as.Close()
+			scmClient, err := factory.NewClient("bitbucketcloud", as.URL, "", factory.Client(as.Client()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := New(scmClient, metrics.NewMock())
+
+			commit, err := client.GetCommit(context.TODO(), "Codertocat/Hello-World", "6dcb09b5b57875f334f61aebed695e2e4193db5e")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(commit.Files) != tt.wantFiles || commit.FilesTruncated != tt.wantTruncated {
+				t.Fatalf("got %d files, truncated %v, want %d files, truncated %v", len(commit.Files), commit.FilesTruncated, tt.wantFiles, tt.wantTruncated)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+const (
+	commitsPageSize = 100
+
+	// maxCommitPages is the number of pages of commits for a ref that are
+	// searched for commits that changed a path, when the upstream service
+	// can't filter the commits by the path.
+	maxCommitPages = 10
+
+	// maxCommitSearchCalls is the number of API calls, listing pages of
+	// commits and the changes in each commit, that are made to search for
+	// the commits that changed a path, the commits that were found are
+	// returned when it's reached.
+	maxCommitSearchCalls = 100
+
+	// maxChangePages is the number of pages of changes that are listed for
+	// a commit.
+	maxChangePages = 10
+)
+
+// listCommits lists the commits that changed a path.
+//
+// go-scm only passes the path to the Azure API, so GitHub and GitLab are
+// queried directly, and for the other drivers the commits for the ref are
+// filtered by the files that they changed.
+func (c *SCMClient) listCommits(ctx context.Context, repo, path, ref string) ([]*scm.Commit, error) {
+	var list func() ([]*scm.Commit, *scm.Response, error)
+	switch c.Client.Driver {
+	case scm.DriverGithub:
+		list = func() ([]*scm.Commit, *scm.Response, error) { return c.listGitHubCommits(ctx, repo, path, ref) }
+	case scm.DriverGitlab:
+		list = func() ([]*scm.Commit, *scm.Response, error) { return c.listGitLabCommits(ctx, repo, path, ref) }
+	case scm.DriverAzure:
+		list = func() ([]*scm.Commit, *scm.Response, error) {
+			return c.Client.Git.ListCommits(ctx, repo, scm.CommitListOptions{Ref: ref, Sha: ref, Path: path, Size: commitsPageSize})
+		}
+	default:
+		return c.listChangedCommits(ctx, repo, path, ref)
+	}
+	var commits []*scm.Commit
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		commits, r, err = list()
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		return nil, responseError(r, fmt.Sprintf("failed to list commits for %s in repo %s ref %s", path, repo, ref))
+	}
+	return commits, err
+}
+
+// listChangedCommits follows the pages of commits for the ref, until there
+// are commitsPageSize commits that changed the path, the files changed by
+// each commit are listed to find the commits that changed the path.
+//
+// At most maxCommitPages pages of commits are searched, with at most
+// maxCommitSearchCalls API calls, so the older commits that changed the path
+// are missing from the result if the path isn't changed often.
+func (c *SCMClient) listChangedCommits(ctx context.Context, repo, path, ref string) ([]*scm.Commit, error) {
+	filtered := []*scm.Commit{}
+	opts := scm.CommitListOptions{Ref: ref, Sha: ref, Size: commitsPageSize}
+	calls := 0
+	for page := 0; page < maxCommitPages && calls < maxCommitSearchCalls; page++ {
+		calls++
+		var commits []*scm.Commit
+		r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+			commits, r, err = c.Client.Git.ListCommits(ctx, repo, opts)
+			return r, err
+		})
+		if r != nil && isErrorStatus(r.Status) {
+			return nil, responseError(r, fmt.Sprintf("failed to list commits in repo %s ref %s", repo, ref))
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, commit := range commits {
+			if path != "" {
+				if calls == maxCommitSearchCalls {
+					return filtered, nil
+				}
+				calls++
+			}
+			changed, err := c.changesPath(ctx, repo, commit.Sha, path)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				continue
+			}
+			filtered = append(filtered, commit)
+			if len(filtered) == commitsPageSize {
+				return filtered, nil
+			}
+		}
+		if r == nil || r.Page.Next == 0 {
+			break
+		}
+		opts.Page = r.Page.Next
+	}
+	return filtered, nil
+}
+
+// changesPath returns true if the commit changed the path, or a file within
+// it, an empty path is changed by every commit.
+func (c *SCMClient) changesPath(ctx context.Context, repo, sha, path string) (bool, error) {
+	if path == "" {
+		return true, nil
+	}
+	c.m.CountAPICall("list_changes")
+	var changes []*scm.Change
+	r, err := c.retry(ctx, func() (r *scm.Response, err error) {
+		changes, r, err = c.Client.Git.ListChanges(ctx, repo, sha, &scm.ListOptions{Size: commitsPageSize})
+		return r, err
+	})
+	if r != nil && isErrorStatus(r.Status) {
+		c.m.CountFailedAPICall("list_changes")
+		return false, responseError(r, fmt.Sprintf("failed to list the changes in commit %s in repo %s", sha, repo))
+	}
+	if err != nil {
+		c.m.CountFailedAPICall("list_changes")
+		return false, err
+	}
+	return changesPath(changes, path), nil
+}
+
+// githubCommit is the subset of a commit in the GitHub API that is used.
+type githubCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Author struct {
+			Name  string    `json:"name"`
+			Email string    `json:"email"`
+			Date  time.Time `json:"date"`
+		} `json:"author"`
+		Committer struct {
+			Date time.Time `json:"date"`
+		} `json:"committer"`
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+func (c *SCMClient) listGitHubCommits(ctx context.Context, repo, path, ref string) ([]*scm.Commit, *scm.Response, error) {
+	params := url.Values{
+		"sha":      []string{ref},
+		"per_page": []string{fmt.Sprint(commitsPageSize)},
+	}
+	if path != "" {
+		params.Set("path", path)
+	}
+	r, err := c.Client.Do(ctx, &scm.Request{
+		Method: http.MethodGet,
+		Path:   fmt.Sprintf("repos/%s/commits?%s", repo, params.Encode()),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Body.Close()
+	// The driver parses the rate limit for its own requests, but not for
+	// requests made directly with the client.
+	r.Rate.Limit, _ = strconv.Atoi(r.Header.Get("X-RateLimit-Limit"))
+	r.Rate.Remaining, _ = strconv.Atoi(r.Header.Get("X-RateLimit-Remaining"))
+	r.Rate.Reset, _ = strconv.ParseInt(r.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if isErrorStatus(r.Status) {
+		return nil, r, nil
+	}
+	var out []githubCommit
+	if err := json.NewDecoder(r.Body).Decode(&out); err != nil {
+		return nil, r, err
+	}
+	commits := make([]*scm.Commit, len(out))
+	for i, v := range out {
+		commits[i] = &scm.Commit{
+			Sha:       v.SHA,
+			Message:   v.Commit.Message,
+			Author:    scm.Signature{Name: v.Commit.Author.Name, Email: v.Commit.Author.Email, Date: v.Commit.Author.Date},
+			Committer: scm.Signature{Date: v.Commit.Committer.Date},
+		}
+	}
+	return commits, r, nil
+}
+
+// gitlabCommit is the subset of a commit in the GitLab API that is used.
+type gitlabCommit struct {
+	ID            string    `json:"id"`
+	Message       string    `json:"message"`
+	AuthorName    string    `json:"author_name"`
+	AuthorEmail   string    `json:"author_email"`
+	AuthoredDate  time.Time `json:"authored_date"`
+	CommittedDate time.Time `json:"committed_date"`
+}
+
+func (c *SCMClient) listGitLabCommits(ctx context.Context, repo, path, ref string) ([]*scm.Commit, *scm.Response, error) {
+	params := url.Values{
+		"ref_name": []string{ref},
+		"per_page": []string{fmt.Sprint(commitsPageSize)},
+	}
+	if path != "" {
+		params.Set("path", path)
+	}
+	r, err := c.Client.Do(ctx, &scm.Request{
+		Method: http.MethodGet,
+		Path:   fmt.Sprintf("api/v4/projects/%s/repository/commits?%s", url.PathEscape(repo), params.Encode()),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Body.Close()
+	r.Rate.Limit, _ = strconv.Atoi(r.Header.Get("RateLimit-Limit"))
+	r.Rate.Remaining, _ = strconv.Atoi(r.Header.Get("RateLimit-Remaining"))
+	r.Rate.Reset, _ = strconv.ParseInt(r.Header.Get("RateLimit-Reset"), 10, 64)
+	if isErrorStatus(r.Status) {
+		return nil, r, nil
+	}
+	var out []gitlabCommit
+	if err := json.NewDecoder(r.Body).Decode(&out); err != nil {
+		return nil, r, err
+	}
+	commits := make([]*scm.Commit, len(out))
+	for i, v := range out {
+		commits[i] = &scm.Commit{
+			Sha:       v.ID,
+			Message:   v.Message,
+			Author:    scm.Signature{Name: v.AuthorName, Email: v.AuthorEmail, Date: v.AuthoredDate},
+			Committer: scm.Signature{Date: v.CommittedDate},
+		}
+	}
+	return commits, r, nil
+}
+
+// changesPath returns true if any of the changes are to the path, or to a
+// file within it.
+func changesPath(changes []*scm.Change, path string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, change := range changes {
+		for _, p := range []string{change.Path, change.PreviousPath} {
+			if p == path || strings.HasPrefix(p, path+"/") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+func TestChangesPath(t *testing.T) {
+	pathTests := []struct {
+		path    string
+		changes []*scm.Change
+		want    bool
+	}{
+		{"environments/dev/apps/taxi", []*scm.Change{{Path: "environments/dev/apps/taxi/kustomization.yaml"}}, true},
+		{"environments/dev/apps/taxi/", []*scm.Change{{Path: "environments/dev/apps/taxi/kustomization.yaml"}}, true},
+		{"environments/dev/apps/taxi", []*scm.Change{{Path: "environments/dev/apps/taxi-v2/kustomization.yaml"}}, false},
+		{"environments/dev/apps/taxi", []*scm.Change{{Path: "README.md", PreviousPath: "environments/dev/apps/taxi/README.md", Renamed: true}}, true},
+		{"pipelines.yaml", []*scm.Change{{Path: "pipelines.yaml"}}, true},
+		{"pipelines.yaml", []*scm.Change{}, false},
+	}
+
+	for _, tt := range pathTests {
+		if got := changesPath(tt.changes, tt.path); got != tt.want {
+			t.Errorf("changesPath(%q) got %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package git
 
-import "net/http"
+import (
+	"net/http"
+	"time"
+)
 
 // IsNotFound returns true if the error represents a NotFound response from an
 // upstream service.
@@ -17,3 +20,13 @@ type SCMError struct {
 func (s SCMError) Error() string {
 	return s.msg
 }
+
+// RateLimitError is returned when the rate limit of the upstream service has
+// been exceeded.
+//
+// RetryAfter is how long until requests can be made again, it is zero if the
+// upstream service didn't report when the rate limit resets.
+type RateLimitError struct {
+	SCMError
+	RetryAfter time.Duration
+}
@@ -0,0 +1,201 @@
+package git
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/transport"
+	"golang.org/x/oauth2"
+	"sigs.k8s.io/yaml"
+)
+
+// supportedDrivers are the go-scm drivers that can be configured for a host.
+var supportedDrivers = map[string]bool{
+	"github":          true,
+	"gitlab":          true,
+	"bitbucketserver": true,
+	"gitea":           true,
+	"azure":           true,
+}
+
+// publicHosts are the hosts where the driver's default server URL is used if
+// none is configured.
+var publicHosts = map[string]string{
+	"github.com": "github",
+	"gitlab.com": "gitlab",
+}
+
+// Hosts maps the host in a repository URL to the configuration used to
+// access it.
+type Hosts map[string]HostConfig
+
+// HostConfig configures the driver used for a Git host.
+//
+// In a hosts file, a host can be mapped directly to the name of a driver, or
+// to an object with these fields.
+type HostConfig struct {
+	// Driver is the go-scm driver used to access the host.
+	Driver string `json:"driver"`
+	// ServerURL is the base URL for the API, if this is not provided, the
+	// driver's default is used for the public hosts, otherwise it's the root
+	// of the host.
+	ServerURL string `json:"serverURL,omitempty"`
+	// CAFile is the filename of a PEM bundle of certificates to verify the
+	// host's certificate with, relative paths are relative to the hosts file.
+	CAFile string `json:"caFile,omitempty"`
+
+	transport http.RoundTripper
+	caBundle  []byte
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (h *HostConfig) UnmarshalJSON(b []byte) error {
+	var driver string
+	if err := json.Unmarshal(b, &driver); err == nil {
+		*h = HostConfig{Driver: driver}
+		return nil
+	}
+	type plain HostConfig
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode((*plain)(h))
+}
+
+// LoadHosts parses a YAML mapping of hosts to drivers from a file, and reads
+// any CA bundles that are configured for the hosts.
+func LoadHosts(filename string) (Hosts, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the Git hosts: %w", err)
+	}
+	var hosts Hosts
+	if err := yaml.UnmarshalStrict(b, &hosts); err != nil {
+		return nil, fmt.Errorf("failed to parse the Git hosts in %s: %w", filename, err)
+	}
+	for _, host := range hosts.names() {
+		config := hosts[host]
+		if err := config.validate(); err != nil {
+			return nil, fmt.Errorf("invalid Git host %s in %s: %w", host, filename, err)
+		}
+		if config.CAFile != "" {
+			caFile := config.CAFile
+			if !filepath.IsAbs(caFile) {
+				caFile = filepath.Join(filepath.Dir(filename), caFile)
+			}
+			config.caBundle, err = os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Git host %s in %s: failed to read the CA bundle: %w", host, filename, err)
+			}
+			config.transport, err = transportWithCABundle(caFile, config.caBundle)
+			if err != nil {
+				return nil, fmt.Errorf("invalid Git host %s in %s: %w", host, filename, err)
+			}
+		}
+		hosts[host] = config
+	}
+	return hosts, nil
+}
+
+// HTTPClient returns a client for requests to the host, which verifies the
+// host's certificate with its CA bundle, if one is configured.
+func (h Hosts) HTTPClient(host string) *http.Client {
+	if config, ok := h[host]; ok && config.transport != nil {
+		return &http.Client{Transport: config.transport}
+	}
+	return http.DefaultClient
+}
+
+// CABundle returns the PEM bundle of certificates that the host's certificate
+// is verified with, or nil if none is configured.
+func (h Hosts) CABundle(host string) []byte {
+	return h[host].caBundle
+}
+
+func (h Hosts) names() []string {
+	names := make([]string, 0, len(h))
+	for k := range h {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (h HostConfig) validate() error {
+	if !supportedDrivers[h.Driver] {
+		return fmt.Errorf("unsupported driver %q, must be one of %s", h.Driver, strings.Join(sortedKeys(supportedDrivers), ", "))
+	}
+	if h.CAFile != "" && h.Driver == "gitea" {
+		return errors.New("a CA bundle can't be configured for the gitea driver")
+	}
+	return nil
+}
+
+// serverURL returns the URL that the driver for the host is created with.
+func (h HostConfig) serverURL(host string) string {
+	if h.ServerURL != "" {
+		return h.ServerURL
+	}
+	if publicHosts[host] == h.Driver {
+		return ""
+	}
+	return "https://" + host
+}
+
+// configureTransport configures the client created by go-scm to use the
+// transport for the host.
+//
+// go-scm wraps the default transport to authenticate requests, so the
+// transport is configured as the base of the authenticating transport.
+func (h HostConfig) configureTransport(c *scm.Client) error {
+	if h.transport == nil {
+		return nil
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{Transport: h.transport}
+		return nil
+	}
+	switch t := c.Client.Transport.(type) {
+	case nil:
+		c.Client.Transport = h.transport
+	case *oauth2.Transport:
+		t.Base = h.transport
+	case *transport.PrivateToken:
+		t.Base = h.transport
+	case *transport.Authorization:
+		t.Base = h.transport
+	case *transport.Custom:
+		t.Base = h.transport
+	default:
+		return fmt.Errorf("unable to configure the CA bundle for a %T", t)
+	}
+	return nil
+}
+
+func transportWithCABundle(filename string, b []byte) (http.RoundTripper, error) {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("failed to parse any certificates from the CA bundle in %s", filename)
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return t, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
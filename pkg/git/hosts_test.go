@@ -0,0 +1,107 @@
+package git
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/redhat-developer/gitops-backend/pkg/metrics"
+)
+
+func TestLoadHosts(t *testing.T) {
+	hosts, err := LoadHosts("testdata/drivers.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Hosts{
+		"gh.example.com":  {Driver: "github"},
+		"gl.example.com":  {Driver: "gitlab"},
+		"git.example.com": {Driver: "gitlab", ServerURL: "https://git.example.com/gitlab/"},
+	}
+	if diff := cmp.Diff(want, hosts, cmpopts.IgnoreUnexported(HostConfig{})); diff != "" {
+		t.Fatalf("incorrect hosts:\n%s", diff)
+	}
+}
+
+func TestLoadHostsWithInvalidHosts(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"unknown driver", "scm.example.com: svn\n", `unsupported driver "svn"`},
+		{"missing driver", "scm.example.com:\n  serverURL: https://scm.example.com\n", `unsupported driver ""`},
+		{"unknown field", "scm.example.com:\n  driver: github\n  token: secret\n", "unknown field"},
+		{"gitea CA bundle", "scm.example.com:\n  driver: gitea\n  caFile: ca.crt\n", "can't be configured for the gitea driver"},
+		{"missing CA bundle", "scm.example.com:\n  driver: github\n  caFile: missing.crt\n", "failed to read the CA bundle"},
+		{"invalid CA bundle", "scm.example.com:\n  driver: github\n  caFile: hosts.yaml\n", "failed to parse any certificates"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "hosts.yaml")
+			if err := os.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadHosts(filename)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCreateWithCABundle(t *testing.T) {
+	var auth string
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization") + r.Header.Get("Private-Token")
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.crt")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0600); err != nil {
+		t.Fatal(err)
+	}
+	host := strings.TrimPrefix(ts.URL, "https://")
+
+	for _, driver := range []string{"github", "gitlab", "bitbucketserver"} {
+		t.Run(driver, func(t *testing.T) {
+			filename := filepath.Join(dir, "hosts.yaml")
+			content := host + ":\n  driver: " + driver + "\n  serverURL: " + ts.URL + "\n  caFile: ca.crt\n"
+			if err := os.WriteFile(filename, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			hosts, err := LoadHosts(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(ca, hosts.CABundle(host)); diff != "" {
+				t.Fatalf("incorrect CA bundle:\n%s", diff)
+			}
+			client, err := NewClientFactory(metrics.NewMock(), hosts).Create(ts.URL+"/myorg/myrepo.git", "test-token")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auth = ""
+			_, err = client.FileContents(context.TODO(), "myorg/myrepo", "pipelines.yaml", "main")
+			if !IsNotFound(err) {
+				t.Fatalf("got error %v, want not found", err)
+			}
+			if !strings.Contains(auth, "test-token") {
+				t.Fatalf("request was not authenticated, got %q", auth)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package git
 
 import (
 	"context"
+	"time"
 )
 
 // ClientFactory is an interface for creating SCM clients based on the URL
@@ -15,4 +16,85 @@ type ClientFactory interface {
 type SCM interface {
 	// FileContents returns the contents of a file within a repo.
 	FileContents(ctx context.Context, repo, path, ref string) ([]byte, error)
+
+	// ResolveRef returns the commit that a branch, tag or commit SHA refers
+	// to.
+	ResolveRef(ctx context.Context, repo, ref string) (*Commit, error)
+
+	// ListDirectory returns the files and directories in a directory within
+	// a repo.
+	ListDirectory(ctx context.Context, repo, path, ref string) ([]DirectoryEntry, error)
+
+	// ListCommits returns the most recent commits that changed a path, from
+	// the ref, the result can be partial if the upstream service can't
+	// filter commits by the path.
+	ListCommits(ctx context.Context, repo, path, ref string) ([]*Commit, error)
+
+	// GetCommit returns a commit, with the files that it changed.
+	GetCommit(ctx context.Context, repo, sha string) (*Commit, error)
+
+	// ListBranches returns the branches in a repo.
+	ListBranches(ctx context.Context, repo string) ([]Branch, error)
+
+	// DefaultBranch returns the name of the default branch of a repo.
+	DefaultBranch(ctx context.Context, repo string) (string, error)
+
+	// CreateBranch creates a branch in a repo at the commit SHA.
+	CreateBranch(ctx context.Context, repo, branch, sha string) error
+
+	// UpdateFile commits new contents for an existing file to a branch.
+	UpdateFile(ctx context.Context, repo, path, branch, message string, content []byte) error
+
+	// CreatePullRequest opens a pull request in a repo.
+	CreatePullRequest(ctx context.Context, repo string, pr *NewPullRequest) (*PullRequest, error)
+
+	// FindPullRequest returns the open pull request from the head branch in a
+	// repo, a not found error is returned if there isn't one.
+	FindPullRequest(ctx context.Context, repo, head string) (*PullRequest, error)
+}
+
+// Commit is a commit in a repository.
+//
+// The Files are only populated by GetCommit.
+type Commit struct {
+	SHA         string
+	Date        time.Time
+	Message     string
+	Author      string
+	AuthorEmail string
+	Files       []string
+	// FilesTruncated is true if the commit changed more files than are
+	// listed in Files.
+	FilesTruncated bool
+}
+
+// DirectoryEntry is a file or directory in a repository.
+type DirectoryEntry struct {
+	Name string
+	Path string
+	// Type is "file" or "dir", submodules and symlinks are reported with the
+	// type from the upstream service.
+	Type string
+	SHA  string
+}
+
+// Branch is a branch in a repository, and the commit that it points to.
+type Branch struct {
+	Name string
+	SHA  string
+}
+
+// NewPullRequest is a pull request to merge the Head branch into the Base
+// branch.
+type NewPullRequest struct {
+	Title string
+	Body  string
+	Head  string
+	Base  string
+}
+
+// PullRequest is a pull request that has been opened.
+type PullRequest struct {
+	Number int
+	URL    string
 }
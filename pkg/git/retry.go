@@ -0,0 +1,132 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm"
+)
+
+const (
+	// defaultRetries is the number of times that a request that failed with a
+	// transient error is retried.
+	defaultRetries = 3
+
+	// defaultBackoff is the delay before the first retry, it doubles for each
+	// retry after that.
+	defaultBackoff = 200 * time.Millisecond
+)
+
+// retry calls f, and calls it again if it fails with a network error or a
+// server error from the upstream service, waiting longer before each retry.
+//
+// Only requests that can safely be made more than once should be retried.
+func (c *SCMClient) retry(ctx context.Context, f func() (*scm.Response, error)) (*scm.Response, error) {
+	for attempt := 0; ; attempt++ {
+		r, err := c.call(f)
+		if attempt >= c.retries || !isTransient(ctx, r, err) {
+			return r, err
+		}
+		select {
+		case <-ctx.Done():
+			return r, err
+		case <-time.After(jitter(c.backoff << attempt)):
+		}
+	}
+}
+
+// call calls f, and records the rate limit in the response.
+func (c *SCMClient) call(f func() (*scm.Response, error)) (*scm.Response, error) {
+	r, err := f()
+	if r != nil && r.Rate.Limit > 0 {
+		c.m.SetRateLimitRemaining(c.Client.BaseURL.Host, r.Rate.Remaining)
+	}
+	return r, err
+}
+
+// responseError returns the error for an error response from the upstream
+// service, this is a RateLimitError if the rate limit has been exceeded.
+func responseError(r *scm.Response, msg string) error {
+	err := SCMError{msg: msg, Status: r.Status}
+	retryAfter, limited := rateLimited(r)
+	if !limited {
+		return err
+	}
+	return RateLimitError{SCMError: SCMError{msg: fmt.Sprintf("%s: rate limit exceeded", msg), Status: r.Status}, RetryAfter: retryAfter}
+}
+
+// rateLimited returns true if the response is because the rate limit was
+// exceeded, and how long until the limit resets, if the upstream service
+// reported it.
+//
+// GitHub reports exceeded rate limits as a 403 Forbidden, with no remaining
+// requests, or a Retry-After header for its secondary rate limits.
+func rateLimited(r *scm.Response) (time.Duration, bool) {
+	retryAfter := r.Header.Get("Retry-After")
+	switch {
+	case r.Status == http.StatusTooManyRequests:
+	case r.Status == http.StatusForbidden && (retryAfter != "" || (r.Rate.Limit > 0 && r.Rate.Remaining == 0)):
+	default:
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if r.Rate.Reset > 0 {
+		if d := time.Until(time.Unix(r.Rate.Reset, 0)); d > 0 {
+			return d, true
+		}
+	}
+	return 0, true
+}
+
+// isTransient returns true if the request failed with an error that may not
+// happen if it's made again.
+//
+// Without a response, only network errors, and connections that were closed
+// or reset before the response was read, are transient.
+func isTransient(ctx context.Context, r *scm.Response, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	if r == nil {
+		return isNetworkError(err)
+	}
+	switch r.Status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func isNetworkError(err error) bool {
+	// The HTTP client wraps all errors in a url.Error, which is a net.Error,
+	// including errors like invalid certificates that aren't transient.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// jitter returns a random duration between half of d and d, so that clients
+// that failed at the same time don't retry at the same time.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return 0
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
@@ -0,0 +1,204 @@
+package git
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jenkins-x/go-scm/scm/factory"
+
+	"github.com/redhat-developer/gitops-backend/pkg/metrics"
+)
+
+func TestFileContentsRetriesServerErrors(t *testing.T) {
+	m := metrics.NewMock()
+	requests := 0
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			http.Error(w, "unavailable", http.StatusBadGateway)
+			return
+		}
+		b, err := os.ReadFile("testdata/content.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4997")
+		_, _ = w.Write(b)
+	}))
+	defer as.Close()
+	client := makeRetryingClient(t, as, m)
+
+	body, err := client.FileContents(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "testing service\n" {
+		t.Fatalf("got body %q", body)
+	}
+	if requests != 3 {
+		t.Fatalf("got %d requests, want 3", requests)
+	}
+	if m.APICalls != 1 || m.FailedAPICalls != 0 {
+		t.Fatalf("metrics count of API calls, got %d and %d failed, want 1 and 0", m.APICalls, m.FailedAPICalls)
+	}
+	if got := m.RateLimitRemaining[hostOf(t, as)]; got != 4997 {
+		t.Fatalf("got remaining rate limit %d, want 4997", got)
+	}
+}
+
+func TestFileContentsGivesUpAfterRetries(t *testing.T) {
+	m := metrics.NewMock()
+	requests := 0
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer as.Close()
+	client := makeRetryingClient(t, as, m)
+
+	_, err := client.FileContents(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "main")
+	var scmErr SCMError
+	if !errors.As(err, &scmErr) || scmErr.Status != http.StatusServiceUnavailable {
+		t.Fatalf("got error %#v, want a service unavailable error", err)
+	}
+	if requests != defaultRetries+1 {
+		t.Fatalf("got %d requests, want %d", requests, defaultRetries+1)
+	}
+}
+
+func TestFileContentsWithExceededRateLimit(t *testing.T) {
+	rateLimitTests := []struct {
+		name    string
+		status  int
+		headers map[string]string
+		want    time.Duration
+	}{
+		{
+			"no remaining requests",
+			http.StatusForbidden,
+			map[string]string{
+				"X-RateLimit-Limit":     "5000",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     fmt.Sprint(time.Now().Add(time.Minute).Unix()),
+			},
+			time.Minute,
+		},
+		{"secondary rate limit", http.StatusForbidden, map[string]string{"Retry-After": "30"}, 30 * time.Second},
+		{"too many requests", http.StatusTooManyRequests, nil, 0},
+	}
+
+	for _, tt := range rateLimitTests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.NewMock()
+			requests := 0
+			as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for k, v := range tt.headers {
+					w.Header().Set(k, v)
+				}
+				http.Error(w, `{"message": "API rate limit exceeded"}`, tt.status)
+			}))
+			defer as.Close()
+			client := makeRetryingClient(t, as, m)
+
+			_, err := client.FileContents(context.TODO(), "Codertocat/Hello-World", "pipelines.yaml", "main")
+			var rateLimitErr RateLimitError
+			if !errors.As(err, &rateLimitErr) {
+				t.Fatalf("got error %#v, want a rate limit error", err)
+			}
+			if d := tt.want - rateLimitErr.RetryAfter; d < 0 || d > 5*time.Second {
+				t.Fatalf("got retry after %s, want %s", rateLimitErr.RetryAfter, tt.want)
+			}
+			if requests != 1 {
+				t.Fatalf("got %d requests, want 1", requests)
+			}
+			if m.FailedAPICalls != 1 {
+				t.Fatalf("metrics count of failed API calls, got %d, want 1", m.FailedAPICalls)
+			}
+		})
+	}
+}
+
+func TestCreateBranchIsNotRetried(t *testing.T) {
+	m := metrics.NewMock()
+	requests := 0
+	as := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusBadGateway)
+	}))
+	defer as.Close()
+	client := makeRetryingClient(t, as, m)
+
+	err := client.CreateBranch(context.TODO(), "Codertocat/Hello-World", "promote-taxi", "7638417db6d59f3c431d3e1f261cc637155684cd")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if requests != 1 {
+		t.Fatalf("got %d requests, want 1", requests)
+	}
+}
+
+func TestIsTransient(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	transientTests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want bool
+	}{
+		{"connection refused", context.Background(), &url.Error{Op: "Get", URL: "https://github.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", context.Background(), &url.Error{Op: "Get", URL: "https://github.com", Err: syscall.ECONNRESET}, true},
+		{"unexpected EOF", context.Background(), &url.Error{Op: "Get", URL: "https://github.com", Err: io.ErrUnexpectedEOF}, true},
+		{"invalid certificate", context.Background(), &url.Error{Op: "Get", URL: "https://github.com", Err: x509.UnknownAuthorityError{}}, false},
+		{"invalid response", context.Background(), errors.New("invalid character '<' looking for beginning of value"), false},
+		{"cancelled", cancelled, &url.Error{Op: "Get", URL: "https://github.com", Err: syscall.ECONNRESET}, false},
+	}
+	for _, tt := range transientTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransient(tt.ctx, nil, tt.err); got != tt.want {
+				t.Fatalf("isTransient() got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		if d := jitter(time.Second); d < 500*time.Millisecond || d > time.Second {
+			t.Fatalf("got %s, want between 500ms and 1s", d)
+		}
+	}
+}
+
+func makeRetryingClient(t *testing.T, as *httptest.Server, m metrics.Interface) *SCMClient {
+	t.Helper()
+	scmClient, err := factory.NewClient("github", as.URL, "", factory.Client(as.Client()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := New(scmClient, m)
+	client.backoff = time.Millisecond
+	return client
+}
+
+func hostOf(t *testing.T, as *httptest.Server) string {
+	t.Helper()
+	u, err := url.Parse(as.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Host
+}
@@ -12,11 +12,15 @@ import (
 // create clients based on go-scm.
 type SCMClientFactory struct {
 	metrics metrics.Interface
+	hosts   Hosts
 }
 
 // NewClientFactory creates and returns an SCMClientFactory.
-func NewClientFactory(m metrics.Interface) *SCMClientFactory {
-	return &SCMClientFactory{metrics: m}
+//
+// The driver for a repository is found in the hosts, and falls back to the
+// go-scm defaults for hosts that aren't configured.
+func NewClientFactory(m metrics.Interface, hosts Hosts) *SCMClientFactory {
+	return &SCMClientFactory{metrics: m, hosts: hosts}
 }
 
 func (s *SCMClientFactory) Create(url, token string) (SCM, error) {
@@ -24,14 +28,21 @@ func (s *SCMClientFactory) Create(url, token string) (SCM, error) {
 	if err != nil {
 		return nil, err
 	}
-	driver, err := scmfactory.DefaultIdentifier.Identify(host)
-	if err != nil {
-		return nil, err
+	config, ok := s.hosts[host]
+	if !ok {
+		driver, err := scmfactory.DefaultIdentifier.Identify(host)
+		if err != nil {
+			return nil, err
+		}
+		config = HostConfig{Driver: driver}
 	}
-	scmClient, err := scmfactory.NewClient(driver, "", token)
+	scmClient, err := scmfactory.NewClient(config.Driver, config.serverURL(host), token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create a git driver: %w", err)
 	}
+	if err := config.configureTransport(scmClient); err != nil {
+		return nil, fmt.Errorf("failed to create a git driver: %w", err)
+	}
 	return New(scmClient, s.metrics), nil
 }
 
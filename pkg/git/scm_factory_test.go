@@ -11,18 +11,24 @@ import (
 var _ ClientFactory = (*SCMClientFactory)(nil)
 
 func TestSCMFactory(t *testing.T) {
-	// TODO non-standard GitHub and GitLab hosts!
-	// Probably need to return the serverURL part for the scm factory too.
+	hosts, err := LoadHosts("testdata/drivers.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
 	urlTests := []struct {
-		gitURL  string
-		want    scm.Driver
-		wantErr string
+		gitURL      string
+		want        scm.Driver
+		wantBaseURL string
+		wantErr     string
 	}{
-		{"https://github.com/myorg/myrepo.git", scm.DriverGithub, ""},
-		{"https://gitlab.com/myorg/myrepo/myother.git", scm.DriverGitlab, ""},
-		{"https://scm.example.com/myorg/myother.git", scm.DriverUnknown, "unable to identify driver"},
+		{"https://github.com/myorg/myrepo.git", scm.DriverGithub, "https://api.github.com/", ""},
+		{"https://gitlab.com/myorg/myrepo/myother.git", scm.DriverGitlab, "https://gitlab.com/", ""},
+		{"https://gh.example.com/myorg/myrepo.git", scm.DriverGithub, "https://gh.example.com/api/v3/", ""},
+		{"https://gl.example.com/myorg/myrepo.git", scm.DriverGitlab, "https://gl.example.com/", ""},
+		{"https://git.example.com/myorg/myrepo.git", scm.DriverGitlab, "https://git.example.com/gitlab/", ""},
+		{"https://scm.example.com/myorg/myother.git", scm.DriverUnknown, "", "unable to identify driver"},
 	}
-	factory := NewClientFactory(metrics.NewMock())
+	factory := NewClientFactory(metrics.NewMock(), hosts)
 	for _, tt := range urlTests {
 		t.Run(tt.gitURL, func(rt *testing.T) {
 			client, err := factory.Create(tt.gitURL, "test-token")
@@ -39,7 +45,9 @@ func TestSCMFactory(t *testing.T) {
 			if gc.Client.Driver != tt.want {
 				rt.Errorf("got %s, want %s", gc.Client.Driver, tt.want)
 			}
+			if u := gc.Client.BaseURL.String(); u != tt.wantBaseURL {
+				rt.Errorf("got base URL %s, want %s", u, tt.wantBaseURL)
+			}
 		})
 	}
-
 }
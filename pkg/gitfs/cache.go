@@ -0,0 +1,254 @@
+package gitfs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/storage/memory"
+	gossh "golang.org/x/crypto/ssh"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-developer/gitops-backend/internal/cache"
+)
+
+// RepositoryCache keeps clones of repositories in memory, and fetches the
+// changes to update them, rather than cloning them again.
+//
+// Repositories are cached by their URL and the identity of the credentials
+// they were cloned with, the username or SSH key, rather than the token, so
+// that the clone is kept when a token is rotated. Every read fetches with the
+// credentials it was given, so a clone is never read without the host
+// checking them.
+//
+// The least recently used repositories are evicted when the cache is full, or
+// the objects in the cached repositories are more than the maximum bytes.
+//
+// It is safe for concurrent use.
+type RepositoryCache struct {
+	mu            sync.Mutex
+	repos         *cache.LRU[string, repository]
+	maxBytes      int64
+	sizes         map[string]int64
+	newRepository func(opts *git.CloneOptions) repository
+}
+
+// repository is a copy of a remote repository that is kept up to date.
+type repository interface {
+	// read updates the repository with the credentials, and calls f with the
+	// files at the revision.
+	read(auth transport.AuthMethod, revision string, f func(fs.FileSystem) error) error
+	// size returns the number of bytes in the objects that are held.
+	size() int64
+}
+
+// cachedRepository is a clone of a repository, the lock is held for writing
+// while it's cloned or fetched, and for reading while files are read from it.
+type cachedRepository struct {
+	mu   sync.RWMutex
+	opts *git.CloneOptions
+	repo *git.Repository
+	// storage holds the objects in the clone.
+	storage *memory.Storage
+	// head is the remote-tracking reference for the branch that was cloned.
+	head plumbing.ReferenceName
+}
+
+// NewRepositoryCache creates and returns a RepositoryCache that holds up to
+// size repositories, and up to maxBytes of objects in them.
+//
+// If maxBytes is zero, only the number of repositories is limited.
+func NewRepositoryCache(size int, maxBytes int64) *RepositoryCache {
+	return newRepositoryCache(size, maxBytes, func(opts *git.CloneOptions) repository {
+		return &cachedRepository{opts: opts}
+	})
+}
+
+// NewSparseRepositoryCache creates and returns a RepositoryCache that holds up
+// to size repositories, and up to maxBytes of objects in them, which are
+// fetched as they're read, rather than cloned, see NewSparseFromOptions.
+//
+// The directories and files that have been read are kept, so that they're
+// only fetched once.
+func NewSparseRepositoryCache(size int, maxBytes int64) *RepositoryCache {
+	return newRepositoryCache(size, maxBytes, func(opts *git.CloneOptions) repository {
+		return newSparseRepository(opts)
+	})
+}
+
+func newRepositoryCache(size int, maxBytes int64, newRepository func(*git.CloneOptions) repository) *RepositoryCache {
+	return &RepositoryCache{
+		repos:    cache.New[string, repository](size, 0),
+		maxBytes: maxBytes,
+		sizes:    make(map[string]int64),
+		newRepository: func(opts *git.CloneOptions) repository {
+			// The options are kept with the repository, and the credentials
+			// are replaced when they change.
+			o := *opts
+			return newRepository(&o)
+		},
+	}
+}
+
+// Read clones or updates the repository in the options, and calls f with the
+// files at the revision, which can be a branch, tag or commit SHA, or empty
+// for the head of the cloned branch.
+//
+// The files must not be used after f returns.
+func (c *RepositoryCache) Read(opts *git.CloneOptions, revision string, f func(fs.FileSystem) error) error {
+	key := repositoryKey(opts)
+	r := c.repository(key, opts)
+	err := r.read(opts.Auth, revision, f)
+	c.trim(key, r.size())
+	return err
+}
+
+// Len returns the number of cached repositories.
+func (c *RepositoryCache) Len() int {
+	return c.repos.Len()
+}
+
+func (c *RepositoryCache) repository(key string, opts *git.CloneOptions) repository {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.repos.Get(key)
+	if !ok {
+		r = c.newRepository(opts)
+		c.repos.Add(key, r)
+	}
+	return r
+}
+
+// trim records the size of the repository that was read, and evicts the
+// least recently used repositories until the objects in the cache fit in the
+// maximum bytes.
+//
+// The repository that was read is not evicted, even if it alone is larger.
+func (c *RepositoryCache) trim(key string, size int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	keys := c.repos.Keys()
+	cached := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		cached[k] = true
+	}
+	// Repositories that were evicted by the LRU are forgotten.
+	for k := range c.sizes {
+		if !cached[k] {
+			delete(c.sizes, k)
+		}
+	}
+	if cached[key] {
+		c.sizes[key] = size
+	}
+	var total int64
+	for _, k := range keys {
+		total += c.sizes[k]
+	}
+	for i := len(keys) - 1; i >= 0 && c.maxBytes > 0 && total > c.maxBytes; i-- {
+		if keys[i] == key {
+			continue
+		}
+		total -= c.sizes[keys[i]]
+		c.repos.Remove(keys[i])
+		delete(c.sizes, keys[i])
+	}
+}
+
+func (r *cachedRepository) read(auth transport.AuthMethod, revision string, f func(fs.FileSystem) error) error {
+	tree, err := r.update(auth, revision)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return f(New(tree))
+}
+
+// update clones the repository if it hasn't been cloned, or fetches the
+// changes, and returns the tree at the revision.
+func (r *cachedRepository) update(auth transport.AuthMethod, revision string) (*object.Tree, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts.Auth = auth
+	if r.repo == nil {
+		if err := r.clone(); err != nil {
+			return nil, err
+		}
+	} else {
+		err := r.repo.Fetch(&git.FetchOptions{
+			RemoteName: git.DefaultRemoteName,
+			Auth:       r.opts.Auth,
+			Depth:      r.opts.Depth,
+			Force:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return nil, fmt.Errorf("failed to fetch %s: %w", r.opts.URL, err)
+		}
+	}
+	commit, err := resolveRevision(r.repo, r.head, revision)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+func (r *cachedRepository) clone() error {
+	// Cloning sets defaults in the options, which are shared with the
+	// caller.
+	o := *r.opts
+	storage := memory.NewStorage()
+	repo, err := git.Clone(storage, nil, &o)
+	if err != nil {
+		return err
+	}
+	head, err := repo.Head()
+	if err != nil {
+		return err
+	}
+	r.repo, r.storage = repo, storage
+	r.head = plumbing.NewRemoteReferenceName(git.DefaultRemoteName, head.Name().Short())
+	return nil
+}
+
+func (r *cachedRepository) size() int64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.storage == nil {
+		return 0
+	}
+	return storageSize(r.storage)
+}
+
+// storageSize returns the number of bytes in the objects in the storage.
+func storageSize(s *memory.Storage) int64 {
+	var n int64
+	for _, obj := range s.ObjectStorage.Objects {
+		n += obj.Size()
+	}
+	return n
+}
+
+// repositoryKey identifies a clone by the URL and branch, and the identity of
+// the credentials, which doesn't change when a token is rotated.
+func repositoryKey(opts *git.CloneOptions) string {
+	var identity string
+	switch a := opts.Auth.(type) {
+	case *http.BasicAuth:
+		identity = "basic:" + a.Username
+	case *http.TokenAuth:
+		identity = "token"
+	case *ssh.PublicKeys:
+		identity = "ssh:" + a.User + ":" + gossh.FingerprintSHA256(a.Signer.PublicKey())
+	case nil:
+	default:
+		identity = a.Name()
+	}
+	return fmt.Sprintf("%s#%s#%s", opts.URL, opts.ReferenceName, identity)
+}
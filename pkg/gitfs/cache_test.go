@@ -0,0 +1,232 @@
+package gitfs
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestRepositoryCacheFetchesChanges(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "README.md", "first")
+	c := NewRepositoryCache(10, 0)
+	opts := &git.CloneOptions{URL: src.dir}
+
+	assertReadFile(t, c, opts, "README.md", "first")
+	src.commit(t, "README.md", "second")
+	assertReadFile(t, c, opts, "README.md", "second")
+
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+}
+
+func TestRepositoryCacheReadsRevisions(t *testing.T) {
+	src := newSourceRepository(t)
+	first := src.commit(t, "README.md", "first")
+	if _, err := src.repo.CreateTag("v1", first, nil); err != nil {
+		t.Fatal(err)
+	}
+	head, err := src.repo.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.commit(t, "README.md", "second")
+	wt, err := src.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("release"), Hash: first, Create: true}); err != nil {
+		t.Fatal(err)
+	}
+	src.commit(t, "README.md", "release")
+	if err := wt.Checkout(&git.CheckoutOptions{Branch: head.Name()}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewRepositoryCache(10, 0)
+	opts := &git.CloneOptions{URL: src.dir}
+
+	revisionTests := []struct {
+		revision string
+		want     string
+	}{
+		{"", "second"},
+		{"HEAD", "second"},
+		{"v1", "first"},
+		{first.String(), "first"},
+		{"release", "release"},
+	}
+	for _, tt := range revisionTests {
+		t.Run(tt.revision, func(t *testing.T) {
+			assertReadFileAt(t, c, opts, tt.revision, "README.md", tt.want)
+		})
+	}
+
+	err = c.Read(opts, "unknown", func(fs.FileSystem) error { return nil })
+	if err == nil || err.Error() != `failed to resolve revision "unknown"` {
+		t.Fatalf("got error %v, want an unresolved revision", err)
+	}
+}
+
+func TestRepositoryCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	first, second := newSourceRepository(t), newSourceRepository(t)
+	first.commit(t, "README.md", "first")
+	second.commit(t, "README.md", "second")
+	c := NewRepositoryCache(1, 0)
+
+	assertReadFile(t, c, &git.CloneOptions{URL: first.dir}, "README.md", "first")
+	assertReadFile(t, c, &git.CloneOptions{URL: second.dir}, "README.md", "second")
+
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+}
+
+func TestRepositoryCacheIsKeyedByIdentity(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "README.md", "first")
+	c := NewRepositoryCache(10, 0)
+
+	for _, username := range []string{"first-user", "second-user", "first-user"} {
+		opts := &git.CloneOptions{URL: src.dir, Auth: &http.BasicAuth{Username: username, Password: "token"}}
+		assertReadFile(t, c, opts, "README.md", "first")
+	}
+
+	if c.Len() != 2 {
+		t.Fatalf("got %d cached repositories, want 2", c.Len())
+	}
+}
+
+func TestRepositoryCacheKeepsTheCloneWhenTheTokenIsRotated(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "README.md", "first")
+	c := NewRepositoryCache(10, 0)
+
+	for _, password := range []string{"first-token", "second-token"} {
+		opts := &git.CloneOptions{URL: src.dir, Auth: &http.BasicAuth{Username: "x-access-token", Password: password}}
+		assertReadFile(t, c, opts, "README.md", "first")
+	}
+
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+	r, _ := c.repos.Get(repositoryKey(&git.CloneOptions{URL: src.dir, Auth: &http.BasicAuth{Username: "x-access-token"}}))
+	if p := r.(*cachedRepository).opts.Auth.(*http.BasicAuth).Password; p != "second-token" {
+		t.Fatalf("got password %q, want the rotated token", p)
+	}
+}
+
+func TestRepositoryCacheEvictsRepositoriesOverTheMaximumBytes(t *testing.T) {
+	first, second := newSourceRepository(t), newSourceRepository(t)
+	first.commit(t, "README.md", "first")
+	second.commit(t, "README.md", "second")
+	c := NewRepositoryCache(10, 1)
+
+	assertReadFile(t, c, &git.CloneOptions{URL: first.dir}, "README.md", "first")
+	assertReadFile(t, c, &git.CloneOptions{URL: second.dir}, "README.md", "second")
+
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+	if _, ok := c.repos.Get(repositoryKey(&git.CloneOptions{URL: second.dir})); !ok {
+		t.Fatal("the repository that was read last was evicted")
+	}
+}
+
+func TestRepositoryCacheWithConcurrentReaders(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "README.md", "first")
+	c := NewRepositoryCache(10, 0)
+	opts := &git.CloneOptions{URL: src.dir}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertReadFile(t, c, opts, "README.md", "first")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRepositoryCacheWithUnknownRepository(t *testing.T) {
+	c := NewRepositoryCache(10, 0)
+
+	err := c.Read(&git.CloneOptions{URL: filepath.Join(t.TempDir(), "missing")}, "", func(fs.FileSystem) error {
+		t.Fatal("read an unknown repository")
+		return nil
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func assertReadFile(t *testing.T, c *RepositoryCache, opts *git.CloneOptions, name, want string) {
+	t.Helper()
+	assertReadFileAt(t, c, opts, "", name, want)
+}
+
+func assertReadFileAt(t *testing.T, c *RepositoryCache, opts *git.CloneOptions, revision, name, want string) {
+	t.Helper()
+	err := c.Read(opts, revision, func(files fs.FileSystem) error {
+		b, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if string(b) != want {
+			t.Errorf("got %q, want %q", b, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+type sourceRepository struct {
+	dir  string
+	repo *git.Repository
+}
+
+func newSourceRepository(t *testing.T) *sourceRepository {
+	t.Helper()
+	dir := t.TempDir()
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sourceRepository{dir: dir, repo: repo}
+}
+
+func (s *sourceRepository) commit(t *testing.T, name, content string) plumbing.Hash {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(s.dir, name)), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, name), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := s.repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wt.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	h, err := wt.Commit("Update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "Testing", Email: "testing@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
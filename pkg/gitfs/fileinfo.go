@@ -0,0 +1,71 @@
+package gitfs
+
+import (
+	"os"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// fileInfo is the os.FileInfo for an entry in a tree.
+//
+// The size of a file is only read when it's needed, as the blob may not have
+// been fetched.
+type fileInfo struct {
+	name string
+	mode filemode.FileMode
+	// tree is the tree that the path is relative to.
+	tree *object.Tree
+	path string
+}
+
+// Name implements os.FileInfo.
+func (f *fileInfo) Name() string {
+	return f.name
+}
+
+// Size implements os.FileInfo, the size of a directory is zero.
+func (f *fileInfo) Size() int64 {
+	if f.IsDir() || f.tree == nil {
+		return 0
+	}
+	size, err := f.tree.Size(f.path)
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
+// Mode implements os.FileInfo.
+//
+// Submodules are irregular files, as the commits that they refer to aren't
+// in the repository.
+func (f *fileInfo) Mode() os.FileMode {
+	switch f.mode {
+	case filemode.Dir:
+		return os.ModeDir | 0755
+	case filemode.Executable:
+		return 0755
+	case filemode.Symlink:
+		return os.ModeSymlink | 0777
+	case filemode.Submodule:
+		return os.ModeIrregular
+	}
+	return 0644
+}
+
+// ModTime implements os.FileInfo, Git doesn't store modification times.
+func (f *fileInfo) ModTime() time.Time {
+	return time.Time{}
+}
+
+// IsDir implements os.FileInfo.
+func (f *fileInfo) IsDir() bool {
+	return f.mode == filemode.Dir
+}
+
+// Sys implements os.FileInfo.
+func (f *fileInfo) Sys() any {
+	return nil
+}
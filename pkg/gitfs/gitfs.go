@@ -1,14 +1,16 @@
 package gitfs
 
 import (
+	"errors"
 	"fmt"
+	"os"
 	"path"
 	"path/filepath"
-	"strings"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
 	"github.com/go-git/go-git/v5/plumbing/object"
-	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/storage/memory"
 	fs "sigs.k8s.io/kustomize/kyaml/filesys"
 )
@@ -24,21 +26,19 @@ func New(t *object.Tree) fs.FileSystem {
 	return &gitFS{tree: t}
 }
 
-// NewInMemoryFromOptions clones a Git repository into memory.
-func NewInMemoryFromOptions(opts *git.CloneOptions) (fs.FileSystem, error) {
+// NewInMemoryFromOptions clones a Git repository into memory, and returns the
+// files at the revision, which can be a branch, tag or commit SHA.
+//
+// If the revision is empty, the files are at the head of the cloned branch.
+func NewInMemoryFromOptions(opts *git.CloneOptions, revision string) (fs.FileSystem, error) {
 	clone, err := git.Clone(memory.NewStorage(), nil, opts)
 	if err != nil {
 		return nil, err
 	}
-	ref, err := clone.Head()
+	commit, err := resolveRevision(clone, plumbing.HEAD, revision)
 	if err != nil {
 		return nil, err
 	}
-	commit, err := clone.CommitObject(ref.Hash())
-	if err != nil {
-		return nil, err
-	}
-
 	tree, err := commit.Tree()
 	if err != nil {
 		return nil, err
@@ -46,32 +46,45 @@ func NewInMemoryFromOptions(opts *git.CloneOptions) (fs.FileSystem, error) {
 	return New(tree), nil
 }
 
+// resolveRevision resolves a branch, tag or commit SHA to a commit in a clone,
+// branches are resolved to the remote-tracking branches, as the clone only
+// has a local branch for the cloned branch.
+//
+// If the revision is empty or HEAD, the head reference is resolved.
+func resolveRevision(repo *git.Repository, head plumbing.ReferenceName, revision string) (*object.Commit, error) {
+	if revision == "" || revision == string(plumbing.HEAD) {
+		ref, err := repo.Reference(head, true)
+		if err != nil {
+			return nil, err
+		}
+		return repo.CommitObject(ref.Hash())
+	}
+	candidates := []plumbing.Revision{
+		plumbing.Revision(plumbing.NewRemoteReferenceName(git.DefaultRemoteName, revision)),
+		plumbing.Revision(plumbing.NewTagReferenceName(revision)),
+		plumbing.Revision(revision),
+	}
+	for _, c := range candidates {
+		if h, err := repo.ResolveRevision(c); err == nil {
+			return repo.CommitObject(*h)
+		}
+	}
+	return nil, fmt.Errorf("failed to resolve revision %#v", revision)
+}
+
 // IsDir implements fs.FileSystem.
 func (g gitFS) IsDir(name string) bool {
-	// If it exists as a file, it's not a directory.
-	_, err := g.tree.File(name)
-	if err == nil {
-		return false
-	}
-	// Git doesn't store directories.
-	//
-	// If we can find a file with a prefix of the name we're looking for, then
-	// the name is a directory.
+	// Git doesn't store directories, but each directory with files in it is
+	// a tree.
 	//
-	// TODO: make this a bit more efficent, cache found dirs?
-	isDir := false
-	err = g.tree.Files().ForEach(func(f *object.File) error {
-		if strings.HasPrefix(f.Name, name) {
-			isDir = true
-			return storer.ErrStop
-		}
-		return nil
-	})
-	// TODO: not a lot of choice here, there's no scope for returning an error.
-	if err != nil {
-		panic(err)
+	// This only reads the trees in the path, and not the files, which matters
+	// when they're fetched as they're read.
+	p := path.Clean(name)
+	if p == "." {
+		return true
 	}
-	return isDir
+	_, err := g.tree.Tree(p)
+	return err == nil
 }
 
 // CleanedAbs implements fs.FileSystem.
@@ -124,7 +137,8 @@ func (g gitFS) Open(name string) (fs.File, error) {
 
 // Exists implements fs.FileSystem.
 func (g gitFS) Exists(name string) bool {
-	return false
+	_, err := g.stat(name)
+	return err == nil
 }
 
 // Glob implements fs.FileSystem.
@@ -138,13 +152,81 @@ func (g gitFS) WriteFile(name string, data []byte) error {
 }
 
 // Walk implementation for fs.FileSystem
-func (g gitFS) Walk(path string, walkFn filepath.WalkFunc) error {
-	return errNotSupported("Walk")
+//
+// The files and directories are walked in the order that they're stored in
+// the trees, which is lexical order.
+func (g gitFS) Walk(root string, walkFn filepath.WalkFunc) error {
+	info, err := g.stat(root)
+	if err != nil {
+		err = walkFn(root, nil, err)
+	} else {
+		err = g.walk(root, info, walkFn)
+	}
+	if errors.Is(err, filepath.SkipDir) || errors.Is(err, filepath.SkipAll) {
+		return nil
+	}
+	return err
+}
+
+func (g gitFS) walk(name string, info os.FileInfo, walkFn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return walkFn(name, info, nil)
+	}
+	entries, err := g.entries(name)
+	if err := walkFn(name, info, err); err != nil || entries == nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := g.walk(path.Join(name, e.Name()), e, walkFn); err != nil {
+			if !e.IsDir() || !errors.Is(err, filepath.SkipDir) {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 // ReadDir implementation for fs.FileSystem
-func (g gitFS) ReadDir(path string) ([]string, error) {
-	return []string{}, errNotSupported("ReadDir")
+func (g gitFS) ReadDir(name string) ([]string, error) {
+	entries, err := g.entries(name)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names, nil
+}
+
+// stat returns the file info for a file or directory.
+func (g gitFS) stat(name string) (os.FileInfo, error) {
+	p := path.Clean(name)
+	if p == "." {
+		return &fileInfo{name: p, mode: filemode.Dir}, nil
+	}
+	e, err := g.tree.FindEntry(p)
+	if err != nil {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return &fileInfo{name: e.Name, mode: e.Mode, tree: g.tree, path: p}, nil
+}
+
+// entries returns the files and directories in a directory.
+func (g gitFS) entries(name string) ([]os.FileInfo, error) {
+	t := g.tree
+	if p := path.Clean(name); p != "." {
+		var err error
+		t, err = g.tree.Tree(p)
+		if err != nil {
+			return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+		}
+	}
+	entries := make([]os.FileInfo, len(t.Entries))
+	for i, e := range t.Entries {
+		entries[i] = &fileInfo{name: e.Name, mode: e.Mode, tree: t, path: e.Name}
+	}
+	return entries, nil
 }
 
 func errNotSupported(s string) error {
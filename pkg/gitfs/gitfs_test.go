@@ -1,7 +1,10 @@
 package gitfs
 
 import (
+	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -54,6 +57,70 @@ func TestIsDir(t *testing.T) {
 	}
 }
 
+func TestExists(t *testing.T) {
+	gfs := makeClonedGFS(t)
+
+	for _, name := range []string{".", "pkg", "pkg/gitfs/", "README.md"} {
+		if !gfs.Exists(name) {
+			t.Errorf("Exists(%q) returned false", name)
+		}
+	}
+	if gfs.Exists("pkg/unknown") {
+		t.Fatal("Exists() returned true for a missing file")
+	}
+}
+
+func TestWalk(t *testing.T) {
+	gfs := makeClonedGFS(t)
+
+	var walked []string
+	err := gfs.Walk("pkg/parser/testdata/helm/taxi", func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == "templates" {
+			return filepath.SkipDir
+		}
+		walked = append(walked, name)
+		return nil
+	})
+	assertNoError(t, err)
+
+	want := []string{
+		"pkg/parser/testdata/helm/taxi",
+		"pkg/parser/testdata/helm/taxi/.helmignore",
+		"pkg/parser/testdata/helm/taxi/Chart.yaml",
+		"pkg/parser/testdata/helm/taxi/README.md",
+		"pkg/parser/testdata/helm/taxi/values.yaml",
+	}
+	if diff := cmp.Diff(want, walked); diff != "" {
+		t.Fatalf("walked incorrect files:\n%s", diff)
+	}
+}
+
+func TestWalkWithMissingDirectory(t *testing.T) {
+	gfs := makeClonedGFS(t)
+
+	err := gfs.Walk("pkg/unknown", func(name string, info os.FileInfo, err error) error {
+		return err
+	})
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("got error %v, want a not exist error", err)
+	}
+}
+
+func TestReadDir(t *testing.T) {
+	gfs := makeClonedGFS(t)
+
+	names, err := gfs.ReadDir("pkg/parser/testdata/app2")
+	assertNoError(t, err)
+
+	want := []string{"kustomization.yaml", "staging_patch.yaml"}
+	if diff := cmp.Diff(want, names); diff != "" {
+		t.Fatalf("read incorrect names:\n%s", diff)
+	}
+}
+
 func TestCleanedAbs(t *testing.T) {
 	gfs := makeClonedGFS(t)
 
@@ -106,7 +173,7 @@ func TestNewInMemoryFromOptions(t *testing.T) {
 func makeClonedGFS(t *testing.T) fs.FileSystem {
 	t.Helper()
 	gfs, err := NewInMemoryFromOptions(
-		test.MakeCloneOptions())
+		test.MakeCloneOptions(), "")
 	assertNoError(t, err)
 	return gfs
 }
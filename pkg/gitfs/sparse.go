@@ -0,0 +1,416 @@
+package gitfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/storage/memory"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-developer/gitops-backend/internal/cache"
+)
+
+// NewSparseFromOptions fetches the commit at the revision from a Git
+// repository, without its history, and returns the files in it.
+//
+// The directories and files are fetched as they're read, rather than
+// cloning the repository, so that only the files that are read are held in
+// memory.
+//
+// If the revision is empty, the files are at the head of the branch in the
+// options.
+func NewSparseFromOptions(opts *git.CloneOptions, revision string) (fs.FileSystem, error) {
+	tree, err := newSparseRepository(opts).tree(revision)
+	if err != nil {
+		return nil, err
+	}
+	return New(tree), nil
+}
+
+// maxSparseObjects is the number of objects that a sparseRepository keeps,
+// the least recently read are dropped, and fetched again if they're read.
+const maxSparseObjects = 10000
+
+// sparseRepository fetches the objects in a repository when they're read.
+//
+// Commits are fetched without their parents, trees or blobs, and each tree is
+// fetched the first time that it's read. When a file is read, all of the
+// files in its directory that haven't been fetched are fetched with it. The
+// objects are kept, so that they're only fetched once.
+//
+// This relies on the server supporting partial fetches, and fetching objects
+// that aren't at the tip of a branch. If it doesn't, commits are fetched with
+// all of their trees and blobs, but still without their history.
+type sparseRepository struct {
+	objects *cache.LRU[plumbing.Hash, plumbing.EncodedObject]
+	// dirs is the tree that each file that has been listed is in.
+	dirs *cache.LRU[plumbing.Hash, plumbing.Hash]
+
+	// mu is held while the fields below are read or changed, but not while
+	// objects are fetched.
+	mu sync.Mutex
+	// opts are replaced when the credentials change.
+	opts *git.CloneOptions
+	// caps are the capabilities of the server, from the last time that the
+	// references were listed.
+	caps *capability.List
+	// fetching are the fetches in progress for each object that's wanted,
+	// so that an object is only fetched once when it's read concurrently.
+	fetching map[plumbing.Hash]*pendingFetch
+}
+
+// pendingFetch is closed when the fetch is done.
+type pendingFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func newSparseRepository(opts *git.CloneOptions) *sparseRepository {
+	return &sparseRepository{
+		opts:     opts,
+		objects:  cache.New[plumbing.Hash, plumbing.EncodedObject](maxSparseObjects, 0),
+		dirs:     cache.New[plumbing.Hash, plumbing.Hash](maxSparseObjects, 0),
+		fetching: make(map[plumbing.Hash]*pendingFetch),
+	}
+}
+
+func (r *sparseRepository) read(auth transport.AuthMethod, revision string, f func(fs.FileSystem) error) error {
+	r.mu.Lock()
+	o := *r.opts
+	o.Auth = auth
+	r.opts = &o
+	r.mu.Unlock()
+	tree, err := r.tree(revision)
+	if err != nil {
+		return err
+	}
+	return f(New(tree))
+}
+
+func (r *sparseRepository) size() int64 {
+	var n int64
+	for _, obj := range r.objects.Values() {
+		n += obj.Size()
+	}
+	return n
+}
+
+// tree resolves the revision with the references on the server, and returns
+// the tree of the commit, fetching the commit if it hasn't been fetched.
+func (r *sparseRepository) tree(revision string) (*object.Tree, error) {
+	opts := r.options()
+	ar, err := r.advertisedReferences(opts)
+	if err != nil {
+		return nil, err
+	}
+	h, err := resolveAdvertisedRevision(ar, opts.ReferenceName, revision)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := object.GetCommit(&lazyObjectStorer{r}, h)
+	if err != nil {
+		return nil, err
+	}
+	return commit.Tree()
+}
+
+// options returns the options with the latest credentials.
+func (r *sparseRepository) options() *git.CloneOptions {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.opts
+}
+
+func (r *sparseRepository) advertisedReferences(opts *git.CloneOptions) (*packp.AdvRefs, error) {
+	s, err := newUploadPackSession(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	ar, err := s.AdvertisedReferences()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the references in %s: %w", opts.URL, err)
+	}
+	r.mu.Lock()
+	r.caps = ar.Capabilities
+	r.mu.Unlock()
+	return ar, nil
+}
+
+// encodedObject returns the object, fetching it if it hasn't been fetched.
+func (r *sparseRepository) encodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	if err := r.ensureFetched(h, t == plumbing.CommitObject); err != nil {
+		return nil, fmt.Errorf("failed to fetch %s from %s: %w", h, r.options().URL, err)
+	}
+	obj, ok := r.objects.Get(h)
+	if !ok || (t != plumbing.AnyObject && obj.Type() != t) {
+		return nil, plumbing.ErrObjectNotFound
+	}
+	return obj, nil
+}
+
+// ensureFetched fetches the object if it hasn't been fetched, with the other
+// files in its directory.
+//
+// If the object is already being fetched, this waits for that fetch rather
+// than fetching it again.
+func (r *sparseRepository) ensureFetched(h plumbing.Hash, commit bool) error {
+	r.mu.Lock()
+	if _, ok := r.objects.Get(h); ok {
+		r.mu.Unlock()
+		return nil
+	}
+	if f, ok := r.fetching[h]; ok {
+		r.mu.Unlock()
+		<-f.done
+		return f.err
+	}
+	f := &pendingFetch{done: make(chan struct{})}
+	wants := r.wants(h)
+	for _, w := range wants {
+		r.fetching[w] = f
+	}
+	opts, caps := r.opts, r.caps
+	r.mu.Unlock()
+
+	objects, err := fetchObjects(opts, caps, wants, commit)
+
+	r.mu.Lock()
+	for _, obj := range objects {
+		r.add(obj)
+	}
+	for _, w := range wants {
+		delete(r.fetching, w)
+	}
+	r.mu.Unlock()
+	f.err = err
+	close(f.done)
+	return err
+}
+
+// wants returns the object, and the other files in the same directory that
+// haven't been fetched and aren't being fetched.
+func (r *sparseRepository) wants(h plumbing.Hash) []plumbing.Hash {
+	wants := []plumbing.Hash{h}
+	dir, ok := r.dirs.Get(h)
+	if !ok {
+		return wants
+	}
+	obj, ok := r.objects.Get(dir)
+	if !ok {
+		return wants
+	}
+	var tree object.Tree
+	if err := tree.Decode(obj); err != nil {
+		return wants
+	}
+	for _, e := range tree.Entries {
+		if !e.Mode.IsFile() || e.Hash == h {
+			continue
+		}
+		if _, ok := r.objects.Get(e.Hash); ok {
+			continue
+		}
+		if _, ok := r.fetching[e.Hash]; ok {
+			continue
+		}
+		wants = append(wants, e.Hash)
+	}
+	return wants
+}
+
+// add keeps an object that was fetched, and the directory of the files in a
+// tree.
+func (r *sparseRepository) add(obj plumbing.EncodedObject) {
+	r.objects.Add(obj.Hash(), obj)
+	if obj.Type() != plumbing.TreeObject {
+		return
+	}
+	var tree object.Tree
+	if err := tree.Decode(obj); err != nil {
+		return
+	}
+	for _, e := range tree.Entries {
+		if e.Mode.IsFile() {
+			r.dirs.Add(e.Hash, obj.Hash())
+		}
+	}
+}
+
+// fetchObjects fetches the objects in one request, and returns them, commits
+// are fetched without their parents.
+//
+// With a partial fetch, none of the objects that the objects refer to are
+// fetched, the server always sends the objects that are requested.
+//
+// The capabilities of the server must have been listed.
+func fetchObjects(opts *git.CloneOptions, caps *capability.List, wants []plumbing.Hash, commit bool) (_ []plumbing.EncodedObject, err error) {
+	s, err := newUploadPackSession(opts)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	req := packp.NewUploadPackRequestFromCapabilities(caps)
+	req.Wants = wants
+	if commit {
+		req.Depth = packp.DepthCommits(1)
+		if err := req.Capabilities.Set(capability.Shallow); err != nil {
+			return nil, err
+		}
+	}
+	if caps.Supports(capability.NoProgress) {
+		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
+			return nil, err
+		}
+	}
+	if partial(caps) {
+		req.Filter = packp.FilterTreeDepth(0)
+		if err := req.Capabilities.Set(capability.Filter); err != nil {
+			return nil, err
+		}
+	}
+	res, err := s.UploadPack(context.Background(), req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if cerr := res.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	storage := memory.NewStorage()
+	if err := packfile.UpdateObjectStorage(storage, demuxSideband(req.Capabilities, res)); err != nil {
+		return nil, err
+	}
+	objects := make([]plumbing.EncodedObject, 0, len(storage.ObjectStorage.Objects))
+	for _, obj := range storage.ObjectStorage.Objects {
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// partial returns true if the server supports fetching objects on demand.
+func partial(caps *capability.List) bool {
+	return caps.Supports(capability.Filter) && caps.Supports(capability.AllowReachableSHA1InWant)
+}
+
+// resolveAdvertisedRevision resolves a branch, tag or commit SHA with the
+// references advertised by the server.
+//
+// If the revision is empty or HEAD, the head reference is resolved, this is
+// the HEAD of the server if the head is empty.
+func resolveAdvertisedRevision(ar *packp.AdvRefs, head plumbing.ReferenceName, revision string) (plumbing.Hash, error) {
+	if revision == "" || revision == string(plumbing.HEAD) {
+		if head == "" || head == plumbing.HEAD {
+			if ar.Head == nil {
+				return plumbing.ZeroHash, errors.New("failed to resolve HEAD")
+			}
+			return *ar.Head, nil
+		}
+		revision = string(head)
+	}
+	candidates := []string{
+		plumbing.NewBranchReferenceName(revision).String(),
+		plumbing.NewTagReferenceName(revision).String(),
+		revision,
+	}
+	for _, name := range candidates {
+		// Annotated tags are peeled to the commit that they tag.
+		if h, ok := ar.Peeled[name]; ok {
+			return h, nil
+		}
+		if h, ok := ar.References[name]; ok {
+			return h, nil
+		}
+	}
+	if plumbing.IsHash(revision) {
+		return plumbing.NewHash(revision), nil
+	}
+	return plumbing.ZeroHash, fmt.Errorf("failed to resolve revision %#v", revision)
+}
+
+func newUploadPackSession(opts *git.CloneOptions) (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint(opts.URL)
+	if err != nil {
+		return nil, err
+	}
+	ep.InsecureSkipTLS = opts.InsecureSkipTLS
+	ep.ClientCert = opts.ClientCert
+	ep.ClientKey = opts.ClientKey
+	ep.CaBundle = opts.CABundle
+	ep.Proxy = opts.ProxyOptions
+	c, err := client.NewClient(ep)
+	if err != nil {
+		return nil, err
+	}
+	return c.NewUploadPackSession(ep, opts.Auth)
+}
+
+func demuxSideband(l *capability.List, r io.Reader) io.Reader {
+	switch {
+	case l.Supports(capability.Sideband64k):
+		return sideband.NewDemuxer(sideband.Sideband64k, r)
+	case l.Supports(capability.Sideband):
+		return sideband.NewDemuxer(sideband.Sideband, r)
+	}
+	return r
+}
+
+// lazyObjectStorer fetches the objects that are read from the repository,
+// only reading objects is supported.
+type lazyObjectStorer struct {
+	*sparseRepository
+}
+
+// EncodedObject implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	return s.encodedObject(t, h)
+}
+
+// NewEncodedObject implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+// SetEncodedObject implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) SetEncodedObject(plumbing.EncodedObject) (plumbing.Hash, error) {
+	return plumbing.ZeroHash, errNotSupported("SetEncodedObject")
+}
+
+// IterEncodedObjects implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) IterEncodedObjects(plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	return nil, errNotSupported("IterEncodedObjects")
+}
+
+// HasEncodedObject implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) HasEncodedObject(h plumbing.Hash) error {
+	_, err := s.encodedObject(plumbing.AnyObject, h)
+	return err
+}
+
+// EncodedObjectSize implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) EncodedObjectSize(h plumbing.Hash) (int64, error) {
+	obj, err := s.encodedObject(plumbing.AnyObject, h)
+	if err != nil {
+		return 0, err
+	}
+	return obj.Size(), nil
+}
+
+// AddAlternate implements storer.EncodedObjectStorer.
+func (s *lazyObjectStorer) AddAlternate(string) error {
+	return errNotSupported("AddAlternate")
+}
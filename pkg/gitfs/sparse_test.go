@@ -0,0 +1,168 @@
+package gitfs
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestSparseRepositoryFetchesFilesAsTheyAreRead(t *testing.T) {
+	src := newSourceRepository(t)
+	src.allowPartialFetches(t)
+	src.commit(t, "environments/dev/apps/taxi/kustomization.yaml", "dev")
+	src.commit(t, "environments/stage/apps/taxi/kustomization.yaml", "stage")
+	r := newSparseRepository(&git.CloneOptions{URL: src.dir})
+
+	tree, err := r.tree("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := New(tree)
+	b, err := files.ReadFile("environments/dev/apps/taxi/kustomization.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "dev" {
+		t.Fatalf("got %q, want %q", b, "dev")
+	}
+	if !files.IsDir("environments/dev/apps") {
+		t.Fatal("IsDir() returned false for a directory")
+	}
+
+	if !r.fetched(t, "dev") {
+		t.Fatal("the file that was read wasn't fetched")
+	}
+	if r.fetched(t, "stage") {
+		t.Fatal("a file that wasn't read was fetched")
+	}
+}
+
+func TestSparseRepositoryFetchesTheFilesInADirectoryTogether(t *testing.T) {
+	src := newSourceRepository(t)
+	src.allowPartialFetches(t)
+	src.commit(t, "environments/dev/apps/taxi/kustomization.yaml", "kustomization")
+	src.commit(t, "environments/dev/apps/taxi/deployment.yaml", "deployment")
+	src.commit(t, "environments/stage/apps/taxi/kustomization.yaml", "stage")
+	r := newSparseRepository(&git.CloneOptions{URL: src.dir})
+
+	tree, err := r.tree("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := New(tree).ReadFile("environments/dev/apps/taxi/kustomization.yaml"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !r.fetched(t, "deployment") {
+		t.Fatal("a file in the same directory wasn't fetched")
+	}
+	if r.fetched(t, "stage") {
+		t.Fatal("a file in another directory was fetched")
+	}
+}
+
+func TestSparseRepositoryWithConcurrentReaders(t *testing.T) {
+	src := newSourceRepository(t)
+	src.allowPartialFetches(t)
+	src.commit(t, "environments/dev/apps/taxi/kustomization.yaml", "dev")
+	src.commit(t, "environments/stage/apps/taxi/kustomization.yaml", "stage")
+	c := NewSparseRepositoryCache(10, 0)
+	opts := &git.CloneOptions{URL: src.dir}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assertReadFile(t, c, opts, "environments/dev/apps/taxi/kustomization.yaml", "dev")
+			assertReadFile(t, c, opts, "environments/stage/apps/taxi/kustomization.yaml", "stage")
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSparseRepositoryWithoutPartialFetches(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "environments/dev/apps/taxi/kustomization.yaml", "dev")
+	src.commit(t, "environments/stage/apps/taxi/kustomization.yaml", "stage")
+
+	files, err := NewSparseFromOptions(&git.CloneOptions{URL: src.dir}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := files.ReadFile("environments/stage/apps/taxi/kustomization.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "stage" {
+		t.Fatalf("got %q, want %q", b, "stage")
+	}
+}
+
+func TestSparseRepositoryCacheReadsRevisions(t *testing.T) {
+	src := newSourceRepository(t)
+	src.allowPartialFetches(t)
+	first := src.commit(t, "README.md", "first")
+	if _, err := src.repo.CreateTag("v1", first, nil); err != nil {
+		t.Fatal(err)
+	}
+	src.commit(t, "README.md", "second")
+	c := NewSparseRepositoryCache(10, 0)
+	opts := &git.CloneOptions{URL: src.dir}
+
+	revisionTests := []struct {
+		revision string
+		want     string
+	}{
+		{"", "second"},
+		{"HEAD", "second"},
+		{"v1", "first"},
+		{first.String(), "first"},
+	}
+	for _, tt := range revisionTests {
+		t.Run(tt.revision, func(t *testing.T) {
+			assertReadFileAt(t, c, opts, tt.revision, "README.md", tt.want)
+		})
+	}
+
+	src.commit(t, "README.md", "third")
+	assertReadFile(t, c, opts, "README.md", "third")
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+}
+
+func TestSparseRepositoryWithUnknownRevision(t *testing.T) {
+	src := newSourceRepository(t)
+	src.commit(t, "README.md", "first")
+
+	_, err := NewSparseFromOptions(&git.CloneOptions{URL: src.dir}, "unknown")
+	if err == nil || err.Error() != `failed to resolve revision "unknown"` {
+		t.Fatalf("got error %v, want an unresolved revision", err)
+	}
+}
+
+// fetched returns true if a file with the content has been fetched.
+func (r *sparseRepository) fetched(t *testing.T, content string) bool {
+	t.Helper()
+	h := plumbing.ComputeHash(plumbing.BlobObject, []byte(content))
+	_, ok := r.objects.Get(h)
+	return ok
+}
+
+// allowPartialFetches configures the repository to serve partial fetches, and
+// objects that aren't at the tip of a branch.
+func (s *sourceRepository) allowPartialFetches(t *testing.T) {
+	t.Helper()
+	cfg, err := s.repo.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Raw.Section("uploadpack").SetOption("allowFilter", "true")
+	cfg.Raw.Section("uploadpack").SetOption("allowAnySHA1InWant", "true")
+	if err := s.repo.SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+}
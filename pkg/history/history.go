@@ -0,0 +1,207 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/kustomization"
+)
+
+// Entry is a commit in the GitOps repository that changed an application.
+type Entry struct {
+	SHA         string
+	Author      string
+	AuthorEmail string
+	Date        time.Time
+	Message     string
+	// Files are the files changed by the commit within the application's
+	// directories.
+	Files  []string
+	Images []ImageChange
+	// FilesTruncated is true if the commit changed more files than were
+	// listed, the images are only compared for the files that were listed.
+	FilesTruncated bool
+	// Baseline is true if there's no commit before the commit, the images
+	// that it set are reported as added.
+	Baseline bool
+}
+
+// ImageChange is an image that was changed in a kustomization.yaml by a
+// commit, From is empty if the image was added, and To is empty if it was
+// removed.
+type ImageChange struct {
+	Name string
+	From string
+	To   string
+}
+
+// ForApplication returns the most recent commits, up to the limit, that
+// changed the application at the path, or the directories that its
+// kustomization.yaml refers to, most recent first.
+//
+// The directories are found from the kustomization.yaml at the ref, if the
+// application has no kustomization.yaml, only the application's path is
+// used.
+//
+// The images changed by a commit are found by comparing the images in the
+// kustomization.yaml files that it changed, with the files at the next older
+// commit that changed the application, which are the same as at its parent.
+// The oldest commit that is listed is compared with the commit before it in
+// the history, as not every Git host resolves "<sha>^", and a commit with no
+// earlier commit is marked as the baseline.
+func ForApplication(ctx context.Context, client git.SCM, repo, ref, appPath string, limit int) ([]Entry, error) {
+	dirs, err := kustomization.Dirs(ctx, client, repo, ref, appPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(dirs) == 0 {
+		dirs = []string{path.Clean(appPath)}
+	}
+
+	var commits []*git.Commit
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		listed, err := client.ListCommits(ctx, repo, dir, ref)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range listed {
+			if !seen[c.SHA] {
+				seen[c.SHA] = true
+				commits = append(commits, c)
+			}
+		}
+	}
+	sort.SliceStable(commits, func(i, j int) bool { return commits[i].Date.After(commits[j].Date) })
+
+	entries := []Entry{}
+	for i, c := range commits {
+		if i >= limit {
+			break
+		}
+		commit, err := client.GetCommit(ctx, repo, c.SHA)
+		if err != nil {
+			return nil, err
+		}
+		files := filesWithin(commit.Files, dirs)
+		var previous string
+		if i+1 < len(commits) {
+			previous = commits[i+1].SHA
+		} else {
+			previous, err = previousCommit(ctx, client, repo, commit.SHA)
+			if err != nil {
+				return nil, err
+			}
+		}
+		images, err := imageChanges(ctx, client, repo, files, commit.SHA, previous)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{
+			SHA:            commit.SHA,
+			Author:         commit.Author,
+			AuthorEmail:    commit.AuthorEmail,
+			Date:           commit.Date,
+			Message:        commit.Message,
+			Files:          files,
+			FilesTruncated: commit.FilesTruncated,
+			Images:         images,
+			Baseline:       previous == "",
+		})
+	}
+	return entries, nil
+}
+
+// previousCommit returns the commit before the commit in the history, or an
+// empty string if it's the first commit.
+func previousCommit(ctx context.Context, client git.SCM, repo, sha string) (string, error) {
+	listed, err := client.ListCommits(ctx, repo, "", sha)
+	if err != nil {
+		return "", err
+	}
+	if len(listed) < 2 || listed[0].SHA != sha {
+		return "", nil
+	}
+	return listed[1].SHA, nil
+}
+
+func filesWithin(files, dirs []string) []string {
+	within := []string{}
+	for _, f := range files {
+		for _, dir := range dirs {
+			if kustomization.Within(f, dir) {
+				within = append(within, f)
+				break
+			}
+		}
+	}
+	sort.Strings(within)
+	return within
+}
+
+// imageChanges compares the images in the kustomization.yaml files at a
+// commit, with the files at the previous commit.
+//
+// If there is no previous commit, the images are reported as added.
+func imageChanges(ctx context.Context, client git.SCM, repo string, files []string, sha, previous string) ([]ImageChange, error) {
+	changes := []ImageChange{}
+	for _, f := range files {
+		if path.Base(f) != kustomization.File {
+			continue
+		}
+		after, err := fileImages(ctx, client, repo, f, sha)
+		if err != nil {
+			return nil, err
+		}
+		before := map[string]string{}
+		if previous != "" {
+			before, err = fileImages(ctx, client, repo, f, previous)
+			if err != nil {
+				return nil, err
+			}
+		}
+		names := map[string]bool{}
+		for name := range before {
+			names[name] = true
+		}
+		for name := range after {
+			names[name] = true
+		}
+		sorted := make([]string, 0, len(names))
+		for name := range names {
+			sorted = append(sorted, name)
+		}
+		sort.Strings(sorted)
+		for _, name := range sorted {
+			if before[name] != after[name] {
+				changes = append(changes, ImageChange{Name: name, From: before[name], To: after[name]})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// fileImages returns the image references in a kustomization.yaml by name,
+// the file not existing at the ref is treated as having no images.
+func fileImages(ctx context.Context, client git.SCM, repo, filename, ref string) (map[string]string, error) {
+	images := map[string]string{}
+	body, err := client.FileContents(ctx, repo, filename, ref)
+	if git.IsNotFound(err) {
+		return images, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	k, err := kustomization.Parse(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s at %s: %w", filename, ref, err)
+	}
+	for _, image := range k.Images {
+		images[image.Name] = kustomization.ImageReference(image)
+	}
+	return images, nil
+}
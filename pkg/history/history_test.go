@@ -0,0 +1,170 @@
+package history
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+const (
+	testRepo = "example/gitops"
+	appPath  = "environments/dev/apps/taxi"
+	basePath = "apps/taxi/base"
+)
+
+func TestForApplication(t *testing.T) {
+	client := newStubSCM()
+	first := client.addCommit("553c2077f0edc3d5dc5d17262f6aa498e69d6f8e", "Add the taxi service", time.Date(2021, time.May, 14, 10, 0, 0, 0, time.UTC),
+		appPath+"/kustomization.yaml", basePath+"/kustomization.yaml", basePath+"/deployment.yaml")
+	second := client.addCommit("6dcb09b5b57875f334f61aebed695e2e4193db5e", "Update the taxi image", time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		appPath+"/kustomization.yaml", "README.md")
+	third := client.addCommit("d8e7b2ca7ab2dd6f3a2b3e1c4a5e1e9f4f5d3b2a", "Scale the taxi service", time.Date(2021, time.May, 16, 8, 0, 0, 0, time.UTC),
+		basePath+"/deployment.yaml")
+	client.commits[appPath] = []*git.Commit{second, first}
+	client.commits[basePath] = []*git.Commit{third, first}
+	client.files[appPath+"/kustomization.yaml#"+first.SHA] = "resources:\n- ../../../../apps/taxi/base\nimages:\n- name: quay.io/example/taxi\n  newTag: v1\n"
+	client.files[appPath+"/kustomization.yaml#"+second.SHA] = "resources:\n- ../../../../apps/taxi/base\nimages:\n- name: quay.io/example/taxi\n  newTag: v2\n- name: quay.io/example/sidecar\n  newTag: v1\n"
+	client.files[appPath+"/kustomization.yaml#main"] = client.files[appPath+"/kustomization.yaml#"+second.SHA]
+	client.files[basePath+"/kustomization.yaml#main"] = "resources:\n- deployment.yaml\n"
+	client.files[basePath+"/kustomization.yaml#"+first.SHA] = client.files[basePath+"/kustomization.yaml#main"]
+
+	entries, err := ForApplication(context.TODO(), client, testRepo, "main", appPath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Entry{
+		{
+			SHA: third.SHA, Author: "Monalisa Octocat", AuthorEmail: "support@github.com", Date: third.Date,
+			Message: third.Message,
+			Files:   []string{basePath + "/deployment.yaml"},
+			Images:  []ImageChange{},
+		},
+		{
+			SHA: second.SHA, Author: "Monalisa Octocat", AuthorEmail: "support@github.com", Date: second.Date,
+			Message: second.Message,
+			Files:   []string{appPath + "/kustomization.yaml"},
+			Images: []ImageChange{
+				{Name: "quay.io/example/sidecar", To: "quay.io/example/sidecar:v1"},
+				{Name: "quay.io/example/taxi", From: "quay.io/example/taxi:v1", To: "quay.io/example/taxi:v2"},
+			},
+		},
+		{
+			SHA: first.SHA, Author: "Monalisa Octocat", AuthorEmail: "support@github.com", Date: first.Date,
+			Message: first.Message,
+			Files:   []string{basePath + "/deployment.yaml", basePath + "/kustomization.yaml", appPath + "/kustomization.yaml"},
+			Images: []ImageChange{
+				{Name: "quay.io/example/taxi", To: "quay.io/example/taxi:v1"},
+			},
+			Baseline: true,
+		},
+	}
+	if diff := cmp.Diff(want, entries); diff != "" {
+		t.Fatalf("incorrect history:\n%s", diff)
+	}
+}
+
+func TestForApplicationComparesTheOldestCommitWithTheCommitBefore(t *testing.T) {
+	client := newStubSCM()
+	second := client.addCommit("6dcb09b5b57875f334f61aebed695e2e4193db5e", "Update the taxi image", time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		appPath+"/kustomization.yaml")
+	// The commit before didn't change the application, so it isn't listed.
+	parent := "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e"
+	client.parents[second.SHA] = parent
+	client.commits[appPath] = []*git.Commit{second}
+	client.files[appPath+"/kustomization.yaml#"+parent] = "images:\n- name: quay.io/example/taxi\n  newTag: v1\n"
+	client.files[appPath+"/kustomization.yaml#"+second.SHA] = "images:\n- name: quay.io/example/taxi\n  newTag: v2\n"
+
+	entries, err := ForApplication(context.TODO(), client, testRepo, "main", appPath, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []ImageChange{{Name: "quay.io/example/taxi", From: "quay.io/example/taxi:v1", To: "quay.io/example/taxi:v2"}}
+	if diff := cmp.Diff(want, entries[0].Images); diff != "" {
+		t.Fatalf("incorrect images:\n%s", diff)
+	}
+	if entries[0].Baseline {
+		t.Fatal("commit with a parent is marked as the baseline")
+	}
+}
+
+func TestForApplicationWithLimit(t *testing.T) {
+	client := newStubSCM()
+	first := client.addCommit("553c2077f0edc3d5dc5d17262f6aa498e69d6f8e", "Add the taxi service", time.Date(2021, time.May, 14, 10, 0, 0, 0, time.UTC),
+		appPath+"/deployment.yaml")
+	second := client.addCommit("6dcb09b5b57875f334f61aebed695e2e4193db5e", "Update the taxi service", time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		appPath+"/deployment.yaml")
+	client.commits[appPath] = []*git.Commit{second, first}
+
+	entries, err := ForApplication(context.TODO(), client, testRepo, "main", appPath, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].SHA != second.SHA {
+		t.Fatalf("got %#v, want only the most recent commit", entries)
+	}
+	if client.commitCalls != 1 {
+		t.Fatalf("got %d commit fetches, want 1", client.commitCalls)
+	}
+}
+
+type stubSCM struct {
+	git.SCM
+	files       map[string]string
+	commits     map[string][]*git.Commit
+	details     map[string]*git.Commit
+	parents     map[string]string
+	commitCalls int
+}
+
+func newStubSCM() *stubSCM {
+	return &stubSCM{
+		files:   map[string]string{},
+		commits: map[string][]*git.Commit{},
+		details: map[string]*git.Commit{},
+		parents: map[string]string{},
+	}
+}
+
+func (s *stubSCM) addCommit(sha, message string, date time.Time, files ...string) *git.Commit {
+	s.details[sha] = &git.Commit{
+		SHA: sha, Message: message, Date: date,
+		Author: "Monalisa Octocat", AuthorEmail: "support@github.com", Files: files,
+	}
+	return &git.Commit{SHA: sha, Message: message, Date: date}
+}
+
+func (s *stubSCM) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
+	if body, ok := s.files[path+"#"+ref]; ok {
+		return []byte(body), nil
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
+
+// ListCommits lists the commit and its parent for an empty path, the
+// history of a commit.
+func (s *stubSCM) ListCommits(ctx context.Context, repo, path, ref string) ([]*git.Commit, error) {
+	if path == "" {
+		listed := []*git.Commit{{SHA: ref}}
+		if parent, ok := s.parents[ref]; ok {
+			listed = append(listed, &git.Commit{SHA: parent})
+		}
+		return listed, nil
+	}
+	return s.commits[path], nil
+}
+
+func (s *stubSCM) GetCommit(ctx context.Context, repo, sha string) (*git.Commit, error) {
+	s.commitCalls++
+	if c, ok := s.details[sha]; ok {
+		return c, nil
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
@@ -1,26 +1,28 @@
 package httpapi
 
 import (
-	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	"github.com/julienschmidt/httprouter"
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 
+	"github.com/redhat-developer/gitops-backend/internal/cache"
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+	"github.com/redhat-developer/gitops-backend/pkg/credentials"
 	"github.com/redhat-developer/gitops-backend/pkg/git"
 	"github.com/redhat-developer/gitops-backend/pkg/httpapi/secrets"
+	"github.com/redhat-developer/gitops-backend/pkg/kinds"
 	"github.com/redhat-developer/gitops-backend/pkg/parser"
 )
 
@@ -31,49 +33,88 @@ var DefaultSecretRef = types.NamespacedName{
 }
 
 const (
-	defaultRef             = "HEAD"
-	defaultArgoCDInstance  = "openshift-gitops"
-	defaultArgocdNamespace = "openshift-gitops"
-	kindService            = "Service"
-	kindDeployment         = "Deployment"
-	kindSecret             = "Secret"
-	kindSealedSecret       = "SealedSecret"
-	kindRoute              = "Route"
-	kindRoleBinding        = "RoleBinding"
-	kindClusterRole        = "ClusterRole"
-	kindClusterRoleBinding = "ClusterRoleBinding"
-)
+	defaultRef = "HEAD"
 
-var baseURL = fmt.Sprintf("https://%s-server.%s.svc.cluster.local", defaultArgoCDInstance, defaultArgocdNamespace)
+	// pipelinesCacheSize is the number of parsed pipelines.yaml files that
+	// are kept, they are keyed by the commit they were read from, so they
+	// don't expire.
+	pipelinesCacheSize = 100
+)
 
 // APIRouter is an HTTP API for accessing app configurations.
 type APIRouter struct {
 	*httprouter.Router
 	gitClientFactory git.ClientFactory
 	secretGetter     secrets.SecretGetter
+	credentials      *credentials.Resolver
 	secretRef        types.NamespacedName
 	resourceParser   parser.ResourceParser
-	k8sClient        ctrlclient.Client
+	k8sClient        ctrlclient.WithWatch
+	pipelinesCache   *cache.LRU[string, *config]
+	renders          *cache.LRU[string, []*parser.Resource]
+	recentRenders    *cache.LRU[string, *recentRender]
+	argoCD           *argocd.Instances
+	resourceKinds    *kinds.Registry
 }
 
 // NewRouter creates and returns a new APIRouter.
-func NewRouter(c git.ClientFactory, s secrets.SecretGetter, kc ctrlclient.Client) *APIRouter {
+func NewRouter(c git.ClientFactory, s secrets.SecretGetter, cr *credentials.Resolver, rp parser.ResourceParser, kc ctrlclient.WithWatch, ac *argocd.Instances, rk *kinds.Registry) *APIRouter {
 	api := &APIRouter{
 		Router:           httprouter.New(),
 		gitClientFactory: c,
 		secretGetter:     s,
+		credentials:      cr,
 		secretRef:        DefaultSecretRef,
-		resourceParser:   parser.ParseFromGit,
+		resourceParser:   rp,
 		k8sClient:        kc,
+		pipelinesCache:   cache.New[string, *config](pipelinesCacheSize, 0),
+		renders:          cache.New[string, []*parser.Resource](renderCacheSize, 0),
+		recentRenders:    cache.New[string, *recentRender](recentRendersSize, recentRendersTTL),
+		argoCD:           ac,
+		resourceKinds:    rk,
+	}
+	for _, r := range api.routes() {
+		api.HandlerFunc(r.method, r.path, r.handler)
 	}
-	api.HandlerFunc(http.MethodGet, "/pipelines", api.GetPipelines)
-	api.HandlerFunc(http.MethodGet, "/applications", api.ListApplications)
-	api.HandlerFunc(http.MethodGet, "/environments/:env/application/:app", api.GetApplication)
-	api.HandlerFunc(http.MethodGet, "/environment/:env/application/:app", api.GetApplicationDetails)
-	api.HandlerFunc(http.MethodGet, "/history/environment/:env/application/:app", api.GetApplicationHistory)
 	return api
 }
 
+// route is an API endpoint, all routes must be described in the OpenAPI
+// specification.
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+}
+
+func (a *APIRouter) routes() []route {
+	var routes []route
+	// The unversioned routes are kept for existing clients, and serve the
+	// same responses as /api/v1.
+	for _, prefix := range []string{"", "/api/v1"} {
+		routes = append(routes,
+			route{http.MethodGet, prefix + "/pipelines", a.GetPipelines},
+			route{http.MethodGet, prefix + "/applications", a.ListApplications},
+			route{http.MethodGet, prefix + "/environments/:env/application/:app", a.GetApplication},
+			route{http.MethodGet, prefix + "/environment/:env/application/:app", a.GetApplicationDetails},
+			route{http.MethodGet, prefix + "/history/environment/:env/application/:app", a.GetApplicationHistory},
+			route{http.MethodPost, prefix + "/environments/:from/application/:app/promote", a.PromoteApplication},
+		)
+	}
+	return append(routes,
+		route{http.MethodGet, "/api/v2/pipelines", a.GetPipelinesV2},
+		route{http.MethodGet, "/api/v2/applications", a.ListApplicationsV2},
+		route{http.MethodGet, "/api/v2/environments/:env/application/:app", a.GetApplicationV2},
+		route{http.MethodGet, "/api/v2/environments/:env/application/:app/commits", a.GetApplicationCommitsV2},
+		route{http.MethodGet, "/api/v2/environment/:env/application/:app", a.GetApplicationDetailsV2},
+		route{http.MethodGet, "/api/v2/history/environment/:env/application/:app", a.GetApplicationHistoryV2},
+		route{http.MethodGet, "/api/v2/stream/applications", a.StreamApplications},
+		route{http.MethodGet, "/api/v2/compare/environments/:from/:to/application/:app", a.CompareEnvironmentsV2},
+		route{http.MethodPost, "/api/v2/environments/:from/application/:app/promote", a.PromoteApplication},
+	)
+}
+
+// RevisionMeta describes the commit that an application was deployed from.
 type RevisionMeta struct {
 	Author   string `json:"author"`
 	Message  string `json:"message"`
@@ -82,172 +123,195 @@ type RevisionMeta struct {
 
 // GetPipelines fetches and returns the pipeline body.
 func (a *APIRouter) GetPipelines(w http.ResponseWriter, r *http.Request) {
-	urlToFetch := r.URL.Query().Get("url")
-	if urlToFetch == "" {
-		log.Println("ERROR: could not get url from request")
-		http.Error(w, "missing parameter 'url'", http.StatusBadRequest)
+	pipelines, commit, _, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
 		return
 	}
+	if notModified(w, r, commit) {
+		return
+	}
+	marshalResponse(w, pipelinesToAppsResponse(pipelines))
+}
 
-	// TODO: replace this with logr or sugar.
-	log.Printf("urlToFetch = %#v\n", urlToFetch)
-	repo, parsedRepo, err := parseURL(urlToFetch)
+// GetApplication fetches an application within a specific environment.
+//
+// Expects the "url" query parameter to point to a GitOps repository with a
+// pipelines.yaml configuration.
+func (a *APIRouter) GetApplication(w http.ResponseWriter, r *http.Request) {
+	pipelines, _, creds, err := a.pipelinesConfig(r)
 	if err != nil {
-		log.Printf("ERROR: failed to parse the URL: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-
-	token, err := a.getAuthToken(r.Context(), r)
+	params := httprouter.ParamsFromContext(r.Context())
+	appEnvironments, err := a.environmentApplication(r.Context(), creds, pipelines, requestRef(r), params.ByName("env"), params.ByName("app"))
 	if err != nil {
-		log.Printf("ERROR: failed to get an authentication token: %s", err)
-		http.Error(w, "unable to authenticate request", http.StatusBadRequest)
+		log.Printf("ERROR: failed to get application data: %s", err)
+		writeError(w, newAPIError("failed to extract data", err))
 		return
 	}
-	client, err := a.getAuthenticatedGitClient(urlToFetch, token)
+	marshalResponse(w, appEnvironments)
+}
+
+// ListApplications lists the Argo CD applications that are deployed from the
+// GitOps repository in the "url" query parameter.
+//
+// The applications can be filtered with the "env", "sync", "health",
+// "project" and "selector" query parameters, sorted with "sort", and
+// paginated with "limit" and "continue".
+func (a *APIRouter) ListApplications(w http.ResponseWriter, r *http.Request) {
+	apps, repoURL, next, err := a.listApplications(r)
 	if err != nil {
-		log.Printf("ERROR: failed to get an authenticated client: %s", err)
-		http.Error(w, "unable to authenticate request", http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
+	resp := applicationsToAppsResponse(apps, repoURL)
+	resp.Continue = next
+	marshalResponse(w, resp)
+}
 
-	// TODO: don't send back the error directly.
-	//
-	// Add a "not found" error that can be returned, otherwise it's a
-	// StatusInternalServerError.
-	log.Println("got an authenticated client")
-	body, err := client.FileContents(r.Context(), repo, "pipelines.yaml", refFromQuery(parsedRepo.Query()))
+// GetApplicationHistory returns the deployment history of an application in
+// an environment.
+func (a *APIRouter) GetApplicationHistory(w http.ResponseWriter, r *http.Request) {
+	app, err := a.applicationFromRequest(r)
 	if err != nil {
-		log.Printf("ERROR: failed to get file contents for repo %#v: %s", repo, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	pipelines := &config{}
-	err = yaml.Unmarshal(body, &pipelines)
+	params := httprouter.ParamsFromContext(r.Context())
+	marshalResponse(w, historyToEnvHistory(a.applicationHistory(r.Context(), app, params.ByName("env"))))
+}
+
+// GetApplicationDetails returns the sync status, and the health of the
+// resources of an application in an environment.
+func (a *APIRouter) GetApplicationDetails(w http.ResponseWriter, r *http.Request) {
+	app, err := a.applicationFromRequest(r)
 	if err != nil {
-		log.Printf("ERROR: failed to unmarshal body: %s", err)
-		http.Error(w, fmt.Sprintf("failed to unmarshal pipelines.yaml: %s", err.Error()), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-	marshalResponse(w, pipelinesToAppsResponse(pipelines))
+	marshalResponse(w, applicationDetailsToMap(a.applicationDetails(r.Context(), app)))
 }
 
-// GetApplication fetches an application within a specific environment.
+// pipelinesConfig fetches and parses the pipelines.yaml from the repository
+// in the "url" query parameter, it returns the parsed configuration, the
+// commit that it was read from, and the credentials that were used to
+// authenticate the request.
 //
-// Expects the
-func (a *APIRouter) GetApplication(w http.ResponseWriter, r *http.Request) {
+// The ref is resolved to a commit with the request's credentials before the
+// file is fetched, and the parsed configuration is cached by the commit SHA.
+func (a *APIRouter) pipelinesConfig(r *http.Request) (*config, *git.Commit, *credentials.Credentials, error) {
 	urlToFetch := r.URL.Query().Get("url")
 	if urlToFetch == "" {
 		log.Println("ERROR: could not get url from request")
-		http.Error(w, "missing parameter 'url'", http.StatusBadRequest)
-		return
+		return nil, nil, nil, badRequest("missing parameter 'url'")
 	}
 
-	// TODO: replace this with logr or sugar.
-	log.Printf("urlToFetch = %#v\n", urlToFetch)
 	repo, parsedRepo, err := parseURL(urlToFetch)
 	if err != nil {
 		log.Printf("ERROR: failed to parse the URL: %s", err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, nil, nil, badRequest(err.Error())
 	}
 
-	token, err := a.getAuthToken(r.Context(), r)
+	creds, err := a.getCredentials(r.Context(), r, urlToFetch)
 	if err != nil {
-		log.Printf("ERROR: failed to get an authentication token: %s", err)
-		http.Error(w, "unable to authenticate request", http.StatusBadRequest)
-		return
+		log.Printf("ERROR: failed to get the credentials: %s", err)
+		return nil, nil, nil, newAPIError("unable to authenticate request", err)
 	}
-	client, err := a.getAuthenticatedGitClient(urlToFetch, token)
+	client, err := a.getAuthenticatedGitClient(urlToFetch, creds)
 	if err != nil {
 		log.Printf("ERROR: failed to get an authenticated client: %s", err)
-		http.Error(w, "unable to authenticate request", http.StatusBadRequest)
-		return
+		return nil, nil, nil, newAPIError("unable to create a Git client", err)
 	}
 
-	// TODO: don't send back the error directly.
-	//
-	// Add a "not found" error that can be returned, otherwise it's a
-	// StatusInternalServerError.
-	body, err := client.FileContents(r.Context(), repo, "pipelines.yaml", refFromQuery(parsedRepo.Query()))
+	ref := requestRef(r)
+	commit, err := client.ResolveRef(r.Context(), repo, ref)
+	if err != nil {
+		log.Printf("ERROR: failed to resolve ref %#v for repo %#v: %s", ref, repo, err)
+		return nil, nil, nil, newAPIError("failed to resolve ref "+ref, err)
+	}
+	cacheKey := strings.Join([]string{parsedRepo.Host, repo, commit.SHA}, "#")
+	if pipelines, ok := a.pipelinesCache.Get(cacheKey); ok {
+		return pipelines, commit, creds, nil
+	}
+	body, err := client.FileContents(r.Context(), repo, "pipelines.yaml", commit.SHA)
 	if err != nil {
 		log.Printf("ERROR: failed to get file contents for repo %#v: %s", repo, err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, nil, nil, newAPIError("failed to fetch pipelines.yaml", err)
 	}
 	pipelines := &config{}
 	err = yaml.Unmarshal(body, &pipelines)
 	if err != nil {
 		log.Printf("ERROR: failed to unmarshal body: %s", err)
-		http.Error(w, fmt.Sprintf("failed to unmarshal pipelines.yaml: %s", err.Error()), http.StatusBadRequest)
-		return
-	}
-	params := httprouter.ParamsFromContext(r.Context())
-	appEnvironments, err := a.environmentApplication(token, pipelines, params.ByName("env"), params.ByName("app"))
-	if err != nil {
-		log.Printf("ERROR: failed to get application data: %s", err)
-		http.Error(w, "failed to extract data", http.StatusBadRequest)
-		return
+		return nil, nil, nil, &APIError{
+			Status:  http.StatusInternalServerError,
+			Code:    CodeInternalError,
+			Message: "failed to unmarshal pipelines.yaml",
+			Details: err.Error(),
+		}
 	}
-	marshalResponse(w, appEnvironments)
+	a.pipelinesCache.Add(cacheKey, pipelines)
+	return pipelines, commit, creds, nil
 }
 
-func (a *APIRouter) ListApplications(w http.ResponseWriter, r *http.Request) {
-	repoURL := strings.TrimSpace(r.URL.Query().Get("url"))
-	if repoURL == "" {
-		http.Error(w, "please provide a valid GitOps repo URL", http.StatusBadRequest)
-		return
-	}
-
-	parsedRepoURL, err := url.Parse(repoURL)
+// listApplications lists the Argo CD applications that match the filter in
+// the query parameters, and returns the page of them in the query parameters,
+// with the parsed repository URL from the "url" query parameter, and the
+// continue token for the next page.
+func (a *APIRouter) listApplications(r *http.Request) ([]*argoV1aplha1.Application, string, string, error) {
+	filter, err := applicationFilterFromQuery(r.URL.Query(), a.argoCD)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to parse URL, error: %v", err), http.StatusBadRequest)
-		return
+		return nil, "", "", err
 	}
-
-	parsedRepoURL.RawQuery = ""
-
-	appList := &argoV1aplha1.ApplicationList{}
-	var listOptions []ctrlclient.ListOption
-
-	listOptions = append(listOptions, ctrlclient.InNamespace(""))
-
-	err = a.k8sClient.List(r.Context(), appList, listOptions...)
+	page, err := applicationPageFromQuery(r.URL.Query())
 	if err != nil {
-		log.Printf("ERROR: failed to get application list: %v", err)
-		http.Error(w, fmt.Sprintf("failed to get list of application, err: %v", err), http.StatusBadRequest)
-		return
+		return nil, "", "", err
 	}
 
 	apps := make([]*argoV1aplha1.Application, 0)
-	for _, app := range appList.Items {
-		apps = append(apps, app.DeepCopy())
+	listOptions := append(filter.listOptions(), ctrlclient.Limit(listChunkSize))
+	continueToken := ""
+	for {
+		appList := &argoV1aplha1.ApplicationList{}
+		err = a.k8sClient.List(r.Context(), appList, append(listOptions, ctrlclient.Continue(continueToken))...)
+		if err != nil {
+			log.Printf("ERROR: failed to get application list: %v", err)
+			return nil, "", "", newAPIError("failed to get list of applications", err)
+		}
+		for i := range appList.Items {
+			app := &appList.Items[i]
+			if filter.matches(app) {
+				apps = append(apps, app.DeepCopy())
+			}
+		}
+		if appList.Continue == "" {
+			break
+		}
+		continueToken = appList.Continue
 	}
 
-	marshalResponse(w, applicationsToAppsResponse(apps, parsedRepoURL.String()))
+	apps, next := page.apply(apps)
+	return apps, filter.repoURL, next, nil
 }
 
-func (a *APIRouter) GetApplicationHistory(w http.ResponseWriter, r *http.Request) {
+// applicationFromRequest finds the Argo CD Application for the environment
+// and application in the request path, deployed from the repository in the
+// "url" query parameter.
+func (a *APIRouter) applicationFromRequest(r *http.Request) (*argoV1aplha1.Application, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 	envName, appName := params.ByName("env"), params.ByName("app")
-	app := &argoV1aplha1.Application{}
+	var app *argoV1aplha1.Application
 
-	repoURL := strings.TrimSpace(r.URL.Query().Get("url"))
-	if repoURL == "" {
-		log.Println("ERROR: please provide a valid GitOps repo URL")
-		http.Error(w, "please provide a valid GitOps repo URL", http.StatusBadRequest)
-		return
+	parsedRepoURL, err := repoURLFromQuery(r.URL.Query())
+	if err != nil {
+		return nil, err
 	}
-
-	parsedRepoURL, err := url.Parse(repoURL)
+	instance, err := instanceFromQuery(r.URL.Query(), a.argoCD)
 	if err != nil {
-		log.Printf("ERROR: failed to parse URL, error: %v", err)
-		http.Error(w, fmt.Sprintf("failed to parse URL, error: %v", err), http.StatusBadRequest)
-		return
+		return nil, err
 	}
 
-	parsedRepoURL.RawQuery = ""
-
 	appList := &argoV1aplha1.ApplicationList{}
 	var listOptions []ctrlclient.ListOption
 
@@ -258,234 +322,142 @@ func (a *APIRouter) GetApplicationHistory(w http.ResponseWriter, r *http.Request
 	err = a.k8sClient.List(r.Context(), appList, listOptions...)
 	if err != nil {
 		log.Printf("ERROR: failed to get application list: %v", err)
-		http.Error(w, fmt.Sprintf("failed to get list of application, err: %v", err), http.StatusBadRequest)
-		return
+		return nil, newAPIError("failed to get list of applications", err)
 	}
+
 	// At this point, if there are no apps as generated by KAM, then check if there are any
 	// apps in general, where the app name is the environment name. Users aren't expected to use both
 	// KAM generated apps and custom apps in their GitOps repo.
+	// We should error out if we don't have the environments as applications
 	if len(appList.Items) == 0 {
 		listOptions = nil
 		listOptions = append(listOptions, ctrlclient.InNamespace(""), ctrlclient.MatchingFields{
-			"metadata.name": fmt.Sprintf("%s", envName),
+			"metadata.name": envName,
 		})
 		err = a.k8sClient.List(r.Context(), appList, listOptions...)
 		if err != nil {
 			log.Printf("ERROR: failed to get application list: %v", err)
-			http.Error(w, fmt.Sprintf("failed to get list of application, err: %v", err), http.StatusBadRequest)
-			return
+			return nil, newAPIError("failed to get list of applications", err)
 		}
 	}
 
-	for _, a := range appList.Items {
-		if a.Spec.Source.RepoURL == parsedRepoURL.String() {
-			app = &a
+	for i := range appList.Items {
+		if appList.Items[i].Spec.Source.RepoURL != parsedRepoURL.String() {
+			continue
+		}
+		if instance != nil && !managedBy(a.argoCD, *instance, &appList.Items[i]) {
+			continue
 		}
+		app = &appList.Items[i]
 	}
 
 	if app == nil {
-		log.Printf("ERROR: failed to get application %s: %v", appName, err)
-		http.Error(w, fmt.Sprintf("failed to get the application %s, err: %v", appName, err), http.StatusBadRequest)
-		return
-	}
-
-	var deployedTime, revision string
-	hist := app.Status.History
-	var historyList = make([]envHistory, 0)
-	for _, h := range hist {
-		revision = h.Revision
-		t := h.DeployedAt
-		if !t.IsZero() {
-			deployedTime = t.String()
-		}
-		commitInfo, err := a.getCommitInfo(app.Name, revision)
-		if err != nil {
-			log.Printf("WARNING: failed to retrieve revision metadata for app %s: %v. The app might be unsynced.", appName, err)
-		}
-		hist := envHistory{
-			Author:      commitInfo["author"],
-			Message:     commitInfo["message"],
-			Revision:    revision,
-			RepoUrl:     h.Source.RepoURL,
-			Environment: envName,
-			DeployedAt:  deployedTime,
-		}
-		historyList = append([]envHistory{hist}, historyList...)
+		log.Printf("ERROR: failed to find application %s in environment %s", appName, envName)
+		return nil, notFound(fmt.Sprintf("failed to find the application %s in environment %s", appName, envName))
 	}
-	marshalResponse(w, historyList)
+	return app, nil
 }
 
-func (a *APIRouter) GetApplicationDetails(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
-	envName, appName := params.ByName("env"), params.ByName("app")
-	app := &argoV1aplha1.Application{}
-	var lastDeployed, revision string
-
-	repoURL := strings.TrimSpace(r.URL.Query().Get("url"))
-	if repoURL == "" {
-		log.Println("ERROR: please provide a valid GitOps repo URL")
-		http.Error(w, "please provide a valid GitOps repo URL", http.StatusBadRequest)
-		return
+func (a *APIRouter) applicationHistory(ctx context.Context, app *argoV1aplha1.Application, envName string) []apiv2.HistoryEntry {
+	history := make([]apiv2.HistoryEntry, 0)
+	for _, h := range app.Status.History {
+		history = append([]apiv2.HistoryEntry{a.historyEntry(ctx, app, h, envName)}, history...)
 	}
+	return history
+}
 
-	parsedRepoURL, err := url.Parse(repoURL)
-	if err != nil {
-		log.Printf("ERROR: failed to parse URL, error: %v", err)
-		http.Error(w, fmt.Sprintf("failed to parse URL, error: %v", err), http.StatusBadRequest)
-		return
+func (a *APIRouter) historyEntry(ctx context.Context, app *argoV1aplha1.Application, h argoV1aplha1.RevisionHistory, envName string) apiv2.HistoryEntry {
+	entry := apiv2.HistoryEntry{
+		Revision:    h.Revision,
+		RepoURL:     h.Source.RepoURL,
+		Environment: envName,
 	}
-
-	parsedRepoURL.RawQuery = ""
-
-	appList := &argoV1aplha1.ApplicationList{}
-	var listOptions []ctrlclient.ListOption
-
-	listOptions = append(listOptions, ctrlclient.InNamespace(""), ctrlclient.MatchingFields{
-		"metadata.name": fmt.Sprintf("%s-%s", envName, appName),
-	})
-
-	err = a.k8sClient.List(r.Context(), appList, listOptions...)
+	metadata, err := a.argoCD.RevisionMetadata(ctx, ctrlclient.ObjectKeyFromObject(app), h.Revision)
 	if err != nil {
-		log.Printf("ERROR: failed to get application list: %v", err)
-		http.Error(w, fmt.Sprintf("failed to get list of application, err: %v", err), http.StatusBadRequest)
-		return
-	}
-
-	// At this point, if there are no apps as generated by KAM, then check if there are any
-	// apps in general, where the app name is the environment name. Users aren't expected to use both
-	// KAM generated apps and custom apps in their GitOps repo.
-	// We should error out if we don't have the environments as applications
-	if len(appList.Items) == 0 {
-		listOptions = nil
-		listOptions = append(listOptions, ctrlclient.InNamespace(""), ctrlclient.MatchingFields{
-			"metadata.name": fmt.Sprintf("%s", envName),
-		})
-		err = a.k8sClient.List(r.Context(), appList, listOptions...)
-		if err != nil {
-			log.Printf("ERROR: failed to get application list: %v", err)
-			http.Error(w, fmt.Sprintf("failed to get list of application, err: %v", err), http.StatusBadRequest)
-			return
-		}
+		log.Printf("WARNING: failed to retrieve revision metadata for app %s: %v. The app might be unsynced.", app.Name, err)
+	} else {
+		entry.Author = metadata.Author
+		entry.Message = metadata.Message
 	}
-
-	for _, a := range appList.Items {
-		if a.Spec.Source.RepoURL == parsedRepoURL.String() {
-			app = &a
-		}
+	if !h.DeployedAt.IsZero() {
+		t := h.DeployedAt.Time
+		entry.DeployedAt = &t
 	}
+	return entry
+}
 
-	if app == nil {
-		log.Printf("ERROR: failed to get application %s: %v", appName, err)
-		http.Error(w, fmt.Sprintf("failed to get the application %s, err: %v", appName, err), http.StatusBadRequest)
-		return
+func (a *APIRouter) applicationDetails(ctx context.Context, app *argoV1aplha1.Application) *apiv2.ApplicationDetails {
+	details := &apiv2.ApplicationDetails{
+		Environment: app.Spec.Destination.Namespace,
+		Cluster:     app.Spec.Destination.Server,
+		Status:      string(app.Status.Sync.Status),
 	}
-
 	if len(app.Status.History) > 0 {
-		revision = app.Status.History[len(app.Status.History)-1].Revision
-		t := app.Status.History[len(app.Status.History)-1].DeployedAt
-		if !t.IsZero() {
-			lastDeployed = t.String()
+		last := app.Status.History[len(app.Status.History)-1]
+		details.Revision.Revision = last.Revision
+		if !last.DeployedAt.IsZero() {
+			t := last.DeployedAt.Time
+			details.LastDeployed = &t
 		}
 	}
 
-	commitInfo, err := a.getCommitInfo(app.Name, revision)
+	metadata, err := a.argoCD.RevisionMetadata(ctx, ctrlclient.ObjectKeyFromObject(app), details.Revision.Revision)
 	if err != nil {
-		log.Printf("Warning: failed to retrieve revision metadata for app %s: %v. The app might be unsynced", appName, err)
+		log.Printf("Warning: failed to retrieve revision metadata for app %s: %v. The app might be unsynced", app.Name, err)
+	} else {
+		details.Revision.Author = strings.Split(metadata.Author, " ")[0]
+		details.Revision.Message = metadata.Message
 	}
 
-	revisionMeta := RevisionMeta{
-		Author:   strings.Split(commitInfo["author"], " ")[0],
-		Message:  commitInfo["message"],
-		Revision: revision,
+	details.Resources = applicationResources(a.resourceKinds, app)
+	return details
+}
+
+// applicationResources groups the resources in an Application by the
+// response group of their kind, with their sync and health status.
+//
+// All the response groups in the registry are included, so that clients see
+// the same groups for every Application, resources of kinds that aren't
+// registered are not included.
+func applicationResources(rk *kinds.Registry, app *argoV1aplha1.Application) map[string][]apiv2.ResourceHealth {
+	envResources := map[string][]apiv2.ResourceHealth{}
+	for _, g := range rk.ResponseGroups() {
+		envResources[g] = make([]apiv2.ResourceHealth, 0)
 	}
-	var envResources = make(map[string][]envHealthResource)
-	envResources[kindService] = make([]envHealthResource, 0)
-	envResources[kindDeployment] = make([]envHealthResource, 0)
-	envResources[kindSecret] = make([]envHealthResource, 0)
-	envResources[kindRoute] = make([]envHealthResource, 0)
-	envResources[kindRoleBinding] = make([]envHealthResource, 0)
-	envResources[kindClusterRole] = make([]envHealthResource, 0)
-	envResources[kindClusterRoleBinding] = make([]envHealthResource, 0)
 
 	for _, aResource := range app.Status.Resources {
-		switch aResource.Kind {
-		case kindService:
-			envResources[kindService] = append(envResources[kindService], envHealthResource{
-				Name:   aResource.Name,
-				Health: string(aResource.Health.Status),
-				Status: string(aResource.Status),
-			})
-		case kindDeployment:
-			envResources[kindDeployment] = append(envResources[kindDeployment], envHealthResource{
-				Name:   aResource.Name,
-				Health: string(aResource.Health.Status),
-				Status: string(aResource.Status),
-			})
-		case kindSecret, kindSealedSecret:
-			secretHealth := ""
-			if aResource.Health != nil {
-				secretHealth = string(aResource.Health.Status)
-			}
-			envResources[kindSecret] = append(envResources[kindSecret], envHealthResource{
-				Name:   aResource.Name,
-				Health: secretHealth,
-				Status: string(aResource.Status),
-			})
-		case kindRoute:
-			envResources[kindRoute] = append(envResources[kindRoute], envHealthResource{
-				Name:   aResource.Name,
-				Status: string(aResource.Status),
-			})
-		case kindRoleBinding:
-			envResources[kindRoleBinding] = append(envResources[kindRoleBinding], envHealthResource{
-				Name:   aResource.Name,
-				Status: string(aResource.Status),
-			})
-		case kindClusterRole:
-			envResources[kindClusterRole] = append(envResources[kindClusterRole], envHealthResource{
-				Name:   aResource.Name,
-				Status: string(aResource.Status),
-			})
-		case kindClusterRoleBinding:
-			envResources[kindClusterRoleBinding] = append(envResources[kindClusterRoleBinding], envHealthResource{
-				Name:   aResource.Name,
-				Status: string(aResource.Status),
-			})
+		kind, ok := rk.Lookup(aResource.Group, aResource.Kind)
+		if !ok {
+			continue
 		}
+		resource := apiv2.ResourceHealth{
+			Name:   aResource.Name,
+			Status: string(aResource.Status),
+		}
+		// Health isn't reported until Argo CD has assessed the resource.
+		if kind.Health && aResource.Health != nil {
+			resource.Health = string(aResource.Health.Status)
+		}
+		envResources[kind.ResponseGroup] = append(envResources[kind.ResponseGroup], resource)
 	}
-	appEnv := map[string]interface{}{
-		"environment":         app.Spec.Destination.Namespace,
-		"cluster":             app.Spec.Destination.Server,
-		"lastDeployed":        lastDeployed,
-		"status":              app.Status.Sync.Status,
-		"revision":            revisionMeta,
-		"services":            envResources[kindService],
-		"secrets":             envResources[kindSecret],
-		"deployments":         envResources[kindDeployment],
-		"routes":              envResources[kindRoute],
-		"roleBindings":        envResources[kindRoleBinding],
-		"clusterRoles":        envResources[kindClusterRole],
-		"clusterRoleBindings": envResources[kindClusterRoleBinding],
-	}
-
-	marshalResponse(w, appEnv)
+	return envResources
 }
 
-func (a *APIRouter) getAuthToken(ctx context.Context, req *http.Request) (string, error) {
-	token := AuthToken(ctx)
-	secret, ok := secretRefFromQuery(req.URL.Query())
-	if !ok {
-		secret = a.secretRef
-	}
-	// TODO: this should be using a logger implementation.
-	log.Printf("using secret from %#v", secret)
-	token, err := a.secretGetter.SecretToken(ctx, token, secret, "token")
+// getCredentials returns the credentials for accessing the repository at
+// repoURL from the secret in the query parameters, or the default secret.
+func (a *APIRouter) getCredentials(ctx context.Context, req *http.Request, repoURL string) (*credentials.Credentials, error) {
+	data, err := a.secretGetter.Secret(ctx, AuthToken(ctx), a.requestSecretRef(req))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return token, nil
+	return a.credentials.FromSecret(ctx, repoURL, data)
 }
-func (a *APIRouter) getAuthenticatedGitClient(fetchURL, token string) (git.SCM, error) {
+
+func (a *APIRouter) getAuthenticatedGitClient(fetchURL string, creds *credentials.Credentials) (git.SCM, error) {
+	token, err := creds.APIToken()
+	if err != nil {
+		return nil, &APIError{Status: http.StatusUnprocessableEntity, Code: CodeInvalidSecret, Message: err.Error(), err: err}
+	}
 	return a.gitClientFactory.Create(fetchURL, token)
 }
 
@@ -497,6 +469,15 @@ func parseURL(s string) (string, *url.URL, error) {
 	return strings.TrimLeft(strings.TrimSuffix(parsed.Path, ".git"), "/"), parsed, nil
 }
 
+// requestSecretRef returns the secret in the query parameters, or the default
+// secret.
+func (a *APIRouter) requestSecretRef(req *http.Request) types.NamespacedName {
+	if secret, ok := secretRefFromQuery(req.URL.Query()); ok {
+		return secret
+	}
+	return a.secretRef
+}
+
 func secretRefFromQuery(v url.Values) (types.NamespacedName, bool) {
 	ns := v.Get("secretNS")
 	name := v.Get("secretName")
@@ -509,86 +490,89 @@ func secretRefFromQuery(v url.Values) (types.NamespacedName, bool) {
 	return types.NamespacedName{}, false
 }
 
-func refFromQuery(v url.Values) string {
-	if ref := v.Get("ref"); ref != "" {
-		return ref
-	}
-	return defaultRef
-}
-
-func marshalResponse(w http.ResponseWriter, v interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	if err := json.NewEncoder(w).Encode(v); err != nil {
-		log.Printf("failed to encode response: %s", err)
-	}
-}
-
-func (a *APIRouter) getCommitInfo(app, revision string) (map[string]string, error) {
-	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
-	argocdCreds := &corev1.Secret{}
-	err := a.k8sClient.Get(context.TODO(),
-		types.NamespacedName{
-			Name:      defaultArgoCDInstance + "-cluster",
-			Namespace: defaultArgocdNamespace,
-		}, argocdCreds)
-	if err != nil {
-		return nil, err
+// repoURLFromQuery parses the "url" query parameter as the URL of a GitOps
+// repository, without any query parameters.
+func repoURLFromQuery(v url.Values) (*url.URL, error) {
+	repoURL := strings.TrimSpace(v.Get("url"))
+	if repoURL == "" {
+		log.Println("ERROR: please provide a valid GitOps repo URL")
+		return nil, badRequest("please provide a valid GitOps repo URL")
 	}
 
-	payload := map[string]string{
-		"username": "admin",
-		"password": string(argocdCreds.Data["admin.password"]),
-	}
-	payloadBytes, err := json.Marshal(payload)
+	parsedRepoURL, err := url.Parse(repoURL)
 	if err != nil {
-		return nil, err
+		log.Printf("ERROR: failed to parse URL, error: %v", err)
+		return nil, badRequest(fmt.Sprintf("failed to parse URL, error: %v", err))
 	}
+	parsedRepoURL.RawQuery = ""
+	return parsedRepoURL, nil
+}
 
-	resp, err := client.Post(fmt.Sprintf("%s/api/v1/session", baseURL),
-		"application/json", bytes.NewBuffer(payloadBytes))
-	if err != nil {
-		return nil, err
+// requestRef returns the ref in the "ref" query parameter, or in the query of
+// the repository URL in the "url" query parameter, or the default ref.
+func requestRef(r *http.Request) string {
+	if v := r.URL.Query().Get("ref"); v != "" {
+		return v
 	}
-	defer resp.Body.Close()
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if parsed, err := url.Parse(r.URL.Query().Get("url")); err == nil {
+		return refFromQuery(parsed.Query())
 	}
+	return defaultRef
+}
 
-	m := make(map[string]string)
-	err = json.Unmarshal(bodyBytes, &m)
-	if err != nil {
-		return nil, err
+func refFromQuery(v url.Values) string {
+	if ref := v.Get("ref"); ref != "" {
+		return ref
 	}
+	return defaultRef
+}
 
-	if token, ok := m["token"]; !ok || token == "" {
-		return nil, fmt.Errorf("failed to retrieve JWT from the api-server")
+// notModified sets the ETag and Last-Modified headers for a response that is
+// generated from a commit, and if the request's conditional headers match the
+// commit, writes a 304 response and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, commit *git.Commit) bool {
+	etag := fmt.Sprintf("%q", commit.SHA)
+	w.Header().Set("ETag", etag)
+	if !commit.Date.IsZero() {
+		w.Header().Set("Last-Modified", commit.Date.UTC().Format(http.TimeFormat))
 	}
 
-	u := fmt.Sprintf("%s/api/v1/applications/%s/revisions/%s/metadata", baseURL, app, revision)
-	req, err := http.NewRequest("GET", u, nil)
-	if err != nil {
-		return nil, err
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if !etagMatches(inm, etag) {
+			return false
+		}
+	} else {
+		ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+		if err != nil || commit.Date.IsZero() || commit.Date.Truncate(time.Second).After(ims) {
+			return false
+		}
 	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
 
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", m["token"]))
-	req.Header.Add("content-type", "application/json")
-
-	resp, err = client.Do(req)
-	if err != nil {
-		return nil, err
+// etagMatches returns true if the etag is in the list of ETags from an
+// If-None-Match header, using weak comparison.
+func etagMatches(header, etag string) bool {
+	for _, v := range strings.Split(header, ",") {
+		v = strings.TrimPrefix(strings.TrimSpace(v), "W/")
+		if v == "*" || v == etag {
+			return true
+		}
 	}
+	return false
+}
 
-	defer resp.Body.Close()
-	bodyBytes, err = ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
+func marshalResponse(w http.ResponseWriter, v interface{}) {
+	marshalResponseWithStatus(w, http.StatusOK, v)
+}
 
-	err = json.Unmarshal(bodyBytes, &m)
-	if err != nil {
-		return nil, err
+func marshalResponseWithStatus(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("failed to encode response: %s", err)
 	}
-	return m, nil
 }
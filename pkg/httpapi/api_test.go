@@ -15,12 +15,17 @@ import (
 
 	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 	gogit "github.com/go-git/go-git/v5"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-cmp/cmp"
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+	"github.com/redhat-developer/gitops-backend/pkg/credentials"
 	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/kinds"
 	"github.com/redhat-developer/gitops-backend/pkg/parser"
 	"github.com/redhat-developer/gitops-backend/test"
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -32,7 +37,7 @@ const (
 	testRef = "7638417db6d59f3c431d3e1f261cc637155684cd"
 )
 
-func makeTestClient() ctrlclient.Client {
+func makeTestClient() ctrlclient.WithWatch {
 	_ = argoV1aplha1.AddToScheme(scheme.Scheme)
 	builder := fake.NewClientBuilder().WithIndex(
 		&argoV1aplha1.Application{},
@@ -88,6 +93,109 @@ func TestGetPipelinesWithASpecificRef(t *testing.T) {
 	})
 }
 
+func TestGetPipelinesWithRefParameter(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", testRef, "testdata/pipelines.yaml")
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git?ref=main"},
+		"ref": []string{testRef},
+	}
+
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?%s", ts.URL, options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertJSONResponse(t, res, map[string]interface{}{
+		"applications": []interface{}{
+			map[string]interface{}{
+				"name":         "taxi",
+				"repo_url":     "https://example.com/demo/gitops.git",
+				"environments": []interface{}{"dev"},
+			},
+		},
+	})
+}
+
+func TestGetPipelinesSetsCacheHeaders(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addCommit("example/gitops", "HEAD", &git.Commit{SHA: testRef, Date: time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)})
+	c.addContents("example/gitops", "pipelines.yaml", testRef, "testdata/pipelines.yaml")
+	pipelinesURL := "https://github.com/example/gitops.git"
+
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?url=%s", ts.URL, pipelinesURL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	assertHTTPHeader(t, res, "ETag", `"`+testRef+`"`)
+	assertHTTPHeader(t, res, "Last-Modified", "Sat, 15 May 2021 02:12:13 GMT")
+}
+
+func TestGetPipelinesNotModified(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addCommit("example/gitops", "HEAD", &git.Commit{SHA: testRef, Date: time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)})
+	c.addContents("example/gitops", "pipelines.yaml", testRef, "testdata/pipelines.yaml")
+	pipelinesURL := "https://github.com/example/gitops.git"
+
+	conditionalTests := []struct {
+		name       string
+		header     string
+		value      string
+		wantStatus int
+	}{
+		{"matching etag", "If-None-Match", `"` + testRef + `"`, http.StatusNotModified},
+		{"weak etag in list", "If-None-Match", `"abc", W/"` + testRef + `"`, http.StatusNotModified},
+		{"different etag", "If-None-Match", `"abc"`, http.StatusOK},
+		{"not modified since", "If-Modified-Since", "Sat, 15 May 2021 02:12:13 GMT", http.StatusNotModified},
+		{"modified since", "If-Modified-Since", "Sat, 15 May 2021 02:12:12 GMT", http.StatusOK},
+	}
+
+	for _, tt := range conditionalTests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, path := range []string{"/pipelines", "/api/v2/pipelines"} {
+				req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s%s?url=%s", ts.URL, path, pipelinesURL))
+				req.Header.Set(tt.header, tt.value)
+				res, err := ts.Client().Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				res.Body.Close()
+				if res.StatusCode != tt.wantStatus {
+					t.Fatalf("%s: got status %d, want %d", path, res.StatusCode, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPipelinesCachesParsedConfig(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", testRef, "testdata/pipelines.yaml")
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git"},
+		"ref": []string{testRef},
+	}
+
+	for i := 0; i < 2; i++ {
+		req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?%s", ts.URL, options.Encode()))
+		res, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("got status %d, want %d", res.StatusCode, http.StatusOK)
+		}
+		// The contents are removed after the first request, the second
+		// request must come from the cache.
+		delete(c.files, key("example/gitops", "pipelines.yaml", testRef))
+	}
+}
+
 func TestGetPipelinesWithNoURL(t *testing.T) {
 	ts, _ := makeServer(t)
 	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines", ts.URL))
@@ -95,7 +203,7 @@ func TestGetPipelinesWithNoURL(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	assertHTTPError(t, res, http.StatusBadRequest, "missing parameter 'url'")
+	assertAPIError(t, res, http.StatusBadRequest, CodeBadRequest, "missing parameter 'url'")
 }
 
 func TestGetPipelinesWithBadURL(t *testing.T) {
@@ -106,7 +214,7 @@ func TestGetPipelinesWithBadURL(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	assertHTTPError(t, res, http.StatusBadRequest, "missing parameter 'url'")
+	assertAPIError(t, res, http.StatusBadRequest, CodeBadRequest, "missing parameter 'url'")
 }
 
 func TestGetPipelinesWithNoAuthorizationHeader(t *testing.T) {
@@ -174,7 +282,56 @@ func TestGetPipelinesWithUnknownSecret(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assertErrorResponse(t, res, http.StatusBadRequest, "unable to authenticate request")
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, "unable to authenticate request")
+}
+
+func TestGetPipelinesWithForbiddenSecret(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.secretGetter = errorSecretGetter{
+			err: apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "other-name", errors.New("access denied")),
+		}
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "main", "testdata/pipelines.yaml")
+	pipelinesURL := "https://github.com/example/gitops.git"
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?url=%s", ts.URL, pipelinesURL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAPIError(t, res, http.StatusForbidden, CodeForbidden, "unable to authenticate request")
+}
+
+// The pipelines.yaml is read from the Git host's API, which can't be
+// authenticated with an SSH private key, so the secret must be fixed.
+func TestGetPipelinesWithSSHOnlySecret(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.secretGetter = &stubSecretGetter{
+			testName: DefaultSecretRef, testAuthToken: "testing",
+			testData: map[string][]byte{"sshPrivateKey": []byte("test-key")},
+		}
+		a.gitClientFactory = privateClientFactory{stubClientFactory{client: newClient()}}
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?url=%s", ts.URL, "https://github.com/example/gitops.git"))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAPIError(t, res, http.StatusUnprocessableEntity, CodeInvalidSecret, credentials.ErrNoAPIToken.Error())
+}
+
+func TestGetPipelinesWithMissingFile(t *testing.T) {
+	ts, _ := makeServer(t)
+	pipelinesURL := "https://github.com/example/gitops.git"
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?url=%s", ts.URL, pipelinesURL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, "failed to fetch pipelines.yaml")
 }
 
 func TestGetPipelineApplication(t *testing.T) {
@@ -207,6 +364,7 @@ func TestGetPipelineApplication(t *testing.T) {
 	assertJSONResponse(t, res, map[string]interface{}{
 		"environment": "dev",
 		"cluster":     "https://dev.testing.svc",
+		"commit":      "HEAD",
 		"services": []interface{}{
 			map[string]interface{}{
 				"name": "gitops-demo",
@@ -228,6 +386,73 @@ func TestGetPipelineApplication(t *testing.T) {
 	})
 }
 
+func TestGetPipelineApplicationCloneOptions(t *testing.T) {
+	cloneTests := []struct {
+		name string
+		data map[string][]byte
+		want *gogit.CloneOptions
+	}{
+		{
+			"token",
+			map[string][]byte{"token": []byte("test-token")},
+			&gogit.CloneOptions{
+				URL:  "https://example.com/demo/gitops.git",
+				Auth: &githttp.BasicAuth{Username: "gitops", Password: "test-token"},
+			},
+		},
+		{
+			"basic auth",
+			map[string][]byte{"username": []byte("deploy"), "password": []byte("test-password")},
+			&gogit.CloneOptions{
+				URL:  "https://example.com/demo/gitops.git",
+				Auth: &githttp.BasicAuth{Username: "deploy", Password: "test-password"},
+			},
+		},
+	}
+
+	for _, tt := range cloneTests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got *gogit.CloneOptions
+			ts, c := makeServer(t, func(a *APIRouter) {
+				a.secretGetter = &stubSecretGetter{testName: DefaultSecretRef, testAuthToken: "testing", testData: tt.data}
+				a.resourceParser = func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+					got = opts
+					return nil, nil
+				}
+			})
+			c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+			req := makeClientRequest(t, "Bearer testing",
+				fmt.Sprintf("%s/environments/dev/application/taxi?url=%s", ts.URL, "https://github.com/example/gitops.git"))
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readBody(t, res)
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("incorrect clone options:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetPipelinesWithInvalidSecret(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.secretGetter = &stubSecretGetter{
+			testName: DefaultSecretRef, testAuthToken: "testing",
+			testData: map[string][]byte{"username": []byte("deploy")},
+		}
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/pipelines?url=%s", ts.URL, "https://github.com/example/gitops.git"))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAPIError(t, res, http.StatusInternalServerError, CodeInternalError, "unable to authenticate request")
+}
+
 func TestGetPipelineApplicationWithRef(t *testing.T) {
 	testResource := &parser.Resource{
 		Group:     "",
@@ -258,6 +483,7 @@ func TestGetPipelineApplicationWithRef(t *testing.T) {
 	assertJSONResponse(t, res, map[string]interface{}{
 		"environment": "dev",
 		"cluster":     "https://dev.testing.svc",
+		"commit":      testRef,
 		"services": []interface{}{
 			map[string]interface{}{
 				"name": "gitops-demo",
@@ -279,6 +505,93 @@ func TestGetPipelineApplicationWithRef(t *testing.T) {
 	})
 }
 
+func TestGetPipelineApplicationWithRefParameter(t *testing.T) {
+	var gotRevision string
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+			gotRevision = revision
+			return nil, nil
+		}
+	})
+	c.addCommit("demo/gitops", "v1.0.0", &git.Commit{SHA: testRef})
+	c.addContents("example/gitops", "pipelines.yaml", "v1.0.0", "testdata/pipelines.yaml")
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git?ref=main"},
+		"ref": []string{"v1.0.0"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/environments/%s/application/%s?%s", ts.URL, "dev", "taxi", options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got apiv2.EnvironmentApplication
+	if err := json.Unmarshal(readBody(t, res), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Commit != testRef {
+		t.Fatalf("got commit %q, want %q", got.Commit, testRef)
+	}
+	if gotRevision != testRef {
+		t.Fatalf("got revision %q, want the resolved commit %q", gotRevision, testRef)
+	}
+}
+
+func TestGetPipelineApplicationWithSourceOptions(t *testing.T) {
+	sourceTests := []struct {
+		filename string
+		want     *parser.Source
+	}{
+		{"testdata/pipelines_directory.yaml", &parser.Source{Directory: &parser.Directory{Recurse: true, Exclude: "tests/*"}}},
+		{"testdata/pipelines_helm.yaml", &parser.Source{Helm: &parser.Helm{ValueFiles: []string{"values-dev.yaml"}}}},
+	}
+	for _, tt := range sourceTests {
+		t.Run(tt.filename, func(t *testing.T) {
+			var gotSource *parser.Source
+			ts, c := makeServer(t, func(a *APIRouter) {
+				a.resourceParser = func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+					gotSource = src
+					return nil, nil
+				}
+			})
+			c.addContents("example/gitops", "pipelines.yaml", "HEAD", tt.filename)
+			options := url.Values{
+				"url": []string{"https://github.com/example/gitops.git"},
+			}
+			req := makeClientRequest(t, "Bearer testing",
+				fmt.Sprintf("%s/api/v2/environments/%s/application/%s?%s", ts.URL, "dev", "taxi", options.Encode()))
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			readBody(t, res)
+
+			if diff := cmp.Diff(tt.want, gotSource); diff != "" {
+				t.Fatalf("incorrect source options:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestGetPipelineApplicationWithUnknownEnvironment(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = stubResourceParser()
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/environments/%s/application/%s?%s", ts.URL, "unknown", "taxi", options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, `failed to find environment "unknown"`)
+}
+
 func TestParseURL(t *testing.T) {
 	urlTests := []struct {
 		u        string
@@ -411,57 +724,20 @@ func TestListApplications_badURL(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assertHTTPError(t, resp, http.StatusBadRequest, "please provide a valid GitOps repo URL")
+	assertAPIError(t, resp, http.StatusBadRequest, CodeBadRequest, "please provide a valid GitOps repo URL")
 }
 
 func TestGetApplicationDetails(t *testing.T) {
-	var err error
 	kc := makeTestClient()
-
-	ts, _ := makeServer(t, func(router *APIRouter) {
-		router.k8sClient = kc
-	})
-
-	// create test ArgoCD Server to handle http requests
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := r.URL.String()
-		if strings.Contains(u, "session") {
-			m := map[string]string{
-				"token": "testing",
-			}
-			marshalResponse(w, m)
-		} else if strings.Contains(u, "metadata") {
-			m := map[string]string{
-				"author":  "test",
-				"message": "testMessage",
-			}
-			marshalResponse(w, m)
-		}
-	}))
-	defer server.Close()
-	tmp := baseURL
-	baseURL = server.URL
-
-	var createOptions []ctrlclient.CreateOption
-	app, _ := testArgoApplication("testdata/application.yaml")
-	// create argocd test-app
-	err = kc.Create(context.TODO(), app, createOptions...)
+	app, err := testArgoApplication("testdata/application.yaml")
 	if err != nil {
 		t.Fatal(err)
 	}
-
-	// create argocd instance creds secret
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      defaultArgoCDInstance + "-cluster",
-			Namespace: defaultArgocdNamespace,
-		},
-		Data: map[string][]byte{
-			"admin.password": []byte("abc"),
-		},
-	}
-	err = kc.Create(context.TODO(), secret, createOptions...)
-	if err != nil {
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD = testArgoCD(app)
+	})
+	if err := kc.Create(context.TODO(), app); err != nil {
 		t.Fatal(err)
 	}
 
@@ -510,62 +786,47 @@ func TestGetApplicationDetails(t *testing.T) {
 		"roleBindings": []interface{}{
 			map[string]interface{}{"name": string("argocd-admin"), "status": string("Synced")},
 		},
+		"configMaps":             []interface{}{},
+		"cronJobs":               []interface{}{},
+		"daemonSets":             []interface{}{},
+		"ingresses":              []interface{}{},
+		"persistentVolumeClaims": []interface{}{},
+		"statefulSets":           []interface{}{},
 	})
-
-	//reset BaseURL
-	baseURL = tmp
 }
 
-func TestGetApplicationHistory(t *testing.T) {
-	var err error
+func TestGetApplicationDetailsWithUnknownApplication(t *testing.T) {
 	kc := makeTestClient()
-
 	ts, _ := makeServer(t, func(router *APIRouter) {
 		router.k8sClient = kc
 	})
 
-	// create test ArgoCD Server to handle http requests
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		u := r.URL.String()
-		if strings.Contains(u, "session") {
-			m := map[string]string{
-				"token": "testing",
-			}
-			marshalResponse(w, m)
-		} else if strings.Contains(u, "metadata") {
-			m := map[string]string{
-				"author":  "test",
-				"message": "testMessage",
-			}
-			marshalResponse(w, m)
-		}
-	}))
-	defer server.Close()
-	tmp := baseURL
-	baseURL = server.URL
-
-	var createOptions []ctrlclient.CreateOption
-	app, _ := testArgoApplication("testdata/application3.yaml")
-	// create argocd test-app
-	err = kc.Create(context.TODO(), app, createOptions...)
+	options := url.Values{
+		"url": []string{"https://github.com/test-repo/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/environment/%s/application/%s?%s", ts.URL, "dev", "unknown", options.Encode()))
+	res, err := ts.Client().Do(req)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	// create argocd instance creds secret
-	secret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      defaultArgoCDInstance + "-cluster",
-			Namespace: defaultArgocdNamespace,
-		},
-		Data: map[string][]byte{
-			"admin.password": []byte("abc"),
-		},
-	}
-	err = kc.Create(context.TODO(), secret, createOptions...)
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, "failed to find the application unknown in environment dev")
+}
+
+func TestGetApplicationHistory(t *testing.T) {
+	kc := makeTestClient()
+	app, err := testArgoApplication("testdata/application3.yaml")
 	if err != nil {
 		t.Fatal(err)
 	}
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD = testArgoCD(app)
+	})
+	if err := kc.Create(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
 
 	options := url.Values{
 		"url": []string{"https://github.com/test-repo/gitops.git"},
@@ -629,9 +890,6 @@ func TestGetApplicationHistory(t *testing.T) {
 		},
 	}
 	assertJSONResponseHistory(t, res, want)
-
-	//reset BaseURL
-	baseURL = tmp
 }
 
 func testArgoApplication(appCr string) (*argoV1aplha1.Application, error) {
@@ -646,11 +904,41 @@ func testArgoApplication(appCr string) (*argoV1aplha1.Application, error) {
 }
 
 func newClient() *stubClient {
-	return &stubClient{files: make(map[string]string)}
+	return &stubClient{
+		files:    make(map[string]string),
+		commits:  make(map[string]*git.Commit),
+		branches: make(map[string]string),
+		updates:  make(map[string][]byte),
+		history:  make(map[string][]*git.Commit),
+	}
 }
 
+// stubClient resolves refs to a commit with the same SHA, unless a commit has
+// been added for the ref.
+//
+// Branches, file updates and pull requests are recorded, and the history of
+// paths can be added.
+//
+// The embedded SCM is nil, so calling an unimplemented method panics.
 type stubClient struct {
-	files map[string]string
+	git.SCM
+	files        map[string]string
+	commits      map[string]*git.Commit
+	branches     map[string]string
+	updates      map[string][]byte
+	pullRequests []*git.NewPullRequest
+	history      map[string][]*git.Commit
+}
+
+func (s stubClient) ResolveRef(ctx context.Context, repo, ref string) (*git.Commit, error) {
+	if c, ok := s.commits[key(repo, ref)]; ok {
+		return c, nil
+	}
+	return &git.Commit{SHA: ref}, nil
+}
+
+func (s *stubClient) addCommit(repo, ref string, c *git.Commit) {
+	s.commits[key(repo, ref)] = c
 }
 
 func (s stubClient) FileContents(ctx context.Context, repo, path, ref string) ([]byte, error) {
@@ -665,6 +953,68 @@ func (s *stubClient) addContents(repo, path, ref, filename string) {
 	s.files[key(repo, path, ref)] = filename
 }
 
+// addHistory records the commits that changed a path, the commits can also be
+// fetched by their SHA.
+func (s *stubClient) addHistory(repo, path string, commits ...*git.Commit) {
+	s.history[key(repo, path)] = commits
+	for _, c := range commits {
+		s.addCommit(repo, c.SHA, c)
+	}
+}
+
+func (s stubClient) ListCommits(ctx context.Context, repo, path, ref string) ([]*git.Commit, error) {
+	return s.history[key(repo, path)], nil
+}
+
+func (s stubClient) GetCommit(ctx context.Context, repo, sha string) (*git.Commit, error) {
+	if c, ok := s.commits[key(repo, sha)]; ok {
+		return c, nil
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
+
+func (s *stubClient) DefaultBranch(ctx context.Context, repo string) (string, error) {
+	return "main", nil
+}
+
+func (s *stubClient) CreateBranch(ctx context.Context, repo, branch, sha string) error {
+	if _, ok := s.branches[key(repo, branch)]; ok {
+		return git.SCMError{Status: http.StatusUnprocessableEntity}
+	}
+	s.branches[key(repo, branch)] = sha
+	return nil
+}
+
+func (s *stubClient) UpdateFile(ctx context.Context, repo, path, branch, message string, content []byte) error {
+	if _, ok := s.branches[key(repo, branch)]; !ok {
+		return git.SCMError{Status: http.StatusNotFound}
+	}
+	s.updates[key(repo, path, branch)] = content
+	return nil
+}
+
+func (s *stubClient) CreatePullRequest(ctx context.Context, repo string, pr *git.NewPullRequest) (*git.PullRequest, error) {
+	s.pullRequests = append(s.pullRequests, pr)
+	n := len(s.pullRequests)
+	return &git.PullRequest{Number: n, URL: fmt.Sprintf("https://github.com/%s/pull/%d", repo, n)}, nil
+}
+
+func (s *stubClient) FindPullRequest(ctx context.Context, repo, head string) (*git.PullRequest, error) {
+	for i, pr := range s.pullRequests {
+		if pr.Head == head {
+			return &git.PullRequest{Number: i + 1, URL: fmt.Sprintf("https://github.com/%s/pull/%d", repo, i+1)}, nil
+		}
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
+
+func assertHTTPHeader(t *testing.T, res *http.Response, name, want string) {
+	t.Helper()
+	if got := res.Header.Get(name); got != want {
+		t.Fatalf("got %s header %q, want %q", name, got, want)
+	}
+}
+
 func parseYAMLToConfig(t *testing.T, path string) *config {
 	t.Helper()
 	b, err := ioutil.ReadFile(path)
@@ -702,8 +1052,9 @@ func makeServer(t *testing.T, opts ...routerOptionFunc) (*httptest.Server, *stub
 		testKey:       "token",
 	}
 	sf := &stubClientFactory{client: newClient()}
-	var kc ctrlclient.Client
-	router := NewRouter(sf, sg, kc)
+	var kc ctrlclient.WithWatch
+	cr := credentials.NewResolver(func(string) *http.Client { return http.DefaultClient }, nil)
+	router := NewRouter(sf, sg, cr, parser.ParseFromGit, kc, testInstances(argocd.NewMock()), kinds.Default())
 	for _, o := range opts {
 		o(router)
 	}
@@ -762,23 +1113,28 @@ func assertJSONResponseHistory(t *testing.T, res *http.Response, want []envHisto
 	}
 }
 
-func assertErrorResponse(t *testing.T, res *http.Response, status int, want string) {
+func assertAPIError(t *testing.T, res *http.Response, status int, code, message string) {
 	t.Helper()
-	if res.StatusCode != status {
-		defer res.Body.Close()
-		errMsg, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			t.Fatal(err)
-		}
-		t.Fatalf("status code didn't match: %v (%s)", res.StatusCode, strings.TrimSpace(string(errMsg)))
-	}
 	defer res.Body.Close()
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if got := strings.TrimSpace(string(b)); got != want {
-		t.Fatalf("got %s, want %s", got, want)
+	if res.StatusCode != status {
+		t.Fatalf("status code didn't match: got %v, want %v (%s)", res.StatusCode, status, strings.TrimSpace(string(b)))
+	}
+	if h := res.Header.Get("Content-Type"); h != "application/json" {
+		t.Fatalf("wanted 'application/json' got %s", h)
+	}
+	got := &APIError{}
+	if err := json.Unmarshal(b, got); err != nil {
+		t.Fatalf("failed to parse %s: %s", b, err)
+	}
+	if got.Code != code {
+		t.Errorf("got code %s, want %s", got.Code, code)
+	}
+	if got.Message != message {
+		t.Errorf("got message %s, want %s", got.Message, message)
 	}
 }
 
@@ -787,13 +1143,36 @@ type stubSecretGetter struct {
 	testToken     string
 	testName      types.NamespacedName
 	testKey       string
+	testData      map[string][]byte
 }
 
 func (f *stubSecretGetter) SecretToken(ctx context.Context, authToken string, id types.NamespacedName, key string) (string, error) {
 	if id == f.testName && authToken == f.testAuthToken && key == f.testKey {
 		return f.testToken, nil
 	}
-	return "", errors.New("failed to get a secret token")
+	return "", apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, id.Name)
+}
+
+func (f *stubSecretGetter) Secret(ctx context.Context, authToken string, id types.NamespacedName) (map[string][]byte, error) {
+	if id != f.testName || authToken != f.testAuthToken {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "secrets"}, id.Name)
+	}
+	if f.testData != nil {
+		return f.testData, nil
+	}
+	return map[string][]byte{f.testKey: []byte(f.testToken)}, nil
+}
+
+type errorSecretGetter struct {
+	err error
+}
+
+func (f errorSecretGetter) SecretToken(ctx context.Context, authToken string, id types.NamespacedName, key string) (string, error) {
+	return "", f.err
+}
+
+func (f errorSecretGetter) Secret(ctx context.Context, authToken string, id types.NamespacedName) (map[string][]byte, error) {
+	return nil, f.err
 }
 
 type stubClientFactory struct {
@@ -805,8 +1184,66 @@ func (s stubClientFactory) Create(url, token string) (git.SCM, error) {
 	return s.client, nil
 }
 
+// privateClientFactory only creates clients with a token, as the repositories
+// are private.
+type privateClientFactory struct {
+	stubClientFactory
+}
+
+func (s privateClientFactory) Create(url, token string) (git.SCM, error) {
+	if token == "" {
+		return nil, git.SCMError{Status: http.StatusNotFound}
+	}
+	return s.stubClientFactory.Create(url, token)
+}
+
 func stubResourceParser(r ...*parser.Resource) parser.ResourceParser {
-	return func(path string, opts *gogit.CloneOptions) ([]*parser.Resource, error) {
+	return func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
 		return r, nil
 	}
 }
+
+// testImages parses the references into images for unnamed regular
+// containers.
+func testImages(t *testing.T, refs ...string) []parser.Image {
+	t.Helper()
+	images := []parser.Image{}
+	for _, ref := range refs {
+		i, err := parser.ParseImage(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		i.ContainerType = parser.RegularContainer
+		images = append(images, i)
+	}
+	return images
+}
+
+func TestApplicationResourcesWithRegisteredKinds(t *testing.T) {
+	app := &argoV1aplha1.Application{
+		Status: argoV1aplha1.ApplicationStatus{
+			Resources: []argoV1aplha1.ResourceStatus{
+				{Group: "serving.knative.dev", Kind: "Service", Name: "taxi", Status: "Synced", Health: &argoV1aplha1.HealthStatus{Status: "Progressing"}},
+				{Group: "apps", Kind: "StatefulSet", Name: "db", Status: "Synced", Health: &argoV1aplha1.HealthStatus{Status: "Healthy"}},
+				{Kind: "ConfigMap", Name: "config", Status: "OutOfSync", Health: &argoV1aplha1.HealthStatus{Status: "Healthy"}},
+				{Group: "example.com", Kind: "Widget", Name: "unregistered", Status: "Synced"},
+			},
+		},
+	}
+	rk := kinds.NewRegistry(
+		kinds.Kind{Group: "serving.knative.dev", Kind: "Service", ResponseGroup: "knativeServices", Health: true},
+		kinds.Kind{Group: "apps", Kind: "StatefulSet", ResponseGroup: "statefulSets", Health: true},
+		kinds.Kind{Kind: "ConfigMap", ResponseGroup: "configMaps"},
+		kinds.Kind{Kind: "Service", ResponseGroup: "services", Health: true},
+	)
+
+	want := map[string][]apiv2.ResourceHealth{
+		"knativeServices": {{Name: "taxi", Health: "Progressing", Status: "Synced"}},
+		"statefulSets":    {{Name: "db", Health: "Healthy", Status: "Synced"}},
+		"configMaps":      {{Name: "config", Status: "OutOfSync"}},
+		"services":        {},
+	}
+	if diff := cmp.Diff(want, applicationResources(rk, app)); diff != "" {
+		t.Fatalf("resources failed:\n%s", diff)
+	}
+}
@@ -0,0 +1,88 @@
+package httpapi
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+)
+
+// GetPipelinesV2 fetches the pipelines.yaml and returns the applications and
+// the environments they are deployed to.
+func (a *APIRouter) GetPipelinesV2(w http.ResponseWriter, r *http.Request) {
+	pipelines, commit, _, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if notModified(w, r, commit) {
+		return
+	}
+	marshalResponse(w, pipelinesToApplicationList(pipelines))
+}
+
+// GetApplicationV2 renders an application within a specific environment.
+func (a *APIRouter) GetApplicationV2(w http.ResponseWriter, r *http.Request) {
+	pipelines, _, creds, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	params := httprouter.ParamsFromContext(r.Context())
+	envName, appName := params.ByName("env"), params.ByName("app")
+	app, services, err := a.environmentServices(r.Context(), creds, pipelines, requestRef(r), envName, appName)
+	if err != nil {
+		log.Printf("ERROR: failed to get application data: %s", err)
+		writeError(w, newAPIError("failed to extract data", err))
+		return
+	}
+	if app == nil {
+		writeError(w, notFound("no GitOps repository is configured in pipelines.yaml"))
+		return
+	}
+	marshalResponse(w, &apiv2.EnvironmentApplication{
+		Environment: app.env.Name,
+		Cluster:     app.env.Cluster,
+		Commit:      app.commit,
+		Services:    servicesToV2(services),
+	})
+}
+
+// ListApplicationsV2 lists the Argo CD applications that are deployed from
+// the GitOps repository in the "url" query parameter, with the same filtering,
+// sorting and pagination as ListApplications.
+func (a *APIRouter) ListApplicationsV2(w http.ResponseWriter, r *http.Request) {
+	apps, repoURL, next, err := a.listApplications(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := applicationsToApplicationList(apps, repoURL)
+	resp.Continue = next
+	marshalResponse(w, resp)
+}
+
+// GetApplicationHistoryV2 returns the deployment history of an application in
+// an environment, most recent first.
+func (a *APIRouter) GetApplicationHistoryV2(w http.ResponseWriter, r *http.Request) {
+	app, err := a.applicationFromRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	params := httprouter.ParamsFromContext(r.Context())
+	marshalResponse(w, a.applicationHistory(r.Context(), app, params.ByName("env")))
+}
+
+// GetApplicationDetailsV2 returns the sync status, and the health of the
+// resources of an application in an environment.
+func (a *APIRouter) GetApplicationDetailsV2(w http.ResponseWriter, r *http.Request) {
+	app, err := a.applicationFromRequest(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	marshalResponse(w, a.applicationDetails(r.Context(), app))
+}
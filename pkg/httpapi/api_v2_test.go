@@ -0,0 +1,304 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+)
+
+func TestGetPipelinesV1(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	pipelinesURL := "https://github.com/example/gitops.git"
+
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/api/v1/pipelines?url=%s", ts.URL, pipelinesURL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertJSONResponse(t, res, map[string]interface{}{
+		"applications": []interface{}{
+			map[string]interface{}{
+				"name":         "taxi",
+				"repo_url":     "https://example.com/demo/gitops.git",
+				"environments": []interface{}{"dev"},
+			},
+		},
+	})
+}
+
+func TestGetPipelinesV2(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	pipelinesURL := "https://github.com/example/gitops.git"
+
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/api/v2/pipelines?url=%s", ts.URL, pipelinesURL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &apiv2.ApplicationList{}
+	decodeV2Response(t, res, got)
+	want := &apiv2.ApplicationList{
+		Applications: []apiv2.Application{
+			{
+				Name:         "taxi",
+				RepoURL:      "https://example.com/demo/gitops.git",
+				Environments: []apiv2.ApplicationEnvironment{{Name: "dev"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("response failed:\n%s", diff)
+	}
+}
+
+func TestGetApplicationV2(t *testing.T) {
+	testResource := &parser.Resource{
+		Group:     "apps",
+		Version:   "v1",
+		Kind:      "Deployment",
+		Name:      "test-deployment",
+		Namespace: "test-ns",
+		Labels: map[string]string{
+			nameLabel: "gitops-demo",
+		},
+		Images: testImages(t, "quay.io/example/gitops-demo:v1"),
+	}
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = stubResourceParser(testResource)
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/environments/%s/application/%s?%s", ts.URL, "dev", "taxi", options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &apiv2.EnvironmentApplication{}
+	decodeV2Response(t, res, got)
+	want := &apiv2.EnvironmentApplication{
+		Environment: "dev",
+		Cluster:     "https://dev.testing.svc",
+		Commit:      "HEAD",
+		Services: []apiv2.Service{
+			{
+				Name: "gitops-demo",
+				Source: &apiv2.Source{
+					URL:  "https://example.com/demo/gitops-demo.git",
+					Type: "example.com",
+				},
+				Images: []string{"quay.io/example/gitops-demo:v1"},
+				Resources: []apiv2.Resource{
+					{
+						Group: "apps", Version: "v1", Kind: "Deployment", Name: "test-deployment", Namespace: "test-ns",
+						Images: []apiv2.Image{
+							{
+								Reference: "quay.io/example/gitops-demo:v1", Registry: "quay.io", Repository: "example/gitops-demo",
+								Tag: "v1", ContainerType: "regular",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("response failed:\n%s", diff)
+	}
+}
+
+func TestListApplicationsV2(t *testing.T) {
+	kc := makeTestClient()
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+	})
+	for _, f := range []string{"testdata/application.yaml", "testdata/application2.yaml"} {
+		app, err := testArgoApplication(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kc.Create(context.TODO(), app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/applications?url=%s", ts.URL, "https://github.com/test-repo/gitops.git"))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &apiv2.ApplicationList{}
+	decodeV2Response(t, res, got)
+	want := &apiv2.ApplicationList{
+		Applications: []apiv2.Application{
+			{
+				Name:    "test-app",
+				RepoURL: "https://github.com/test-repo/gitops.git",
+				Environments: []apiv2.ApplicationEnvironment{
+					{Name: "dev", SyncStatus: "Synced", LastDeployed: timePtr(time.Date(2021, time.Month(5), 15, 2, 12, 13, 0, time.UTC))},
+					{Name: "production", SyncStatus: "OutOfSync", LastDeployed: timePtr(time.Date(2021, time.Month(5), 16, 1, 10, 35, 0, time.UTC))},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpTimes()); diff != "" {
+		t.Fatalf("response failed:\n%s", diff)
+	}
+}
+
+func TestGetApplicationDetailsV2(t *testing.T) {
+	kc := makeTestClient()
+	app, err := testArgoApplication("testdata/application.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD = testArgoCD(app)
+	})
+	if err := kc.Create(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
+
+	options := url.Values{
+		"url": []string{"https://github.com/test-repo/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/environment/%s/application/%s?%s", ts.URL, "dev", "test-app", options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := &apiv2.ApplicationDetails{}
+	decodeV2Response(t, res, got)
+	want := &apiv2.ApplicationDetails{
+		Environment:  "dev",
+		Cluster:      "https://kubernetes.default.svc",
+		LastDeployed: timePtr(time.Date(2021, time.Month(5), 15, 2, 12, 13, 0, time.UTC)),
+		Status:       "Synced",
+		Revision: apiv2.RevisionMeta{
+			Author:   "test",
+			Message:  "testMessage",
+			Revision: "123456789",
+		},
+		Resources: map[string][]apiv2.ResourceHealth{
+			"services":               {{Name: "taxi", Health: "Healthy", Status: "Synced"}},
+			"deployments":            {{Name: "taxi", Health: "Healthy", Status: "Synced"}},
+			"secrets":                {{Name: "testsecret", Health: "Missing", Status: "OutOfSync"}},
+			"routes":                 {{Name: "taxi", Status: "Synced"}},
+			"roleBindings":           {{Name: "argocd-admin", Status: "Synced"}},
+			"clusterRoles":           {{Name: "pipelines-clusterrole", Status: "Synced"}},
+			"clusterRoleBindings":    {{Name: "pipelines-service-role-binding", Status: "Synced"}},
+			"configMaps":             {},
+			"cronJobs":               {},
+			"daemonSets":             {},
+			"ingresses":              {},
+			"persistentVolumeClaims": {},
+			"statefulSets":           {},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpTimes()); diff != "" {
+		t.Fatalf("response failed:\n%s", diff)
+	}
+}
+
+func TestGetApplicationHistoryV2(t *testing.T) {
+	kc := makeTestClient()
+	app, err := testArgoApplication("testdata/application3.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD = testArgoCD(app)
+	})
+	if err := kc.Create(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
+
+	options := url.Values{
+		"url": []string{"https://github.com/test-repo/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/history/environment/%s/application/%s?%s", ts.URL, "dev", "app-taxi", options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := []apiv2.HistoryEntry{}
+	decodeV2Response(t, res, &got)
+	if l := len(got); l != 6 {
+		t.Fatalf("got %d history entries, want 6", l)
+	}
+	want := apiv2.HistoryEntry{
+		Author:      "test",
+		Message:     "testMessage",
+		Revision:    "a0c7298faead28f7f60a5106afbb18882ad220a7",
+		Environment: "dev",
+		RepoURL:     "https://github.com/test-repo/gitops.git",
+		DeployedAt:  timePtr(time.Date(2022, time.Month(4), 22, 17, 11, 29, 0, time.UTC)),
+	}
+	if diff := cmp.Diff(want, got[0], cmpTimes()); diff != "" {
+		t.Fatalf("response failed:\n%s", diff)
+	}
+}
+
+// testArgoCD returns an Argo CD instance that returns the same metadata for
+// all the revisions in the history of the applications.
+func testArgoCD(apps ...*argoV1aplha1.Application) *argocd.Instances {
+	ac := argocd.NewMock()
+	for _, app := range apps {
+		for _, h := range app.Status.History {
+			ac.AddRevisionMetadata(ctrlclient.ObjectKeyFromObject(app), h.Revision, &argoV1aplha1.RevisionMetadata{Author: "test", Message: "testMessage"})
+		}
+	}
+	return testInstances(ac)
+}
+
+// testInstances returns the default Argo CD instance with the client.
+func testInstances(c argocd.Client) *argocd.Instances {
+	instances := argocd.NewInstances()
+	instances.Add(argocd.DefaultInstance, c)
+	return instances
+}
+
+func decodeV2Response(t *testing.T, res *http.Response, v interface{}) {
+	t.Helper()
+	b := readBody(t, res)
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("failed to parse %s: %s", b, err)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func cmpTimes() cmp.Option {
+	return cmp.Comparer(func(x, y time.Time) bool {
+		return x.Equal(y)
+	})
+}
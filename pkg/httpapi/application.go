@@ -1,48 +1,101 @@
 package httpapi
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"path"
 	"sort"
 
-	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/redhat-developer/gitops-backend/pkg/credentials"
 	"github.com/redhat-developer/gitops-backend/pkg/parser"
 )
 
 const nameLabel = "app.kubernetes.io/name"
 
-// TODO: if the environment doesn't exist, this should return a not found error.
-func (a *APIRouter) environmentApplication(authToken string, c *config, envName, appName string) (map[string]interface{}, error) {
+func (a *APIRouter) environmentApplication(ctx context.Context, creds *credentials.Credentials, c *config, ref, envName, appName string) (map[string]interface{}, error) {
+	app, services, err := a.environmentServices(ctx, creds, c, ref, envName, appName)
+	if err != nil || app == nil {
+		return nil, err
+	}
+	appEnv := map[string]interface{}{
+		"environment": envName,
+		"cluster":     app.env.Cluster,
+		"commit":      app.commit,
+		"services":    services,
+	}
+	return appEnv, nil
+}
+
+// environmentServices renders the application in the environment from the
+// GitOps repository, and groups the resulting resources into services.
+//
+// If the configuration has no GitOps repository, no application is returned.
+func (a *APIRouter) environmentServices(ctx context.Context, creds *credentials.Credentials, c *config, ref, envName, appName string) (*renderedApplication, []responseService, error) {
+	app, err := a.renderApplication(ctx, creds, c, ref, envName, appName)
+	if err != nil || app == nil {
+		return nil, nil, err
+	}
+	services, err := parseServicesFromResources(app.env, app.resources)
+	if err != nil {
+		return nil, nil, err
+	}
+	return app, services, nil
+}
+
+// renderedApplication is an application that was rendered from a commit in
+// the GitOps repository.
+type renderedApplication struct {
+	env       *environment
+	resources []*parser.Resource
+	commit    string
+}
+
+// renderApplication renders the application in the environment from the
+// GitOps repository, at the commit that the ref resolves to.
+//
+// If the configuration has no GitOps repository, no application is returned.
+func (a *APIRouter) renderApplication(ctx context.Context, creds *credentials.Credentials, c *config, ref, envName, appName string) (*renderedApplication, error) {
 	if c.GitOpsURL == "" {
 		return nil, nil
 	}
 	env := c.findEnvironment(envName)
 	if env == nil {
-		return nil, fmt.Errorf("failed to find environment %#v", envName)
+		return nil, notFound(fmt.Sprintf("failed to find environment %#v", envName))
 	}
-	co := &git.CloneOptions{
-		Auth: &http.BasicAuth{
-			Username: "gitops",
-			Password: authToken,
-		},
-		URL: c.GitOpsURL,
+	repo, parsedRepo, err := parseURL(c.GitOpsURL)
+	if err != nil {
+		return nil, err
 	}
-	res, err := a.resourceParser(pathForApplication(appName, envName), co)
+	client, err := a.getAuthenticatedGitClient(c.GitOpsURL, creds)
 	if err != nil {
 		return nil, err
 	}
-	services, err := parseServicesFromResources(env, res)
+	commit, err := client.ResolveRef(ctx, repo, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve ref %s in the GitOps repository: %w", ref, err)
+	}
+	co, err := creds.CloneOptions(c.GitOpsURL)
 	if err != nil {
 		return nil, err
 	}
-	appEnv := map[string]interface{}{
-		"environment": envName,
-		"cluster":     c.findEnvironment(envName).Cluster,
-		"services":    services,
+	var src *parser.Source
+	if app := env.findApplication(appName); app != nil {
+		src = &app.Source
 	}
-	return appEnv, nil
+	res, err := a.parseApplication(&recentRender{
+		host: parsedRepo.Host,
+		repo: repo,
+		ref:  ref,
+		env:  envName,
+		path: pathForApplication(appName, envName),
+		src:  src,
+		opts: co,
+	}, commit.SHA)
+	if err != nil {
+		return nil, err
+	}
+	return &renderedApplication{env: env, resources: res, commit: commit.SHA}, nil
 }
 
 func pathForApplication(appName, envName string) string {
@@ -50,16 +103,20 @@ func pathForApplication(appName, envName string) string {
 }
 
 func parseServicesFromResources(env *environment, res []*parser.Resource) ([]responseService, error) {
-	serviceImages := map[string]map[string]bool{}
+	// The images are keyed by their normalized references, so that the same
+	// image isn't returned twice when it's referred to differently.
+	serviceImages := map[string]map[string]string{}
 	serviceResources := map[string][]*parser.Resource{}
 	for _, v := range res {
 		name := serviceFromLabels(v.Labels)
 		images, ok := serviceImages[name]
 		if !ok {
-			images = map[string]bool{}
+			images = map[string]string{}
 		}
 		for _, n := range v.Images {
-			images[n] = true
+			if _, ok := images[n.Normalized()]; !ok {
+				images[n.Normalized()] = n.Reference
+			}
 		}
 		serviceImages[name] = images
 		resources, ok := serviceResources[name]
@@ -89,7 +146,7 @@ func parseServicesFromResources(env *environment, res []*parser.Resource) ([]res
 		}
 		rs := responseService{
 			Name:      k,
-			Images:    keys(v),
+			Images:    references(v),
 			Resources: serviceResources[k],
 		}
 		if svcRepo != "" {
@@ -22,7 +22,7 @@ func TestParseServicesFromResources(t *testing.T) {
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: testImages(t, "bigkevmcd/go-demo:876ecb3"),
 		},
 		{
 			Version: "v1", Kind: "Service", Name: "go-demo-http",
@@ -54,7 +54,7 @@ func TestParseServicesFromResources(t *testing.T) {
 				nameLabel:   "redis",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"redis:6-alpine"},
+			Images: testImages(t, "redis:6-alpine"),
 		},
 	}
 	env := &environment{
@@ -116,7 +116,7 @@ func TestParseServicesFromResourcesReturnsSetOfImages(t *testing.T) {
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: testImages(t, "bigkevmcd/go-demo:876ecb3"),
 		},
 		{
 			Group: "apps", Version: "v1", Kind: "Deployment", Name: "go-demo-cmd",
@@ -124,7 +124,7 @@ func TestParseServicesFromResourcesReturnsSetOfImages(t *testing.T) {
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"bigkevmcd/testing:a29fcef", "bigkevmcd/go-demo:876ecb3"},
+			Images: testImages(t, "bigkevmcd/testing:a29fcef", "bigkevmcd/go-demo:876ecb3"),
 		},
 	}
 	env := &environment{
@@ -171,12 +171,49 @@ func TestParseServicesFromResourcesReturnsSetOfImages(t *testing.T) {
 	}
 }
 
+func TestParseServicesFromResourcesDeduplicatesNormalizedImages(t *testing.T) {
+	labels := map[string]string{
+		nameLabel:   "go-demo",
+		partOfLabel: "go-demo",
+	}
+	res := []*parser.Resource{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Name: "go-demo-http",
+			Labels: labels,
+			Images: testImages(t, "bigkevmcd/go-demo:876ecb3"),
+		},
+		{
+			Group: "batch", Version: "v1", Kind: "CronJob", Name: "go-demo-cleanup",
+			Labels: labels,
+			Images: testImages(t, "docker.io/bigkevmcd/go-demo:876ecb3"),
+		},
+	}
+	env := &environment{
+		Name: "test-env",
+		Apps: []*application{
+			{Name: "my-app", Services: []service{{Name: "go-demo"}}},
+		},
+	}
+
+	svcs, err := parseServicesFromResources(env, res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(svcs) != 1 {
+		t.Fatalf("got %d services, want 1", len(svcs))
+	}
+	if diff := cmp.Diff([]string{"bigkevmcd/go-demo:876ecb3"}, svcs[0].Images); diff != "" {
+		t.Fatalf("incorrect images:\n%s", diff)
+	}
+}
+
 func TestParseServicesFromResourcesIgnoresEmptyServices(t *testing.T) {
 	res := []*parser.Resource{
 		{
 			Group: "apps", Version: "v1", Kind: "Deployment", Name: "go-demo-http",
 			Labels: map[string]string{},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: testImages(t, "bigkevmcd/go-demo:876ecb3"),
 		},
 		{
 			Version: "v1", Kind: "Service", Name: "go-demo-http",
@@ -218,7 +255,7 @@ func TestParseServicesFromResourcesIgnoresUnknownServices(t *testing.T) {
 				nameLabel:   "unknown",
 				partOfLabel: "unknown",
 			},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: testImages(t, "bigkevmcd/go-demo:876ecb3"),
 		},
 	}
 
@@ -0,0 +1,108 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/julienschmidt/httprouter"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/history"
+)
+
+const (
+	// defaultCommitsLimit is the number of commits returned if no limit is
+	// requested.
+	defaultCommitsLimit = 20
+	// maxCommitsLimit is the largest number of commits that can be requested,
+	// each commit is fetched from the Git host.
+	maxCommitsLimit = 100
+)
+
+// GetApplicationCommitsV2 returns the commits in the GitOps repository that
+// changed an application in an environment, most recent first, from the
+// ref in the "ref" query parameter.
+//
+// Unlike the deployment history from Argo CD, this includes commits that
+// haven't been synced, and is available for applications that have never
+// been synced.
+func (a *APIRouter) GetApplicationCommitsV2(w http.ResponseWriter, r *http.Request) {
+	limit := defaultCommitsLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		l, err := strconv.Atoi(s)
+		if err != nil || l < 1 || l > maxCommitsLimit {
+			writeError(w, badRequest(fmt.Sprintf("invalid limit %q, must be between 1 and %d", s, maxCommitsLimit)))
+			return
+		}
+		limit = l
+	}
+	pipelines, _, creds, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	params := httprouter.ParamsFromContext(r.Context())
+	envName, appName := params.ByName("env"), params.ByName("app")
+	if pipelines.GitOpsURL == "" {
+		writeError(w, notFound("no GitOps repository is configured in pipelines.yaml"))
+		return
+	}
+	if pipelines.findEnvironment(envName) == nil {
+		writeError(w, notFound(fmt.Sprintf("failed to find environment %#v", envName)))
+		return
+	}
+	repo, _, err := parseURL(pipelines.GitOpsURL)
+	if err != nil {
+		log.Printf("ERROR: failed to parse the GitOps URL: %s", err)
+		writeError(w, newAPIError("failed to parse the GitOps repository URL", err))
+		return
+	}
+	client, err := a.getAuthenticatedGitClient(pipelines.GitOpsURL, creds)
+	if err != nil {
+		log.Printf("ERROR: failed to get an authenticated client: %s", err)
+		writeError(w, newAPIError("unable to create a Git client", err))
+		return
+	}
+	ref := requestRef(r)
+	commit, err := client.ResolveRef(r.Context(), repo, ref)
+	if err != nil {
+		log.Printf("ERROR: failed to resolve ref %#v for repo %#v: %s", ref, repo, err)
+		writeError(w, newAPIError("failed to resolve ref "+ref, err))
+		return
+	}
+	if notModified(w, r, commit) {
+		return
+	}
+
+	entries, err := history.ForApplication(r.Context(), client, repo, commit.SHA, pathForApplication(appName, envName), limit)
+	if err != nil {
+		log.Printf("ERROR: failed to get the commits for application %s in environment %s: %s", appName, envName, err)
+		writeError(w, newAPIError("failed to get the commits", err))
+		return
+	}
+	marshalResponse(w, commitsToV2(entries))
+}
+
+func commitsToV2(entries []history.Entry) []apiv2.Commit {
+	commits := []apiv2.Commit{}
+	for _, e := range entries {
+		c := apiv2.Commit{
+			SHA:            e.SHA,
+			Author:         e.Author,
+			AuthorEmail:    e.AuthorEmail,
+			Date:           e.Date,
+			Message:        e.Message,
+			Files:          e.Files,
+			FilesTruncated: e.FilesTruncated,
+			Images:         []apiv2.ImageChange{},
+			Baseline:       e.Baseline,
+		}
+		for _, i := range e.Images {
+			c.Images = append(c.Images, apiv2.ImageChange{Name: i.Name, From: i.From, To: i.To})
+		}
+		commits = append(commits, c)
+	}
+	return commits
+}
@@ -0,0 +1,137 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+func TestGetApplicationCommitsV2(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	head := &git.Commit{SHA: "6dcb09b5b57875f334f61aebed695e2e4193db5e"}
+	c.addCommit("demo/gitops", "HEAD", head)
+	updated := &git.Commit{
+		SHA: head.SHA, Date: time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC),
+		Message: "Update the image for the taxi service", Author: "Monalisa Octocat", AuthorEmail: "support@github.com",
+		Files: []string{devKustomization, "README.md"},
+	}
+	added := &git.Commit{
+		SHA: "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e", Date: time.Date(2021, time.May, 14, 10, 0, 0, 0, time.UTC),
+		Message: "Add the taxi service", Author: "Hubot", AuthorEmail: "hubot@github.com",
+		Files: []string{devKustomization},
+	}
+	c.addHistory("demo/gitops", "environments/dev/apps/taxi", updated, added)
+	c.addContents("demo/gitops", devKustomization, head.SHA, "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", devKustomization, added.SHA, "testdata/promotion/stage-kustomization.yaml")
+
+	res := getApplicationCommits(t, ts, "dev", "taxi", "", "")
+	var got []apiv2.Commit
+	decodeV2Response(t, res, &got)
+
+	want := []apiv2.Commit{
+		{
+			SHA: updated.SHA, Author: "Monalisa Octocat", AuthorEmail: "support@github.com", Date: updated.Date,
+			Message: updated.Message,
+			Files:   []string{devKustomization},
+			Images: []apiv2.ImageChange{
+				{Name: "quay.io/example/gitops-demo", From: "quay.io/example/gitops-demo:v1", To: "quay.io/example/gitops-demo:v2"},
+			},
+		},
+		{
+			SHA: added.SHA, Author: "Hubot", AuthorEmail: "hubot@github.com", Date: added.Date,
+			Message: added.Message,
+			Files:   []string{devKustomization},
+			Images: []apiv2.ImageChange{
+				{Name: "quay.io/example/gitops-demo", To: "quay.io/example/gitops-demo:v1"},
+			},
+			Baseline: true,
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("incorrect commits:\n%s", diff)
+	}
+	assertHTTPHeader(t, res, "ETag", `"`+head.SHA+`"`)
+}
+
+func TestGetApplicationCommitsV2WithNoCommits(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	res := getApplicationCommits(t, ts, "dev", "taxi", "", "")
+	var got []apiv2.Commit
+	decodeV2Response(t, res, &got)
+
+	if got == nil || len(got) != 0 {
+		t.Fatalf("got %#v, want an empty list", got)
+	}
+}
+
+func TestGetApplicationCommitsV2WithRef(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "v1", "testdata/pipelines.yaml")
+	tagged := &git.Commit{
+		SHA: "553c2077f0edc3d5dc5d17262f6aa498e69d6f8e", Date: time.Date(2021, time.May, 14, 10, 0, 0, 0, time.UTC),
+		Message: "Add the taxi service", Author: "Hubot", AuthorEmail: "hubot@github.com",
+		Files: []string{devKustomization},
+	}
+	c.addCommit("demo/gitops", "v1", tagged)
+	c.addHistory("demo/gitops", "environments/dev/apps/taxi", tagged)
+	c.addContents("demo/gitops", devKustomization, tagged.SHA, "testdata/promotion/stage-kustomization.yaml")
+
+	res := getApplicationCommits(t, ts, "dev", "taxi", "v1", "")
+	var got []apiv2.Commit
+	decodeV2Response(t, res, &got)
+
+	if len(got) != 1 || got[0].SHA != tagged.SHA {
+		t.Fatalf("got %#v, want the commit at the ref", got)
+	}
+	assertHTTPHeader(t, res, "ETag", `"`+tagged.SHA+`"`)
+}
+
+func TestGetApplicationCommitsV2Errors(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	errorTests := []struct {
+		env        string
+		limit      string
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"unknown", "", http.StatusNotFound, CodeNotFound, `failed to find environment "unknown"`},
+		{"dev", "0", http.StatusBadRequest, CodeBadRequest, `invalid limit "0", must be between 1 and 100`},
+		{"dev", "101", http.StatusBadRequest, CodeBadRequest, `invalid limit "101", must be between 1 and 100`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.env+"?limit="+tt.limit, func(t *testing.T) {
+			res := getApplicationCommits(t, ts, tt.env, "taxi", "", tt.limit)
+			assertAPIError(t, res, tt.wantStatus, tt.wantCode, tt.wantMsg)
+		})
+	}
+}
+
+func getApplicationCommits(t *testing.T, ts *httptest.Server, env, app, ref, limit string) *http.Response {
+	t.Helper()
+	q := url.Values{"url": []string{"https://github.com/example/gitops.git"}}
+	if ref != "" {
+		q.Set("ref", ref)
+	}
+	if limit != "" {
+		q.Set("limit", limit)
+	}
+	res, err := ts.Client().Do(makeClientRequest(t, "Bearer testing", ts.URL+"/api/v2/environments/"+env+"/application/"+app+"/commits?"+q.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
@@ -0,0 +1,202 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"sort"
+
+	"github.com/julienschmidt/httprouter"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+)
+
+// CompareEnvironmentsV2 renders an application in two environments from the
+// GitOps repository, and returns the differences in the images of its
+// services, and in its resources.
+func (a *APIRouter) CompareEnvironmentsV2(w http.ResponseWriter, r *http.Request) {
+	pipelines, _, creds, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	params := httprouter.ParamsFromContext(r.Context())
+	from, to, appName := params.ByName("from"), params.ByName("to"), params.ByName("app")
+
+	rendered := map[string]*renderedApplication{}
+	for _, envName := range []string{from, to} {
+		app, err := a.renderApplication(r.Context(), creds, pipelines, requestRef(r), envName, appName)
+		if err != nil {
+			log.Printf("ERROR: failed to render application %s in environment %s: %s", appName, envName, err)
+			writeError(w, newAPIError(fmt.Sprintf("failed to render the application in environment %s", envName), err))
+			return
+		}
+		if app == nil {
+			writeError(w, notFound("no GitOps repository is configured in pipelines.yaml"))
+			return
+		}
+		rendered[envName] = app
+	}
+	comparison := compareEnvironments(appName, from, to, rendered[from].resources, rendered[to].resources)
+	comparison.Commit = rendered[from].commit
+	marshalResponse(w, comparison)
+}
+
+// compareEnvironments compares the resources rendered for an application in
+// two environments.
+//
+// Resources are matched by their group, kind and name, as the namespace is
+// commonly different in each environment.
+func compareEnvironments(appName, from, to string, fromResources, toResources []*parser.Resource) *apiv2.EnvironmentComparison {
+	comparison := &apiv2.EnvironmentComparison{
+		Application: appName,
+		From:        from,
+		To:          to,
+		Services:    compareServiceImages(fromResources, toResources),
+		Resources:   []apiv2.ResourceDifference{},
+	}
+
+	type resourceKey struct{ group, kind, name string }
+	fromByKey := map[resourceKey]*parser.Resource{}
+	for _, r := range fromResources {
+		fromByKey[resourceKey{r.Group, r.Kind, r.Name}] = r
+	}
+	toByKey := map[resourceKey]*parser.Resource{}
+	for _, r := range toResources {
+		toByKey[resourceKey{r.Group, r.Kind, r.Name}] = r
+	}
+	for _, r := range fromResources {
+		if d := compareResource(r, toByKey[resourceKey{r.Group, r.Kind, r.Name}]); d != nil {
+			comparison.Resources = append(comparison.Resources, *d)
+		}
+	}
+	for _, r := range toResources {
+		if _, ok := fromByKey[resourceKey{r.Group, r.Kind, r.Name}]; !ok {
+			comparison.Resources = append(comparison.Resources, *compareResource(nil, r))
+		}
+	}
+	sort.Slice(comparison.Resources, func(i, j int) bool {
+		ri, rj := comparison.Resources[i], comparison.Resources[j]
+		if ri.Group != rj.Group {
+			return ri.Group < rj.Group
+		}
+		if ri.Kind != rj.Kind {
+			return ri.Kind < rj.Kind
+		}
+		return ri.Name < rj.Name
+	})
+	comparison.Identical = len(comparison.Services) == 0 && len(comparison.Resources) == 0
+	return comparison
+}
+
+// compareResource returns the difference between a resource in the two
+// environments, either can be nil if the resource isn't in the environment,
+// or nil if there are no differences.
+func compareResource(from, to *parser.Resource) *apiv2.ResourceDifference {
+	r := from
+	if r == nil {
+		r = to
+	}
+	d := &apiv2.ResourceDifference{
+		Group:  r.Group,
+		Kind:   r.Kind,
+		Name:   r.Name,
+		InFrom: from != nil,
+		InTo:   to != nil,
+	}
+	if from == nil || to == nil {
+		return d
+	}
+	if from.Namespace != to.Namespace {
+		d.Namespace = &apiv2.ValueDifference{From: from.Namespace, To: to.Namespace}
+	}
+	for _, k := range labelKeys(from.Labels, to.Labels) {
+		if from.Labels[k] != to.Labels[k] {
+			if d.Labels == nil {
+				d.Labels = map[string]apiv2.ValueDifference{}
+			}
+			d.Labels[k] = apiv2.ValueDifference{From: from.Labels[k], To: to.Labels[k]}
+		}
+	}
+	if d.Namespace == nil && d.Labels == nil {
+		return nil
+	}
+	return d
+}
+
+// compareServiceImages groups the images in the resources by service, and
+// returns the services that have different images in the two environments.
+//
+// Images are compared once they're normalized, so redis:6-alpine is the same
+// as docker.io/library/redis:6-alpine, and the references are returned.
+func compareServiceImages(fromResources, toResources []*parser.Resource) []apiv2.ServiceDifference {
+	fromImages, toImages := serviceImages(fromResources), serviceImages(toResources)
+	names := map[string]bool{}
+	for name := range fromImages {
+		names[name] = true
+	}
+	for name := range toImages {
+		names[name] = true
+	}
+
+	differences := []apiv2.ServiceDifference{}
+	for _, name := range keys(names) {
+		if !slices.Equal(normalizedKeys(fromImages[name]), normalizedKeys(toImages[name])) {
+			differences = append(differences, apiv2.ServiceDifference{
+				Name:       name,
+				FromImages: references(fromImages[name]),
+				ToImages:   references(toImages[name]),
+			})
+		}
+	}
+	return differences
+}
+
+// serviceImages returns the references of the images for each service, keyed
+// by their normalized references, resources without a service name label are
+// skipped.
+func serviceImages(res []*parser.Resource) map[string]map[string]string {
+	images := map[string]map[string]string{}
+	for _, r := range res {
+		name := serviceFromLabels(r.Labels)
+		if name == "" {
+			continue
+		}
+		if images[name] == nil {
+			images[name] = map[string]string{}
+		}
+		for _, image := range r.Images {
+			images[name][image.Normalized()] = image.Reference
+		}
+	}
+	return images
+}
+
+func normalizedKeys(images map[string]string) []string {
+	normalized := map[string]bool{}
+	for k := range images {
+		normalized[k] = true
+	}
+	return keys(normalized)
+}
+
+func references(images map[string]string) []string {
+	refs := map[string]bool{}
+	for _, v := range images {
+		refs[v] = true
+	}
+	return keys(refs)
+}
+
+func labelKeys(from, to map[string]string) []string {
+	all := map[string]bool{}
+	for k := range from {
+		all[k] = true
+	}
+	for k := range to {
+		all[k] = true
+	}
+	return keys(all)
+}
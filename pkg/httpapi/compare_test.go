@@ -0,0 +1,150 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/google/go-cmp/cmp"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+)
+
+func TestCompareEnvironmentsV2(t *testing.T) {
+	rendered := map[string][]*parser.Resource{
+		pathForApplication("taxi", "dev"): {
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "taxi", Namespace: "dev",
+				Labels: map[string]string{nameLabel: "taxi", "tier": "web"},
+				Images: testImages(t, "quay.io/example/taxi:v2")},
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "bus", Namespace: "dev",
+				Labels: map[string]string{nameLabel: "bus"},
+				Images: testImages(t, "quay.io/example/bus:v1")},
+			{Version: "v1", Kind: "ConfigMap", Name: "debug", Namespace: "dev"},
+		},
+		pathForApplication("taxi", "stage"): {
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "taxi", Namespace: "stage",
+				Labels: map[string]string{nameLabel: "taxi"},
+				Images: testImages(t, "quay.io/example/taxi:v1")},
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "bus", Namespace: "dev",
+				Labels: map[string]string{nameLabel: "bus"},
+				Images: testImages(t, "quay.io/example/bus:v1")},
+			{Version: "v1", Kind: "Secret", Name: "credentials", Namespace: "stage"},
+		},
+	}
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+			return rendered[path], nil
+		}
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	got := compareEnvironmentsV2(t, ts, "dev", "stage", "taxi")
+
+	want := &apiv2.EnvironmentComparison{
+		Application: "taxi",
+		From:        "dev",
+		To:          "stage",
+		Commit:      "HEAD",
+		Services: []apiv2.ServiceDifference{
+			{Name: "taxi", FromImages: []string{"quay.io/example/taxi:v2"}, ToImages: []string{"quay.io/example/taxi:v1"}},
+		},
+		Resources: []apiv2.ResourceDifference{
+			{Kind: "ConfigMap", Name: "debug", InFrom: true},
+			{Kind: "Secret", Name: "credentials", InTo: true},
+			{
+				Group: "apps", Kind: "Deployment", Name: "taxi", InFrom: true, InTo: true,
+				Namespace: &apiv2.ValueDifference{From: "dev", To: "stage"},
+				Labels:    map[string]apiv2.ValueDifference{"tier": {From: "web"}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("comparison failed:\n%s", diff)
+	}
+}
+
+func TestCompareEnvironmentsV2WithIdenticalEnvironments(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = stubResourceParser(&parser.Resource{
+			Group: "apps", Version: "v1", Kind: "Deployment", Name: "taxi",
+			Labels: map[string]string{nameLabel: "taxi"},
+			Images: testImages(t, "quay.io/example/taxi:v1"),
+		})
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	got := compareEnvironmentsV2(t, ts, "dev", "stage", "taxi")
+
+	want := &apiv2.EnvironmentComparison{
+		Application: "taxi",
+		From:        "dev",
+		To:          "stage",
+		Commit:      "HEAD",
+		Identical:   true,
+		Services:    []apiv2.ServiceDifference{},
+		Resources:   []apiv2.ResourceDifference{},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("comparison failed:\n%s", diff)
+	}
+}
+
+func TestCompareEnvironmentsV2WithNormalizedImages(t *testing.T) {
+	rendered := map[string][]*parser.Resource{
+		pathForApplication("taxi", "dev"): {
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "redis",
+				Labels: map[string]string{nameLabel: "redis"},
+				Images: testImages(t, "redis:6-alpine")},
+		},
+		pathForApplication("taxi", "stage"): {
+			{Group: "apps", Version: "v1", Kind: "Deployment", Name: "redis",
+				Labels: map[string]string{nameLabel: "redis"},
+				Images: testImages(t, "docker.io/library/redis:6-alpine")},
+		},
+	}
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = func(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+			return rendered[path], nil
+		}
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	got := compareEnvironmentsV2(t, ts, "dev", "stage", "taxi")
+
+	if !got.Identical {
+		t.Fatalf("got differences %#v, want the environments to be identical", got.Services)
+	}
+}
+
+func TestCompareEnvironmentsV2WithUnknownEnvironment(t *testing.T) {
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = stubResourceParser()
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	options := url.Values{"url": []string{"https://github.com/example/gitops.git"}}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/compare/environments/dev/production/application/taxi?%s", ts.URL, options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, `failed to find environment "production"`)
+}
+
+func compareEnvironmentsV2(t *testing.T, ts *httptest.Server, from, to, app string) *apiv2.EnvironmentComparison {
+	t.Helper()
+	options := url.Values{"url": []string{"https://github.com/example/gitops.git"}}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/compare/environments/%s/%s/application/%s?%s", ts.URL, from, to, app, options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &apiv2.EnvironmentComparison{}
+	decodeV2Response(t, res, got)
+	return got
+}
@@ -8,6 +8,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
 )
 
 // TODO: this should really import the config from the upstream and use it to
@@ -33,8 +36,11 @@ func pipelinesToAppsResponse(cfg *config) *appsResponse {
 	return &appsResponse{Apps: apps}
 }
 
+// applicationsToAppsResponse groups the applications by name, in the order
+// they are first seen.
 func applicationsToAppsResponse(appSet []*argoV1aplha1.Application, repoURL string) *appsResponse {
 	appsMap := make(map[string]appResponse)
+	var names []string
 	var appName string
 	repoURL = strings.TrimSuffix(repoURL, ".git")
 
@@ -63,6 +69,7 @@ func applicationsToAppsResponse(appSet []*argoV1aplha1.Application, repoURL stri
 			lastDeployedTime = ""
 		}
 		if appResp, ok := appsMap[appName]; !ok {
+			names = append(names, appName)
 			appsMap[appName] = appResponse{
 				Name:         appName,
 				RepoURL:      app.Spec.Source.RepoURL,
@@ -79,9 +86,150 @@ func applicationsToAppsResponse(appSet []*argoV1aplha1.Application, repoURL stri
 	}
 
 	var apps []appResponse
-	for _, app := range appsMap {
-		apps = append(apps, app)
+	for _, name := range names {
+		apps = append(apps, appsMap[name])
 	}
 
 	return &appsResponse{Apps: apps}
 }
+
+func historyToEnvHistory(history []apiv2.HistoryEntry) []envHistory {
+	historyList := make([]envHistory, 0, len(history))
+	for _, h := range history {
+		deployedAt := ""
+		if h.DeployedAt != nil {
+			deployedAt = metav1.NewTime(*h.DeployedAt).String()
+		}
+		historyList = append(historyList, envHistory{
+			Author:      h.Author,
+			Message:     h.Message,
+			Revision:    h.Revision,
+			Environment: h.Environment,
+			RepoUrl:     h.RepoURL,
+			DeployedAt:  deployedAt,
+		})
+	}
+	return historyList
+}
+
+// applicationDetailsToMap converts the details to the v1 response, where the
+// resource groups are reported alongside the fields of the application.
+func applicationDetailsToMap(d *apiv2.ApplicationDetails) map[string]interface{} {
+	lastDeployed := ""
+	if d.LastDeployed != nil {
+		lastDeployed = metav1.NewTime(*d.LastDeployed).String()
+	}
+	appEnv := map[string]interface{}{
+		"environment":  d.Environment,
+		"cluster":      d.Cluster,
+		"lastDeployed": lastDeployed,
+		"status":       d.Status,
+		"revision":     RevisionMeta(d.Revision),
+	}
+	for k, v := range d.Resources {
+		if _, ok := appEnv[k]; ok {
+			log.Printf("WARNING: the resource group %q is not reported as it is a field of the application", k)
+			continue
+		}
+		appEnv[k] = v
+	}
+	return appEnv
+}
+
+func pipelinesToApplicationList(cfg *config) *apiv2.ApplicationList {
+	apps := []apiv2.Application{}
+	for _, app := range pipelinesToAppsResponse(cfg).Apps {
+		envs := []apiv2.ApplicationEnvironment{}
+		for _, env := range app.Environments {
+			envs = append(envs, apiv2.ApplicationEnvironment{Name: env})
+		}
+		apps = append(apps, apiv2.Application{Name: app.Name, RepoURL: app.RepoURL, Environments: envs})
+	}
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name < apps[j].Name })
+	return &apiv2.ApplicationList{Applications: apps}
+}
+
+// applicationsToApplicationList groups the applications by name, in the
+// order they are first seen.
+func applicationsToApplicationList(appSet []*argoV1aplha1.Application, repoURL string) *apiv2.ApplicationList {
+	appsMap := make(map[string]*apiv2.Application)
+	var names []string
+	repoURL = strings.TrimSuffix(repoURL, ".git")
+
+	for _, app := range appSet {
+		if repoURL != strings.TrimSuffix(app.Spec.Source.RepoURL, ".git") {
+			continue
+		}
+		appName := app.ObjectMeta.Labels["app.kubernetes.io/name"]
+		if appName == "" {
+			continue
+		}
+
+		env := apiv2.ApplicationEnvironment{
+			Name:       app.Spec.Destination.Namespace,
+			SyncStatus: string(app.Status.Sync.Status),
+		}
+		if size := len(app.Status.History); size > 0 && !app.Status.History[size-1].DeployedAt.IsZero() {
+			t := app.Status.History[size-1].DeployedAt.Time
+			env.LastDeployed = &t
+		}
+		appResp, ok := appsMap[appName]
+		if !ok {
+			appResp = &apiv2.Application{Name: appName, RepoURL: app.Spec.Source.RepoURL}
+			appsMap[appName] = appResp
+			names = append(names, appName)
+		}
+		appResp.Environments = append(appResp.Environments, env)
+	}
+
+	apps := []apiv2.Application{}
+	for _, name := range names {
+		apps = append(apps, *appsMap[name])
+	}
+	return &apiv2.ApplicationList{Applications: apps}
+}
+
+func servicesToV2(services []responseService) []apiv2.Service {
+	converted := make([]apiv2.Service, 0, len(services))
+	for _, svc := range services {
+		s := apiv2.Service{
+			Name:   svc.Name,
+			Images: svc.Images,
+		}
+		if svc.Source.URL != "" {
+			s.Source = &apiv2.Source{URL: svc.Source.URL, Type: svc.Source.Type}
+		}
+		for _, r := range svc.Resources {
+			s.Resources = append(s.Resources, apiv2.Resource{
+				Group:     r.Group,
+				Version:   r.Version,
+				Kind:      r.Kind,
+				Name:      r.Name,
+				Namespace: r.Namespace,
+				Images:    imagesToV2(r.Images),
+			})
+		}
+		converted = append(converted, s)
+	}
+	sort.Slice(converted, func(i, j int) bool { return converted[i].Name < converted[j].Name })
+	return converted
+}
+
+func imagesToV2(images []parser.Image) []apiv2.Image {
+	if len(images) == 0 {
+		return nil
+	}
+	converted := make([]apiv2.Image, 0, len(images))
+	for _, i := range images {
+		converted = append(converted, apiv2.Image{
+			Reference:     i.Reference,
+			Registry:      i.Registry,
+			Repository:    i.Repository,
+			Tag:           i.Tag,
+			Digest:        i.Digest,
+			Container:     i.Container,
+			ContainerType: string(i.ContainerType),
+		})
+	}
+	return converted
+}
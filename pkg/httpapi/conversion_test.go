@@ -8,6 +8,8 @@ import (
 	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
 
 	"github.com/google/go-cmp/cmp"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
 )
 
 func TestPipelinesToAppsResponse(t *testing.T) {
@@ -92,3 +94,23 @@ func TestApplicationsToAppsResponseForTwoApps(t *testing.T) {
 		t.Fatal(fmt.Errorf("WANT[%v] != RECEIVED[%v], diff=%s", want, resp, diff))
 	}
 }
+
+func TestApplicationDetailsToMapKeepsTheApplicationFields(t *testing.T) {
+	d := &apiv2.ApplicationDetails{
+		Environment: "dev",
+		Status:      "Synced",
+		Resources: map[string][]apiv2.ResourceHealth{
+			"status":   {{Name: "widget"}},
+			"services": {{Name: "taxi"}},
+		},
+	}
+
+	m := applicationDetailsToMap(d)
+
+	if m["status"] != "Synced" {
+		t.Fatalf("got status %#v, want Synced", m["status"])
+	}
+	if diff := cmp.Diff([]apiv2.ResourceHealth{{Name: "taxi"}}, m["services"]); diff != "" {
+		t.Fatalf("incorrect services:\n%s", diff)
+	}
+}
@@ -0,0 +1,157 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+// Error codes returned in the body of error responses.
+const (
+	CodeBadRequest      = "BadRequest"
+	CodeUnauthorized    = "Unauthorized"
+	CodeForbidden       = "Forbidden"
+	CodeNotFound        = "NotFound"
+	CodeConflict        = "Conflict"
+	CodeInvalidSecret   = "InvalidSecret"
+	CodeRateLimited     = "RateLimited"
+	CodeInternalError   = "InternalError"
+	CodeUpstreamError   = "UpstreamError"
+	CodeUpstreamTimeout = "UpstreamTimeout"
+)
+
+// APIError is the error returned by the API handlers, it is written to the
+// response as JSON, with the Status as the HTTP status code.
+type APIError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Details string `json:"details,omitempty"`
+
+	// retryAfter is sent in the Retry-After header, if it's set.
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *APIError) Error() string {
+	if e.err != nil {
+		return e.Message + ": " + e.err.Error()
+	}
+	return e.Message
+}
+
+// Unwrap returns the underlying cause of the error, if any.
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+func badRequest(msg string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeBadRequest, Message: msg}
+}
+
+func notFound(msg string) *APIError {
+	return &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: msg}
+}
+
+func conflict(msg string) *APIError {
+	return &APIError{Status: http.StatusConflict, Code: CodeConflict, Message: msg}
+}
+
+// newAPIError classifies the error and returns an APIError with an
+// appropriate status and code, and the provided message.
+//
+// If err is already an APIError, it is returned unchanged.
+//
+// Only errors that are known not to leak upstream internals are copied into
+// the details, everything else is left for the logs.
+func newAPIError(msg string, err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	e := &APIError{
+		Status:  http.StatusInternalServerError,
+		Code:    CodeInternalError,
+		Message: msg,
+		err:     err,
+	}
+
+	var rateLimitErr git.RateLimitError
+	var scmErr git.SCMError
+	var statusErr apierrors.APIStatus
+	var netErr net.Error
+	switch {
+	case errors.As(err, &rateLimitErr):
+		e.Status, e.Code, e.Details = http.StatusTooManyRequests, CodeRateLimited, rateLimitErr.Error()
+		e.retryAfter = rateLimitErr.RetryAfter
+	case errors.As(err, &scmErr):
+		e.Details = scmErr.Error()
+		switch {
+		case scmErr.Status == http.StatusNotFound:
+			e.Status, e.Code = http.StatusNotFound, CodeNotFound
+		case scmErr.Status == http.StatusUnauthorized:
+			e.Status, e.Code = http.StatusUnauthorized, CodeUnauthorized
+		case scmErr.Status == http.StatusForbidden:
+			e.Status, e.Code = http.StatusForbidden, CodeForbidden
+		default:
+			e.Status, e.Code = http.StatusBadGateway, CodeUpstreamError
+		}
+	case errors.As(err, &statusErr):
+		e.Details = statusErr.Status().Message
+		switch {
+		case apierrors.IsNotFound(err):
+			e.Status, e.Code = http.StatusNotFound, CodeNotFound
+		case apierrors.IsUnauthorized(err):
+			e.Status, e.Code = http.StatusUnauthorized, CodeUnauthorized
+		case apierrors.IsForbidden(err):
+			e.Status, e.Code = http.StatusForbidden, CodeForbidden
+		case apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+			e.Status, e.Code = http.StatusGatewayTimeout, CodeUpstreamTimeout
+		case apierrors.IsServiceUnavailable(err), apierrors.IsInternalError(err):
+			e.Status, e.Code = http.StatusBadGateway, CodeUpstreamError
+		default:
+			e.Details = ""
+		}
+	case errors.Is(err, transport.ErrRepositoryNotFound):
+		e.Status, e.Code, e.Details = http.StatusNotFound, CodeNotFound, err.Error()
+	case errors.Is(err, transport.ErrAuthenticationRequired):
+		e.Status, e.Code, e.Details = http.StatusUnauthorized, CodeUnauthorized, err.Error()
+	case errors.Is(err, transport.ErrAuthorizationFailed):
+		e.Status, e.Code, e.Details = http.StatusForbidden, CodeForbidden, err.Error()
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Status, e.Code = http.StatusGatewayTimeout, CodeUpstreamTimeout
+	case errors.As(err, &netErr):
+		if netErr.Timeout() {
+			e.Status, e.Code = http.StatusGatewayTimeout, CodeUpstreamTimeout
+		} else {
+			e.Status, e.Code = http.StatusBadGateway, CodeUpstreamError
+		}
+	}
+	return e
+}
+
+// writeError writes the error to the response as a JSON body, with the status
+// code from the error.
+func writeError(w http.ResponseWriter, err error) {
+	apiErr := newAPIError("internal error", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if apiErr.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(apiErr.retryAfter.Seconds()))))
+	}
+	w.WriteHeader(apiErr.Status)
+	if err := json.NewEncoder(w).Encode(apiErr); err != nil {
+		log.Printf("failed to encode error response: %s", err)
+	}
+}
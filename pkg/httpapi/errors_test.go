@@ -0,0 +1,83 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-cmp/cmp"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+func TestNewAPIError(t *testing.T) {
+	secrets := schema.GroupResource{Resource: "secrets"}
+	errorTests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"unknown error", errors.New("failed"), http.StatusInternalServerError, CodeInternalError},
+		{"git not found", git.SCMError{Status: http.StatusNotFound}, http.StatusNotFound, CodeNotFound},
+		{"git unauthorized", git.SCMError{Status: http.StatusUnauthorized}, http.StatusUnauthorized, CodeUnauthorized},
+		{"git forbidden", git.SCMError{Status: http.StatusForbidden}, http.StatusForbidden, CodeForbidden},
+		{"git server error", git.SCMError{Status: http.StatusServiceUnavailable}, http.StatusBadGateway, CodeUpstreamError},
+		{"git rate limited", git.RateLimitError{SCMError: git.SCMError{Status: http.StatusForbidden}}, http.StatusTooManyRequests, CodeRateLimited},
+		{"wrapped git error", fmt.Errorf("wrapped: %w", git.SCMError{Status: http.StatusNotFound}), http.StatusNotFound, CodeNotFound},
+		{"secret not found", apierrors.NewNotFound(secrets, "test"), http.StatusNotFound, CodeNotFound},
+		{"secret forbidden", fmt.Errorf("wrapped: %w", apierrors.NewForbidden(secrets, "test", errors.New("denied"))), http.StatusForbidden, CodeForbidden},
+		{"kube unauthorized", apierrors.NewUnauthorized("bad token"), http.StatusUnauthorized, CodeUnauthorized},
+		{"kube timeout", apierrors.NewTimeoutError("timed out", 1), http.StatusGatewayTimeout, CodeUpstreamTimeout},
+		{"kube unavailable", apierrors.NewServiceUnavailable("unavailable"), http.StatusBadGateway, CodeUpstreamError},
+		{"repository not found", transport.ErrRepositoryNotFound, http.StatusNotFound, CodeNotFound},
+		{"clone authentication", transport.ErrAuthenticationRequired, http.StatusUnauthorized, CodeUnauthorized},
+		{"clone authorization", transport.ErrAuthorizationFailed, http.StatusForbidden, CodeForbidden},
+		{"deadline exceeded", fmt.Errorf("wrapped: %w", context.DeadlineExceeded), http.StatusGatewayTimeout, CodeUpstreamTimeout},
+		{"api error", notFound("missing"), http.StatusNotFound, CodeNotFound},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newAPIError("test message", tt.err)
+			if e.Status != tt.wantStatus {
+				t.Errorf("got status %d, want %d", e.Status, tt.wantStatus)
+			}
+			if e.Code != tt.wantCode {
+				t.Errorf("got code %s, want %s", e.Code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	writeError(w, newAPIError("failed to fetch pipelines.yaml", git.SCMError{Status: http.StatusNotFound}))
+
+	res := w.Result()
+	assertAPIError(t, res, http.StatusNotFound, CodeNotFound, "failed to fetch pipelines.yaml")
+	if diff := cmp.Diff("*", res.Header.Get("Access-Control-Allow-Origin")); diff != "" {
+		t.Fatalf("incorrect CORS header:\n%s", diff)
+	}
+}
+
+func TestWriteErrorWithRateLimit(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := git.RateLimitError{SCMError: git.SCMError{Status: http.StatusForbidden}, RetryAfter: 1500 * time.Millisecond}
+
+	writeError(w, newAPIError("failed to fetch pipelines.yaml", fmt.Errorf("wrapped: %w", err)))
+
+	res := w.Result()
+	assertAPIError(t, res, http.StatusTooManyRequests, CodeRateLimited, "failed to fetch pipelines.yaml")
+	if diff := cmp.Diff("2", res.Header.Get("Retry-After")); diff != "" {
+		t.Fatalf("incorrect Retry-After header:\n%s", diff)
+	}
+}
@@ -0,0 +1,297 @@
+package httpapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+)
+
+const (
+	sortByName         = "name"
+	sortByLastDeployed = "lastDeployed"
+
+	// maxPageSize is the largest number of applications returned in a page.
+	maxPageSize = 500
+
+	// listChunkSize is the number of Argo CD Applications requested from the
+	// API server in each list call.
+	listChunkSize = 500
+
+	appNameLabel = "app.kubernetes.io/name"
+)
+
+// applicationFilter selects Argo CD Applications from the query parameters in
+// a request.
+//
+// The label selector, and the app name, which is the "app.kubernetes.io/name"
+// label, are passed to the API server. The other fields are in the spec or
+// status of the Application, which can't be selected by the API server for
+// custom resources, and are matched after listing.
+type applicationFilter struct {
+	repoURL    string
+	env        string
+	app        string
+	syncStatus string
+	health     string
+	project    string
+	selector   labels.Selector
+	// argoCD is the instance that must manage the Application, if one was
+	// selected, instances is used to find the instance for each Application.
+	argoCD    *argocd.Instance
+	instances *argocd.Instances
+}
+
+func applicationFilterFromQuery(v url.Values, instances *argocd.Instances) (applicationFilter, error) {
+	parsedRepoURL, err := repoURLFromQuery(v)
+	if err != nil {
+		return applicationFilter{}, err
+	}
+	f := applicationFilter{
+		repoURL:    parsedRepoURL.String(),
+		env:        v.Get("env"),
+		app:        v.Get("app"),
+		syncStatus: v.Get("sync"),
+		health:     v.Get("health"),
+		project:    v.Get("project"),
+		instances:  instances,
+	}
+	f.argoCD, err = instanceFromQuery(v, instances)
+	if err != nil {
+		return applicationFilter{}, err
+	}
+	if s := v.Get("selector"); s != "" {
+		f.selector, err = labels.Parse(s)
+		if err != nil {
+			e := badRequest(fmt.Sprintf("invalid label selector %q", s))
+			e.Details = err.Error()
+			return applicationFilter{}, e
+		}
+	}
+	return f, nil
+}
+
+// listOptions returns the options for listing or watching the Applications
+// that can match the filter.
+//
+// Only Applications with an "app.kubernetes.io/name" label are listed, and if
+// an app was selected, only those with it as the label.
+func (f applicationFilter) listOptions() []ctrlclient.ListOption {
+	return []ctrlclient.ListOption{
+		ctrlclient.InNamespace(""),
+		ctrlclient.MatchingLabelsSelector{Selector: f.labelSelector()},
+	}
+}
+
+// labelSelector returns the selector in the filter, with the requirements for
+// the "app.kubernetes.io/name" label.
+func (f applicationFilter) labelSelector() labels.Selector {
+	selector := labels.Everything()
+	if f.selector != nil {
+		selector = f.selector
+	}
+	op, values := selection.Exists, []string(nil)
+	if f.app != "" {
+		op, values = selection.Equals, []string{f.app}
+	}
+	r, err := labels.NewRequirement(appNameLabel, op, values)
+	if err != nil {
+		// The app is not a valid label value, so no Application has it.
+		return labels.Nothing()
+	}
+	return selector.Add(*r)
+}
+
+// matches returns true if the Application matches all the fields in the
+// filter.
+//
+// The label requirements are passed to the API server in listOptions, but
+// they're matched here too, as watch events are matched again.
+func (f applicationFilter) matches(app *argoV1aplha1.Application) bool {
+	if strings.TrimSuffix(app.Spec.GetSource().RepoURL, ".git") != strings.TrimSuffix(f.repoURL, ".git") {
+		return false
+	}
+	if f.env != "" && app.Spec.Destination.Namespace != f.env {
+		return false
+	}
+	if !f.labelSelector().Matches(labels.Set(app.Labels)) {
+		return false
+	}
+	if f.syncStatus != "" && string(app.Status.Sync.Status) != f.syncStatus {
+		return false
+	}
+	if f.health != "" && string(app.Status.Health.Status) != f.health {
+		return false
+	}
+	if f.project != "" && app.Spec.Project != f.project {
+		return false
+	}
+	if f.argoCD != nil && !managedBy(f.instances, *f.argoCD, app) {
+		return false
+	}
+	return true
+}
+
+// instanceFromQuery returns the Argo CD instance in the "argocd" query
+// parameter, in the form "namespace/name", or nil if no instance was
+// selected.
+func instanceFromQuery(v url.Values, instances *argocd.Instances) (*argocd.Instance, error) {
+	s := v.Get("argocd")
+	if s == "" {
+		return nil, nil
+	}
+	namespace, name, _ := strings.Cut(s, "/")
+	i, ok := instances.Lookup(namespace, name)
+	if !ok {
+		return nil, badRequest(fmt.Sprintf("unknown Argo CD instance %q", s))
+	}
+	return &i, nil
+}
+
+// managedBy returns true if the Application is managed by the Argo CD
+// instance.
+func managedBy(instances *argocd.Instances, i argocd.Instance, app *argoV1aplha1.Application) bool {
+	managing, ok := instances.ForApplication(ctrlclient.ObjectKeyFromObject(app))
+	return ok && managing.String() == i.String()
+}
+
+// applicationPage is the sorting and pagination of a list of applications.
+//
+// Argo CD Applications are grouped into applications by their
+// "app.kubernetes.io/name" label, the groups are sorted and paginated.
+type applicationPage struct {
+	sortBy string
+	limit  int
+	after  *pageCursor
+}
+
+// pageCursor is the position of the last application in a page, the next
+// page starts after it.
+//
+// Cursors are opaque to clients, and remain valid if applications are added
+// or removed.
+//
+// Applications are grouped and sorted across all the matching Argo CD
+// Applications, so every page lists all of them from the API server, and the
+// cursor is an offset into the sorted groups, it isn't passed to the API
+// server.
+type pageCursor struct {
+	SortBy       string    `json:"s"`
+	Name         string    `json:"n"`
+	LastDeployed time.Time `json:"t,omitempty"`
+}
+
+func applicationPageFromQuery(v url.Values) (applicationPage, error) {
+	p := applicationPage{sortBy: sortByName}
+	if s := v.Get("sort"); s != "" {
+		if s != sortByName && s != sortByLastDeployed {
+			return p, badRequest(fmt.Sprintf("invalid sort %q, must be %q or %q", s, sortByName, sortByLastDeployed))
+		}
+		p.sortBy = s
+	}
+	if s := v.Get("limit"); s != "" {
+		limit, err := strconv.Atoi(s)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return p, badRequest(fmt.Sprintf("invalid limit %q, must be between 1 and %d", s, maxPageSize))
+		}
+		p.limit = limit
+	}
+	if s := v.Get("continue"); s != "" {
+		c, err := decodeCursor(s)
+		if err != nil || c.SortBy != p.sortBy {
+			return p, badRequest("invalid continue token")
+		}
+		p.after = c
+	}
+	return p, nil
+}
+
+// apply sorts the Applications by group, and returns the Applications in the
+// groups in the page, with the continue token for the next page, if there is
+// one.
+func (p applicationPage) apply(apps []*argoV1aplha1.Application) ([]*argoV1aplha1.Application, string) {
+	groups := map[string][]*argoV1aplha1.Application{}
+	var cursors []pageCursor
+	for _, app := range apps {
+		name := app.Labels[appNameLabel]
+		if _, ok := groups[name]; !ok {
+			cursors = append(cursors, pageCursor{SortBy: p.sortBy, Name: name})
+		}
+		groups[name] = append(groups[name], app)
+	}
+	for i := range cursors {
+		if p.sortBy == sortByLastDeployed {
+			cursors[i].LastDeployed = groupLastDeployed(groups[cursors[i].Name])
+		}
+	}
+	sort.Slice(cursors, func(i, j int) bool {
+		return cursors[i].before(cursors[j])
+	})
+
+	start := 0
+	if p.after != nil {
+		start = sort.Search(len(cursors), func(i int) bool {
+			return p.after.before(cursors[i])
+		})
+	}
+	end := len(cursors)
+	if p.limit > 0 && start+p.limit < end {
+		end = start + p.limit
+	}
+
+	page := []*argoV1aplha1.Application{}
+	for _, c := range cursors[start:end] {
+		page = append(page, groups[c.Name]...)
+	}
+	if end == len(cursors) {
+		return page, ""
+	}
+	return page, encodeCursor(cursors[end-1])
+}
+
+// before returns true if the group at c is sorted before the group at o.
+//
+// Groups are sorted by the most recently deployed first, when sorting by the
+// last deployment, and then by name.
+func (c pageCursor) before(o pageCursor) bool {
+	if c.SortBy == sortByLastDeployed && !c.LastDeployed.Equal(o.LastDeployed) {
+		return c.LastDeployed.After(o.LastDeployed)
+	}
+	return c.Name < o.Name
+}
+
+func groupLastDeployed(apps []*argoV1aplha1.Application) time.Time {
+	var last time.Time
+	for _, app := range apps {
+		if size := len(app.Status.History); size > 0 && app.Status.History[size-1].DeployedAt.After(last) {
+			last = app.Status.History[size-1].DeployedAt.Time
+		}
+	}
+	return last.UTC()
+}
+
+func encodeCursor(c pageCursor) string {
+	// The cursor fields can always be marshaled.
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeCursor(s string) (*pageCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	c := &pageCursor{}
+	return c, json.Unmarshal(b, c)
+}
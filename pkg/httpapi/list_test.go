@@ -0,0 +1,276 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+)
+
+func TestListApplicationsV2WithFilters(t *testing.T) {
+	kc := makeTestClient()
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+	})
+	for _, app := range []*argoV1aplha1.Application{
+		makeTestApplication("dev", "taxi", "Synced", time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)),
+		makeTestApplication("production", "taxi", "OutOfSync", time.Date(2021, time.May, 16, 1, 10, 35, 0, time.UTC)),
+		makeTestApplication("dev", "bus", "OutOfSync", time.Date(2021, time.May, 14, 1, 10, 35, 0, time.UTC)),
+	} {
+		if err := kc.Create(context.TODO(), app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filterTests := []struct {
+		name      string
+		query     url.Values
+		wantNames []string
+	}{
+		{"no filters", url.Values{}, []string{"bus", "taxi"}},
+		{"environment", url.Values{"env": {"production"}}, []string{"taxi"}},
+		{"sync status", url.Values{"sync": {"OutOfSync"}}, []string{"bus", "taxi"}},
+		{"sync status and environment", url.Values{"sync": {"OutOfSync"}, "env": {"dev"}}, []string{"bus"}},
+		{"health", url.Values{"health": {"Degraded"}}, []string{}},
+		{"project", url.Values{"project": {"other"}}, []string{}},
+		{"label selector", url.Values{"selector": {"app.kubernetes.io/name in (bus)"}}, []string{"bus"}},
+		{"sort by last deployed", url.Values{"sort": {"lastDeployed"}}, []string{"taxi", "bus"}},
+	}
+
+	for _, tt := range filterTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Set("url", "https://github.com/test-repo/gitops.git")
+			got := listApplicationsV2(t, ts, tt.query)
+			if diff := cmp.Diff(tt.wantNames, applicationNames(got)); diff != "" {
+				t.Fatalf("applications failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListApplicationsV2Pagination(t *testing.T) {
+	kc := makeTestClient()
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+	})
+	for i, name := range []string{"taxi", "bus", "tram", "ferry", "train"} {
+		for _, env := range []string{"dev", "production"} {
+			app := makeTestApplication(env, name, "Synced", time.Date(2021, time.May, i+1, 0, 0, 0, 0, time.UTC))
+			if err := kc.Create(context.TODO(), app); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	paginationTests := []struct {
+		sort  string
+		pages [][]string
+	}{
+		{"name", [][]string{{"bus", "ferry"}, {"taxi", "train"}, {"tram"}}},
+		{"lastDeployed", [][]string{{"train", "ferry"}, {"tram", "bus"}, {"taxi"}}},
+	}
+
+	for _, tt := range paginationTests {
+		t.Run(tt.sort, func(t *testing.T) {
+			query := url.Values{
+				"url":   {"https://github.com/test-repo/gitops.git"},
+				"sort":  {tt.sort},
+				"limit": {"2"},
+			}
+			var pages [][]string
+			for {
+				got := listApplicationsV2(t, ts, query)
+				for _, app := range got.Applications {
+					if l := len(app.Environments); l != 2 {
+						t.Fatalf("application %s has %d environments, want 2", app.Name, l)
+					}
+				}
+				pages = append(pages, applicationNames(got))
+				if got.Continue == "" {
+					break
+				}
+				query.Set("continue", got.Continue)
+			}
+			if diff := cmp.Diff(tt.pages, pages); diff != "" {
+				t.Fatalf("pages failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListApplicationsV2WithArgoCDInstance(t *testing.T) {
+	kc := makeTestClient()
+	teamA := argocd.Instance{Name: "argocd", Namespace: "team-a"}
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD.Add(teamA, argocd.NewMock())
+	})
+	taxi := makeTestApplication("dev", "taxi", "Synced", time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC))
+	bus := makeTestApplication("dev", "bus", "Synced", time.Date(2021, time.May, 14, 1, 10, 35, 0, time.UTC))
+	bus.Namespace = teamA.Namespace
+	for _, app := range []*argoV1aplha1.Application{taxi, bus} {
+		if err := kc.Create(context.TODO(), app); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	instanceTests := []struct {
+		instance  string
+		wantNames []string
+	}{
+		{"", []string{"bus", "taxi"}},
+		{"team-a/argocd", []string{"bus"}},
+		{"openshift-gitops/openshift-gitops", []string{"taxi"}},
+	}
+
+	for _, tt := range instanceTests {
+		t.Run(tt.instance, func(t *testing.T) {
+			query := url.Values{"url": {"https://github.com/test-repo/gitops.git"}}
+			if tt.instance != "" {
+				query.Set("argocd", tt.instance)
+			}
+			got := listApplicationsV2(t, ts, query)
+			if diff := cmp.Diff(tt.wantNames, applicationNames(got)); diff != "" {
+				t.Fatalf("applications failed:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestListApplicationsWithInvalidQuery(t *testing.T) {
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = makeTestClient()
+	})
+	nameCursor := encodeCursor(pageCursor{SortBy: sortByName, Name: "taxi"})
+
+	invalidTests := []struct {
+		query   url.Values
+		wantMsg string
+	}{
+		{url.Values{"sort": {"date"}}, `invalid sort "date", must be "name" or "lastDeployed"`},
+		{url.Values{"limit": {"0"}}, `invalid limit "0", must be between 1 and 500`},
+		{url.Values{"limit": {"ten"}}, `invalid limit "ten", must be between 1 and 500`},
+		{url.Values{"continue": {"!!!"}}, "invalid continue token"},
+		{url.Values{"continue": {nameCursor}, "sort": {"lastDeployed"}}, "invalid continue token"},
+		{url.Values{"selector": {"a in b"}}, `invalid label selector "a in b"`},
+		{url.Values{"argocd": {"team-a/argocd"}}, `unknown Argo CD instance "team-a/argocd"`},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.query.Encode(), func(t *testing.T) {
+			tt.query.Set("url", "https://github.com/test-repo/gitops.git")
+			req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/applications?%s", ts.URL, tt.query.Encode()))
+			res, err := ts.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertAPIError(t, res, http.StatusBadRequest, CodeBadRequest, tt.wantMsg)
+		})
+	}
+}
+
+func TestApplicationFilter(t *testing.T) {
+	app, err := testArgoApplication("testdata/application3.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoURL := "https://github.com/test-repo/gitops.git"
+	filterTests := []struct {
+		filter applicationFilter
+		want   bool
+	}{
+		{applicationFilter{repoURL: repoURL}, true},
+		{applicationFilter{repoURL: "https://github.com/test-repo/other.git"}, false},
+		{applicationFilter{repoURL: repoURL, env: "dev"}, true},
+		{applicationFilter{repoURL: repoURL, env: "production"}, false},
+		{applicationFilter{repoURL: repoURL, app: "app-taxi"}, true},
+		{applicationFilter{repoURL: repoURL, app: "dev-app-taxi"}, false},
+		{applicationFilter{repoURL: repoURL, app: "app-bus"}, false},
+	}
+
+	for _, tt := range filterTests {
+		if got := tt.filter.matches(app); got != tt.want {
+			t.Errorf("%#v got %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestApplicationFilterLabelSelector(t *testing.T) {
+	selectorTests := []struct {
+		filter applicationFilter
+		want   string
+	}{
+		{applicationFilter{}, "app.kubernetes.io/name"},
+		{applicationFilter{app: "taxi"}, "app.kubernetes.io/name=taxi"},
+		{applicationFilter{app: "taxi", selector: labels.SelectorFromSet(labels.Set{"team": "a"})}, "app.kubernetes.io/name=taxi,team=a"},
+		{applicationFilter{app: "not a label value"}, labels.Nothing().String()},
+	}
+
+	for _, tt := range selectorTests {
+		if got := tt.filter.labelSelector().String(); got != tt.want {
+			t.Errorf("%#v got %q, want %q", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestPageCursorRoundTrip(t *testing.T) {
+	c := pageCursor{SortBy: sortByLastDeployed, Name: "taxi", LastDeployed: time.Date(2021, time.May, 15, 2, 12, 13, 0, time.UTC)}
+
+	got, err := decodeCursor(encodeCursor(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(&c, got); diff != "" {
+		t.Fatalf("cursor failed:\n%s", diff)
+	}
+}
+
+func listApplicationsV2(t *testing.T, ts *httptest.Server, query url.Values) *apiv2.ApplicationList {
+	t.Helper()
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/api/v2/applications?%s", ts.URL, query.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &apiv2.ApplicationList{}
+	decodeV2Response(t, res, got)
+	return got
+}
+
+func applicationNames(l *apiv2.ApplicationList) []string {
+	names := []string{}
+	for _, app := range l.Applications {
+		names = append(names, app.Name)
+	}
+	return names
+}
+
+func makeTestApplication(env, name, syncStatus string, deployedAt time.Time) *argoV1aplha1.Application {
+	return &argoV1aplha1.Application{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      env + "-" + name,
+			Namespace: "test-namespace",
+			Labels:    map[string]string{appNameLabel: name},
+		},
+		Spec: argoV1aplha1.ApplicationSpec{
+			Project:     "default",
+			Destination: argoV1aplha1.ApplicationDestination{Namespace: env, Server: "https://kubernetes.default.svc"},
+			Source:      &argoV1aplha1.ApplicationSource{RepoURL: "https://github.com/test-repo/gitops.git"},
+		},
+		Status: argoV1aplha1.ApplicationStatus{
+			Sync:    argoV1aplha1.SyncStatus{Status: argoV1aplha1.SyncStatusCode(syncStatus)},
+			History: argoV1aplha1.RevisionHistories{{DeployedAt: metav1.NewTime(deployedAt)}},
+		},
+	}
+}
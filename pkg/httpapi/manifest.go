@@ -1,5 +1,7 @@
 package httpapi
 
+import "github.com/redhat-developer/gitops-backend/pkg/parser"
+
 type appResponse struct {
 	Name         string   `json:"name,omitempty"`
 	RepoURL      string   `json:"repo_url,omitempty"`
@@ -9,7 +11,8 @@ type appResponse struct {
 }
 
 type appsResponse struct {
-	Apps []appResponse `json:"applications"`
+	Apps     []appResponse `json:"applications"`
+	Continue string        `json:"continue,omitempty"`
 }
 
 type config struct {
@@ -26,6 +29,9 @@ type environment struct {
 type application struct {
 	Name     string    `json:"name,omitempty"`
 	Services []service `json:"services,omitempty"`
+	// Source configures how the application is read, if it's a directory of
+	// manifests, or a Helm chart.
+	parser.Source `json:",inline"`
 }
 
 type envHealthResource struct {
@@ -59,6 +65,15 @@ type service struct {
 	SourceURL string `json:"source_url"`
 }
 
+func (e environment) findApplication(n string) *application {
+	for _, a := range e.Apps {
+		if a.Name == n {
+			return a
+		}
+	}
+	return nil
+}
+
 func (c *config) findEnvironment(n string) *environment {
 	for _, e := range c.Environments {
 		if e.Name == n {
@@ -0,0 +1,22 @@
+package httpapi
+
+import (
+	_ "embed"
+	"log"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 specification for the routes served by the
+// APIRouter.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPIHandler serves the OpenAPI specification for the API.
+func OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if _, err := w.Write(openAPISpec); err != nil {
+		log.Printf("failed to write OpenAPI specification: %s", err)
+	}
+}
@@ -0,0 +1,337 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+	"github.com/redhat-developer/gitops-backend/pkg/webhooks"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	OpenAPIHandler(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	res := w.Result()
+	spec := map[string]interface{}{}
+	if err := json.Unmarshal(readBody(t, res), &spec); err != nil {
+		t.Fatal(err)
+	}
+	if v := spec["openapi"]; v != "3.0.3" {
+		t.Fatalf("got OpenAPI version %v, want 3.0.3", v)
+	}
+}
+
+func TestOpenAPISpecCoversRoutes(t *testing.T) {
+	spec := loadSpec(t)
+	router := NewRouter(nil, nil, nil, nil, nil, nil, nil)
+
+	routes := map[string]bool{}
+	for _, r := range router.routes() {
+		p := specPath(r.path)
+		routes[p] = true
+		if _, ok := spec.operation(p, r.method); !ok {
+			t.Errorf("route %s %s is not described in the OpenAPI specification", r.method, p)
+		}
+	}
+	// The webhooks are handled outside of the router.
+	for _, p := range webhooks.Paths() {
+		routes[p] = true
+		if _, ok := spec.operation(p, http.MethodPost); !ok {
+			t.Errorf("webhook POST %s is not described in the OpenAPI specification", p)
+		}
+	}
+	for p := range spec.paths() {
+		if p == "/openapi.json" {
+			continue
+		}
+		if !routes[p] {
+			t.Errorf("path %s in the OpenAPI specification is not routed", p)
+		}
+	}
+}
+
+// TestResponsesMatchSpec makes requests to each of the routes, and validates
+// the responses against the schemas in the OpenAPI specification.
+func TestResponsesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	testResource := &parser.Resource{
+		Group: "apps", Version: "v1", Kind: "Deployment", Name: "test-deployment", Namespace: "test-ns",
+		Labels: map[string]string{nameLabel: "gitops-demo"},
+		Images: testImages(t, "quay.io/example/gitops-demo:v1"),
+	}
+	kc := makeTestClient()
+	var apps []*argoV1aplha1.Application
+	for _, f := range []string{"testdata/application.yaml", "testdata/application2.yaml", "testdata/application3.yaml"} {
+		app, err := testArgoApplication(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kc.Create(context.TODO(), app); err != nil {
+			t.Fatal(err)
+		}
+		apps = append(apps, app)
+	}
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.k8sClient = kc
+		a.resourceParser = stubResourceParser(testResource)
+		a.argoCD = testArgoCD(apps...)
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addHistory("demo/gitops", "environments/dev/apps/taxi", &git.Commit{
+		SHA: "6dcb09b5b57875f334f61aebed695e2e4193db5e", Message: "Update the image for the taxi service",
+		Author: "Monalisa Octocat", Files: []string{devKustomization},
+	})
+	c.addContents("demo/gitops", devKustomization, "6dcb09b5b57875f334f61aebed695e2e4193db5e", "testdata/promotion/dev-kustomization.yaml")
+	gitOpsURL := url.Values{"url": []string{"https://github.com/example/gitops.git"}}.Encode()
+	argoURL := url.Values{"url": []string{"https://github.com/test-repo/gitops.git"}}.Encode()
+
+	requestTests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{"/pipelines?" + gitOpsURL, http.StatusOK},
+		{"/pipelines", http.StatusBadRequest},
+		{"/applications?" + argoURL, http.StatusOK},
+		{"/environments/dev/application/taxi?" + gitOpsURL, http.StatusOK},
+		{"/environments/unknown/application/taxi?" + gitOpsURL, http.StatusNotFound},
+		{"/environment/dev/application/test-app?" + argoURL, http.StatusOK},
+		{"/history/environment/dev/application/app-taxi?" + argoURL, http.StatusOK},
+		{"/api/v1/pipelines?" + gitOpsURL, http.StatusOK},
+		{"/api/v1/applications?" + argoURL, http.StatusOK},
+		{"/api/v1/environments/dev/application/taxi?" + gitOpsURL, http.StatusOK},
+		{"/api/v1/environment/dev/application/test-app?" + argoURL, http.StatusOK},
+		{"/api/v1/history/environment/dev/application/app-taxi?" + argoURL, http.StatusOK},
+		{"/api/v2/pipelines?" + gitOpsURL, http.StatusOK},
+		{"/api/v2/applications?" + argoURL, http.StatusOK},
+		{"/api/v2/environments/dev/application/taxi?" + gitOpsURL, http.StatusOK},
+		{"/api/v2/environments/dev/application/taxi/commits?" + gitOpsURL, http.StatusOK},
+		{"/api/v2/environments/unknown/application/taxi/commits?" + gitOpsURL, http.StatusNotFound},
+		{"/api/v2/environment/dev/application/test-app?" + argoURL, http.StatusOK},
+		{"/api/v2/environment/dev/application/unknown?" + argoURL, http.StatusNotFound},
+		{"/api/v2/history/environment/dev/application/app-taxi?" + argoURL, http.StatusOK},
+		{"/api/v2/compare/environments/dev/stage/application/taxi?" + gitOpsURL, http.StatusOK},
+		{"/api/v2/compare/environments/dev/unknown/application/taxi?" + gitOpsURL, http.StatusNotFound},
+	}
+
+	for _, tt := range requestTests {
+		t.Run(tt.path, func(t *testing.T) {
+			res, err := ts.Client().Do(makeClientRequest(t, "Bearer testing", ts.URL+tt.path))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer res.Body.Close()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, b)
+			}
+			schema := spec.responseSchema(t, http.MethodGet, strings.Split(tt.path, "?")[0], res.StatusCode)
+			var body interface{}
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Fatalf("failed to parse %s: %s", b, err)
+			}
+			if errs := spec.validate(schema, body, "$"); len(errs) > 0 {
+				t.Fatalf("response does not match the specification:\n%s\n%s", strings.Join(errs, "\n"), b)
+			}
+		})
+	}
+}
+
+// TestPromotionResponsesMatchSpec validates the responses to promotions
+// against the schemas in the OpenAPI specification.
+func TestPromotionResponsesMatchSpec(t *testing.T) {
+	spec := loadSpec(t)
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addContents("demo/gitops", devKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", stageKustomization, "main", "testdata/promotion/stage-kustomization.yaml")
+
+	requestTests := []struct {
+		path       string
+		to         string
+		wantStatus int
+	}{
+		{"/api/v2/environments/dev/application/taxi/promote", "stage", http.StatusCreated},
+		{"/api/v2/environments/dev/application/bus/promote", "stage", http.StatusConflict},
+		{"/api/v2/environments/dev/application/taxi/promote", "", http.StatusBadRequest},
+	}
+
+	for _, tt := range requestTests {
+		t.Run(tt.path+"?to="+tt.to, func(t *testing.T) {
+			res := promoteApplication(t, ts, tt.path, tt.to)
+			defer res.Body.Close()
+			b, err := ioutil.ReadAll(res.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.StatusCode != tt.wantStatus {
+				t.Fatalf("got status %d, want %d: %s", res.StatusCode, tt.wantStatus, b)
+			}
+			schema := spec.responseSchema(t, http.MethodPost, tt.path, res.StatusCode)
+			var body interface{}
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Fatalf("failed to parse %s: %s", b, err)
+			}
+			if errs := spec.validate(schema, body, "$"); len(errs) > 0 {
+				t.Fatalf("response does not match the specification:\n%s\n%s", strings.Join(errs, "\n"), b)
+			}
+		})
+	}
+}
+
+type openAPISpecification map[string]interface{}
+
+func loadSpec(t *testing.T) openAPISpecification {
+	t.Helper()
+	spec := openAPISpecification{}
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		t.Fatalf("failed to parse the OpenAPI specification: %s", err)
+	}
+	return spec
+}
+
+func (s openAPISpecification) paths() map[string]interface{} {
+	return s["paths"].(map[string]interface{})
+}
+
+func (s openAPISpecification) operation(path, method string) (map[string]interface{}, bool) {
+	item, ok := s.paths()[path].(map[string]interface{})
+	if !ok {
+		return nil, false
+	}
+	op, ok := item[strings.ToLower(method)].(map[string]interface{})
+	return op, ok
+}
+
+// responseSchema finds the schema for the response to a request path, by
+// matching the path against the templated paths in the specification.
+func (s openAPISpecification) responseSchema(t *testing.T, method, requestPath string, status int) map[string]interface{} {
+	t.Helper()
+	var templates []string
+	for p := range s.paths() {
+		templates = append(templates, p)
+	}
+	sort.Strings(templates)
+	for _, p := range templates {
+		re := regexp.MustCompile("^" + regexp.MustCompile(`\{[^}]+\}`).ReplaceAllString(p, "[^/]+") + "$")
+		if !re.MatchString(requestPath) {
+			continue
+		}
+		op, ok := s.operation(p, method)
+		if !ok {
+			t.Fatalf("no %s operation for %s", method, p)
+		}
+		responses := op["responses"].(map[string]interface{})
+		res, ok := responses[fmt.Sprint(status)].(map[string]interface{})
+		if !ok {
+			t.Fatalf("no response for status %d in %s %s", status, method, p)
+		}
+		res = s.resolve(res)
+		return res["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	}
+	t.Fatalf("no path in the specification matches %s", requestPath)
+	return nil
+}
+
+func (s openAPISpecification) resolve(v map[string]interface{}) map[string]interface{} {
+	ref, ok := v["$ref"].(string)
+	if !ok {
+		return v
+	}
+	var node interface{} = map[string]interface{}(s)
+	for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+		node = node.(map[string]interface{})[part]
+	}
+	return s.resolve(node.(map[string]interface{}))
+}
+
+// validate implements the subset of schema validation used in the
+// specification, and returns a description of each mismatch.
+func (s openAPISpecification) validate(schema map[string]interface{}, v interface{}, path string) []string {
+	schema = s.resolve(schema)
+	if v == nil {
+		if nullable, _ := schema["nullable"].(bool); nullable {
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: is null", path)}
+	}
+	var errs []string
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %T, want object", path, v)}
+		}
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				if _, ok := obj[r.(string)]; !ok {
+					errs = append(errs, fmt.Sprintf("%s: missing required property %s", path, r))
+				}
+			}
+		}
+		props, _ := schema["properties"].(map[string]interface{})
+		for k, pv := range obj {
+			if ps, ok := props[k].(map[string]interface{}); ok {
+				errs = append(errs, s.validate(ps, pv, path+"."+k)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case bool:
+				if !additional {
+					errs = append(errs, fmt.Sprintf("%s: unexpected property %s", path, k))
+				}
+			case map[string]interface{}:
+				errs = append(errs, s.validate(additional, pv, path+"."+k)...)
+			}
+		}
+	case "array":
+		items, ok := v.([]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: got %T, want array", path, v)}
+		}
+		for i, item := range items {
+			errs = append(errs, s.validate(schema["items"].(map[string]interface{}), item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return []string{fmt.Sprintf("%s: got %T, want string", path, v)}
+		}
+		if enum, ok := schema["enum"].([]interface{}); ok {
+			for _, e := range enum {
+				if e == v {
+					return nil
+				}
+			}
+			errs = append(errs, fmt.Sprintf("%s: %v is not one of %v", path, v, enum))
+		}
+	case "integer", "number":
+		if _, ok := v.(float64); !ok {
+			return []string{fmt.Sprintf("%s: got %T, want number", path, v)}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return []string{fmt.Sprintf("%s: got %T, want boolean", path, v)}
+		}
+	}
+	return errs
+}
+
+// specPath converts an httprouter path to an OpenAPI templated path.
+func specPath(p string) string {
+	return regexp.MustCompile(`:([^/]+)`).ReplaceAllString(p, "{$1}")
+}
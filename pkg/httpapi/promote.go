@@ -0,0 +1,192 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/promotion"
+)
+
+// PromoteApplication opens a pull request in the GitOps repository that
+// promotes the images of an application from the environment in the path, to
+// the environment in the "to" query parameter.
+//
+// The pull request is opened against the branch in the "base" query
+// parameter, or the default branch of the repository. If a pull request is
+// already open for the same changes, it is returned instead.
+//
+// Only the images in the kustomization.yaml files are promoted.
+func (a *APIRouter) PromoteApplication(w http.ResponseWriter, r *http.Request) {
+	params := httprouter.ParamsFromContext(r.Context())
+	from, appName := params.ByName("from"), params.ByName("app")
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		writeError(w, badRequest("missing parameter 'to'"))
+		return
+	}
+	if to == from {
+		writeError(w, badRequest(fmt.Sprintf("can't promote an application from environment %#v to itself", from)))
+		return
+	}
+
+	pipelines, _, creds, err := a.pipelinesConfig(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if pipelines.GitOpsURL == "" {
+		writeError(w, notFound("no GitOps repository is configured in pipelines.yaml"))
+		return
+	}
+	for _, envName := range []string{from, to} {
+		if pipelines.findEnvironment(envName) == nil {
+			writeError(w, notFound(fmt.Sprintf("failed to find environment %#v", envName)))
+			return
+		}
+	}
+	repo, _, err := parseURL(pipelines.GitOpsURL)
+	if err != nil {
+		log.Printf("ERROR: failed to parse the GitOps URL: %s", err)
+		writeError(w, newAPIError("failed to parse the GitOps repository URL", err))
+		return
+	}
+	client, err := a.getAuthenticatedGitClient(pipelines.GitOpsURL, creds)
+	if err != nil {
+		log.Printf("ERROR: failed to get an authenticated client: %s", err)
+		writeError(w, newAPIError("unable to create a Git client", err))
+		return
+	}
+
+	ctx := r.Context()
+	base := r.URL.Query().Get("base")
+	if base == "" {
+		base, err = client.DefaultBranch(ctx, repo)
+		if err != nil {
+			log.Printf("ERROR: failed to get the default branch of repo %#v: %s", repo, err)
+			writeError(w, newAPIError("failed to get the default branch", err))
+			return
+		}
+	}
+	commit, err := client.ResolveRef(ctx, repo, base)
+	if err != nil {
+		log.Printf("ERROR: failed to resolve ref %#v for repo %#v: %s", base, repo, err)
+		writeError(w, newAPIError("failed to resolve ref "+base, err))
+		return
+	}
+
+	changes, err := promotion.Changes(ctx, client, repo, commit.SHA, pathForApplication(appName, from), pathForApplication(appName, to))
+	if err != nil {
+		log.Printf("ERROR: failed to compare application %s in environments %s and %s: %s", appName, from, to, err)
+		writeError(w, newAPIError("failed to compare the environments", err))
+		return
+	}
+	if len(changes) == 0 {
+		writeError(w, conflict(fmt.Sprintf("application %#v in environment %#v already has the images from environment %#v", appName, to, from)))
+		return
+	}
+
+	branch := promotionBranch(appName, from, to, changes)
+	promoted := &apiv2.Promotion{
+		Application: appName,
+		From:        from,
+		To:          to,
+		Branch:      branch,
+		Files:       promotedFiles(changes),
+	}
+	// The branch is named for the changes, so if the same promotion has
+	// already been requested, the pull request that was opened for it is
+	// returned.
+	existing, err := client.FindPullRequest(ctx, repo, branch)
+	if err == nil {
+		promoted.PullRequest = apiv2.PullRequest{Number: existing.Number, URL: existing.URL}
+		marshalResponse(w, promoted)
+		return
+	}
+	if !git.IsNotFound(err) {
+		log.Printf("ERROR: failed to find a pull request for branch %#v in repo %#v: %s", branch, repo, err)
+		writeError(w, newAPIError("failed to find an existing pull request", err))
+		return
+	}
+	// The branch can exist without a pull request if an earlier request
+	// failed after creating it, the files are committed to it again.
+	if err := client.CreateBranch(ctx, repo, branch, commit.SHA); err != nil && !branchExists(ctx, client, repo, branch) {
+		log.Printf("ERROR: failed to create branch %#v in repo %#v: %s", branch, repo, err)
+		writeError(w, newAPIError("failed to create the promotion branch", err))
+		return
+	}
+	title := fmt.Sprintf("Promote %s from %s to %s", appName, from, to)
+	for _, c := range changes {
+		if err := client.UpdateFile(ctx, repo, c.Path, branch, title, c.Content); err != nil {
+			log.Printf("ERROR: failed to update %s in branch %#v: %s", c.Path, branch, err)
+			writeError(w, newAPIError("failed to commit the promotion", err))
+			return
+		}
+	}
+	pr, err := client.CreatePullRequest(ctx, repo, &git.NewPullRequest{
+		Title: title,
+		Body:  promotionBody(appName, from, to, changes),
+		Head:  branch,
+		Base:  base,
+	})
+	if err != nil {
+		log.Printf("ERROR: failed to open a pull request for branch %#v: %s", branch, err)
+		writeError(w, newAPIError("failed to open the pull request", err))
+		return
+	}
+
+	promoted.PullRequest = apiv2.PullRequest{Number: pr.Number, URL: pr.URL}
+	marshalResponseWithStatus(w, http.StatusCreated, promoted)
+}
+
+// branchExists returns true if the branch can be resolved in the repo.
+func branchExists(ctx context.Context, client git.SCM, repo, branch string) bool {
+	_, err := client.ResolveRef(ctx, repo, branch)
+	return err == nil
+}
+
+// promotionBranch returns the name of the branch for a promotion, the
+// changes are hashed so that promoting the same changes again reuses the
+// name, and different changes get a new branch.
+func promotionBranch(appName, from, to string, changes []promotion.Change) string {
+	h := sha256.New()
+	for _, c := range changes {
+		fmt.Fprintf(h, "%s\n%s\n", c.Path, c.Content)
+	}
+	return fmt.Sprintf("promote-%s-%s-to-%s-%x", appName, from, to, h.Sum(nil)[:4])
+}
+
+func promotionBody(appName, from, to string, changes []promotion.Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Promotes the images of %s from the %s environment to %s.\n", appName, from, to)
+	for _, c := range changes {
+		fmt.Fprintf(&b, "\n`%s`\n", c.Path)
+		for _, i := range c.Images {
+			if i.From == "" {
+				fmt.Fprintf(&b, "- %s: `%s`\n", i.Name, i.To)
+				continue
+			}
+			fmt.Fprintf(&b, "- %s: `%s` → `%s`\n", i.Name, i.From, i.To)
+		}
+	}
+	return b.String()
+}
+
+func promotedFiles(changes []promotion.Change) []apiv2.PromotedFile {
+	files := []apiv2.PromotedFile{}
+	for _, c := range changes {
+		f := apiv2.PromotedFile{Path: c.Path, Images: []apiv2.ImageChange{}}
+		for _, i := range c.Images {
+			f.Images = append(f.Images, apiv2.ImageChange{Name: i.Name, From: i.From, To: i.To})
+		}
+		files = append(files, f)
+	}
+	return files
+}
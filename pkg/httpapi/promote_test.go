@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+const (
+	devKustomization   = "environments/dev/apps/taxi/kustomization.yaml"
+	stageKustomization = "environments/stage/apps/taxi/kustomization.yaml"
+)
+
+func TestPromoteApplication(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addContents("demo/gitops", devKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", stageKustomization, "main", "testdata/promotion/stage-kustomization.yaml")
+
+	res := promoteApplication(t, ts, "/api/v2/environments/dev/application/taxi/promote", "stage")
+	got := &apiv2.Promotion{}
+	decodeCreatedResponse(t, res, got)
+
+	want := &apiv2.Promotion{
+		Application: "taxi",
+		From:        "dev",
+		To:          "stage",
+		Branch:      got.Branch,
+		PullRequest: apiv2.PullRequest{Number: 1, URL: "https://github.com/demo/gitops/pull/1"},
+		Files: []apiv2.PromotedFile{
+			{
+				Path: stageKustomization,
+				Images: []apiv2.ImageChange{
+					{Name: "quay.io/example/gitops-demo", From: "quay.io/example/gitops-demo:v1", To: "quay.io/example/gitops-demo:v2"},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("promotion failed:\n%s", diff)
+	}
+	if c.branches[key("demo/gitops", got.Branch)] != "main" {
+		t.Fatalf("branch %s was not created from main: %#v", got.Branch, c.branches)
+	}
+	wantContent := "resources:\n- deployment.yaml\n- service.yaml\nimages:\n- name: quay.io/example/gitops-demo\n  newTag: v2\n"
+	if diff := cmp.Diff(wantContent, string(c.updates[key("demo/gitops", stageKustomization, got.Branch)])); diff != "" {
+		t.Fatalf("incorrect update:\n%s", diff)
+	}
+	wantPR := []*git.NewPullRequest{
+		{
+			Title: "Promote taxi from dev to stage",
+			Body:  "Promotes the images of taxi from the dev environment to stage.\n\n`" + stageKustomization + "`\n- quay.io/example/gitops-demo: `quay.io/example/gitops-demo:v1` → `quay.io/example/gitops-demo:v2`\n",
+			Head:  got.Branch,
+			Base:  "main",
+		},
+	}
+	if diff := cmp.Diff(wantPR, c.pullRequests); diff != "" {
+		t.Fatalf("incorrect pull request:\n%s", diff)
+	}
+}
+
+func TestPromoteApplicationAgain(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addContents("demo/gitops", devKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", stageKustomization, "main", "testdata/promotion/stage-kustomization.yaml")
+	first := &apiv2.Promotion{}
+	decodeCreatedResponse(t, promoteApplication(t, ts, "/api/v2/environments/dev/application/taxi/promote", "stage"), first)
+
+	res := promoteApplication(t, ts, "/api/v2/environments/dev/application/taxi/promote", "stage")
+	got := &apiv2.Promotion{}
+	decodeV2Response(t, res, got)
+
+	if diff := cmp.Diff(first, got); diff != "" {
+		t.Fatalf("promotion failed:\n%s", diff)
+	}
+	if len(c.pullRequests) != 1 {
+		t.Fatalf("got %d pull requests, want 1", len(c.pullRequests))
+	}
+}
+
+func TestPromoteApplicationWithExistingBranch(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addContents("demo/gitops", devKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", stageKustomization, "main", "testdata/promotion/stage-kustomization.yaml")
+	first := &apiv2.Promotion{}
+	decodeCreatedResponse(t, promoteApplication(t, ts, "/api/v2/environments/dev/application/taxi/promote", "stage"), first)
+	// The branch was created, but the pull request wasn't opened.
+	c.pullRequests = nil
+	delete(c.updates, key("demo/gitops", stageKustomization, first.Branch))
+
+	res := promoteApplication(t, ts, "/api/v2/environments/dev/application/taxi/promote", "stage")
+	got := &apiv2.Promotion{}
+	decodeCreatedResponse(t, res, got)
+
+	if got.Branch != first.Branch {
+		t.Fatalf("got branch %s, want %s", got.Branch, first.Branch)
+	}
+	if _, ok := c.updates[key("demo/gitops", stageKustomization, got.Branch)]; !ok {
+		t.Fatalf("%s was not updated in branch %s", stageKustomization, got.Branch)
+	}
+	if len(c.pullRequests) != 1 {
+		t.Fatalf("got %d pull requests, want 1", len(c.pullRequests))
+	}
+}
+
+func TestPromoteApplicationWithNoChanges(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addContents("demo/gitops", devKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+	c.addContents("demo/gitops", stageKustomization, "main", "testdata/promotion/dev-kustomization.yaml")
+
+	res := promoteApplication(t, ts, "/environments/dev/application/taxi/promote", "stage")
+
+	assertAPIError(t, res, http.StatusConflict, CodeConflict, `application "taxi" in environment "stage" already has the images from environment "dev"`)
+	if len(c.pullRequests) != 0 {
+		t.Fatalf("got %d pull requests, want none", len(c.pullRequests))
+	}
+}
+
+func TestPromoteApplicationErrors(t *testing.T) {
+	ts, c := makeServer(t)
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	errorTests := []struct {
+		path       string
+		to         string
+		wantStatus int
+		wantCode   string
+		wantMsg    string
+	}{
+		{"/api/v1/environments/dev/application/taxi/promote", "", http.StatusBadRequest, CodeBadRequest, "missing parameter 'to'"},
+		{"/api/v1/environments/dev/application/taxi/promote", "dev", http.StatusBadRequest, CodeBadRequest, `can't promote an application from environment "dev" to itself`},
+		{"/api/v1/environments/dev/application/taxi/promote", "prod", http.StatusNotFound, CodeNotFound, `failed to find environment "prod"`},
+		{"/api/v1/environments/test/application/taxi/promote", "stage", http.StatusNotFound, CodeNotFound, `failed to find environment "test"`},
+	}
+
+	for _, tt := range errorTests {
+		t.Run(tt.path+"?to="+tt.to, func(t *testing.T) {
+			res := promoteApplication(t, ts, tt.path, tt.to)
+			assertAPIError(t, res, tt.wantStatus, tt.wantCode, tt.wantMsg)
+		})
+	}
+}
+
+func promoteApplication(t *testing.T, ts *httptest.Server, path, to string) *http.Response {
+	t.Helper()
+	q := url.Values{"url": []string{"https://github.com/example/gitops.git"}}
+	if to != "" {
+		q.Set("to", to)
+	}
+	req := makeClientRequest(t, "Bearer testing", ts.URL+path+"?"+q.Encode())
+	req.Method = http.MethodPost
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+func decodeCreatedResponse(t *testing.T, res *http.Response, v interface{}) {
+	t.Helper()
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusCreated {
+		t.Fatalf("got status %d, want %d: %s", res.StatusCode, http.StatusCreated, b)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatalf("failed to parse %s: %s", b, err)
+	}
+}
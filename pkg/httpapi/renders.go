@@ -0,0 +1,135 @@
+package httpapi
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+	"github.com/redhat-developer/gitops-backend/pkg/webhooks"
+)
+
+const (
+	// renderCacheSize is the number of rendered applications that are kept,
+	// they are keyed by the commit they were rendered from, so they don't
+	// expire.
+	renderCacheSize = 100
+
+	// recentRendersSize is the number of recently rendered applications that
+	// are rendered again when a push changes them.
+	recentRendersSize = 100
+
+	// recentRendersTTL is how long an application is rendered again after
+	// pushes, with the credentials of the request that rendered it.
+	recentRendersTTL = 10 * time.Minute
+)
+
+// recentRender is an application that was rendered from a ref in a GitOps
+// repository, with the options to clone the repository with the credentials
+// of the request that rendered it.
+//
+// Recent renders expire after the recentRendersTTL, so the credentials are
+// only used for a short time after the request, and an application is only
+// rendered in the background for a user that can access the repository.
+type recentRender struct {
+	host string
+	repo string
+	ref  string
+	env  string
+	path string
+	src  *parser.Source
+	opts *gogit.CloneOptions
+}
+
+// parseApplication parses the resources for the application at the commit
+// that the ref resolved to, and records the application so that it's
+// rendered again when the ref is pushed to.
+func (a *APIRouter) parseApplication(r *recentRender, sha string) ([]*parser.Resource, error) {
+	a.recentRenders.Add(strings.Join([]string{r.host, r.repo, r.ref, r.path}, "#"), r)
+	return a.parseAt(r, sha)
+}
+
+// parseAt parses the resources for the application at the commit, through
+// the render cache.
+//
+// Rendered applications are shared between credentials, requests resolve
+// the ref with their own credentials before the application is rendered, and
+// resolved refs are cached per token, so the Git host checks the credentials
+// of every request first.
+func (a *APIRouter) parseAt(r *recentRender, sha string) ([]*parser.Resource, error) {
+	key := strings.Join([]string{r.host, r.repo, sha, r.path, sourceKey(r.src)}, "#")
+	if res, ok := a.renders.Get(key); ok {
+		return res, nil
+	}
+	res, err := a.resourceParser(r.path, sha, r.opts, r.src)
+	if err != nil {
+		return nil, err
+	}
+	a.renders.Add(key, res)
+	return res, nil
+}
+
+// sourceKey identifies the source options in the render cache, as the same
+// path can be read with different options.
+func sourceKey(s *parser.Source) string {
+	if s == nil {
+		return ""
+	}
+	var key string
+	if d := s.Directory; d != nil {
+		key = fmt.Sprintf("%t#%s#%s", d.Recurse, d.Include, d.Exclude)
+	}
+	if h := s.Helm; h != nil {
+		key += "#" + strings.Join(h.ValueFiles, ",")
+	}
+	return key
+}
+
+// Pushed implements the webhooks.Listener interface, the recently rendered
+// applications that the push changed are rendered again at the new commit in
+// the background, so that they're cached when the application is next
+// requested.
+func (a *APIRouter) Pushed(p *webhooks.Push) {
+	if p.SHA == "" {
+		return
+	}
+	var changed []*recentRender
+	for _, r := range a.recentRenders.Values() {
+		if pushChanges(p, r) {
+			changed = append(changed, r)
+		}
+	}
+	if len(changed) > 0 {
+		go a.prerender(p.SHA, changed)
+	}
+}
+
+func (a *APIRouter) prerender(sha string, renders []*recentRender) {
+	for _, r := range renders {
+		if _, err := a.parseAt(r, sha); err != nil {
+			log.Printf("ERROR: failed to render %s at %s in %s/%s: %s", r.path, sha, r.host, r.repo, err)
+		}
+	}
+}
+
+// pushChanges returns true if the push changed the ref that the application
+// was rendered from, and changed files in the application's environment, or
+// outside of the environments, where the bases that it refers to may be.
+func pushChanges(p *webhooks.Push, r *recentRender) bool {
+	if !strings.EqualFold(p.Host, r.host) || !strings.EqualFold(p.Repo, r.repo) || !p.Updates(r.ref) {
+		return false
+	}
+	if p.Changed(path.Join("environments", r.env)) {
+		return true
+	}
+	for _, f := range p.Files {
+		if !strings.HasPrefix(f, "environments/") {
+			return true
+		}
+	}
+	return false
+}
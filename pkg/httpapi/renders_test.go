@@ -0,0 +1,183 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/redhat-developer/gitops-backend/internal/cache"
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/parser"
+	"github.com/redhat-developer/gitops-backend/pkg/webhooks"
+)
+
+func TestRenderedApplicationsAreCachedByCommit(t *testing.T) {
+	rp := &countingParser{}
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = rp.parse
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+
+	for i := 0; i < 2; i++ {
+		getApplication(t, ts, "dev", "taxi")
+	}
+
+	if renders := rp.parsed(); len(renders) != 1 {
+		t.Fatalf("got %d renders, want 1: %v", len(renders), renders)
+	}
+}
+
+func TestPushedRendersChangedApplications(t *testing.T) {
+	rp := &countingParser{rendered: make(chan string, 10)}
+	var router *APIRouter
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = rp.parse
+		router = a
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addCommit("demo/gitops", "HEAD", &git.Commit{SHA: "first"})
+	getApplication(t, ts, "dev", "taxi")
+	getApplication(t, ts, "stage", "taxi")
+	for i := 0; i < 2; i++ {
+		<-rp.rendered
+	}
+
+	router.Pushed(&webhooks.Push{
+		Host:  "example.com",
+		Repo:  "demo/gitops",
+		Ref:   "refs/heads/main",
+		SHA:   "second",
+		Files: []string{"environments/dev/apps/taxi/kustomization.yaml"},
+	})
+
+	select {
+	case got := <-rp.rendered:
+		if want := "environments/dev/apps/taxi#second"; got != want {
+			t.Fatalf("rendered %q, want %q", got, want)
+		}
+		if token := rp.lastToken(); token != "test-token" {
+			t.Fatalf("rendered with token %q, want the token of the request", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the changed application was not rendered")
+	}
+
+	c.addCommit("demo/gitops", "HEAD", &git.Commit{SHA: "second"})
+	getApplication(t, ts, "dev", "taxi")
+	if renders := rp.parsed(); len(renders) != 3 {
+		t.Fatalf("got %d renders, want 3: %v", len(renders), renders)
+	}
+}
+
+func TestPushedDoesNotRenderExpiredApplications(t *testing.T) {
+	rp := &countingParser{rendered: make(chan string, 10)}
+	var router *APIRouter
+	ts, c := makeServer(t, func(a *APIRouter) {
+		a.resourceParser = rp.parse
+		a.recentRenders = cache.New[string, *recentRender](recentRendersSize, time.Millisecond)
+		router = a
+	})
+	c.addContents("example/gitops", "pipelines.yaml", "HEAD", "testdata/pipelines.yaml")
+	c.addCommit("demo/gitops", "HEAD", &git.Commit{SHA: "first"})
+	getApplication(t, ts, "dev", "taxi")
+	<-rp.rendered
+	time.Sleep(10 * time.Millisecond)
+
+	router.Pushed(&webhooks.Push{
+		Host:  "example.com",
+		Repo:  "demo/gitops",
+		Ref:   "refs/heads/main",
+		SHA:   "second",
+		Files: []string{"environments/dev/apps/taxi/kustomization.yaml"},
+	})
+
+	select {
+	case got := <-rp.rendered:
+		t.Fatalf("rendered %q with expired credentials", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestPushChanges(t *testing.T) {
+	r := &recentRender{host: "example.com", repo: "demo/gitops", ref: "HEAD", env: "dev"}
+	changeTests := []struct {
+		name string
+		push *webhooks.Push
+		want bool
+	}{
+		{"environment", &webhooks.Push{Host: "example.com", Repo: "Demo/GitOps", Ref: "refs/heads/main", Files: []string{"environments/dev/env/namespace.yaml"}}, true},
+		{"other environment", &webhooks.Push{Host: "example.com", Repo: "demo/gitops", Ref: "refs/heads/main", Files: []string{"environments/stage/env/namespace.yaml"}}, false},
+		{"bases", &webhooks.Push{Host: "example.com", Repo: "demo/gitops", Ref: "refs/heads/main", Files: []string{"config/base/deployment.yaml"}}, true},
+		{"unknown files", &webhooks.Push{Host: "example.com", Repo: "demo/gitops", Ref: "refs/heads/main"}, true},
+		{"other branch", &webhooks.Push{Host: "example.com", Repo: "demo/gitops", Ref: "refs/heads/dev", DefaultBranch: "main"}, false},
+		{"other repository", &webhooks.Push{Host: "example.com", Repo: "demo/other", Ref: "refs/heads/main"}, false},
+	}
+
+	for _, tt := range changeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pushChanges(tt.push, r); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func getApplication(t *testing.T, ts *httptest.Server, env, app string) {
+	t.Helper()
+	options := url.Values{
+		"url": []string{"https://github.com/example/gitops.git"},
+	}
+	req := makeClientRequest(t, "Bearer testing",
+		fmt.Sprintf("%s/api/v2/environments/%s/application/%s?%s", ts.URL, env, app, options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+// countingParser records the paths and revisions that it parses, and sends
+// them to the rendered channel, if there is one.
+type countingParser struct {
+	mu       sync.Mutex
+	renders  []string
+	token    string
+	rendered chan string
+}
+
+func (p *countingParser) parse(path, revision string, opts *gogit.CloneOptions, src *parser.Source) ([]*parser.Resource, error) {
+	key := path + "#" + revision
+	p.mu.Lock()
+	p.renders = append(p.renders, key)
+	if auth, ok := opts.Auth.(*githttp.BasicAuth); ok {
+		p.token = auth.Password
+	}
+	p.mu.Unlock()
+	if p.rendered != nil {
+		p.rendered <- key
+	}
+	return nil, nil
+}
+
+// lastToken returns the token that the last application was parsed with.
+func (p *countingParser) lastToken() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.token
+}
+
+func (p *countingParser) parsed() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.renders...)
+}
@@ -11,6 +11,7 @@ import (
 // returns an error.
 type SecretGetter interface {
 	SecretToken(ctx context.Context, authToken string, id types.NamespacedName, key string) (string, error)
+	Secret(ctx context.Context, authToken string, id types.NamespacedName) (map[string][]byte, error)
 }
 
 // RESTConfigFactory creates and returns new Kubernetes client configurations
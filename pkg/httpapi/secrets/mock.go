@@ -12,28 +12,48 @@ var _ SecretGetter = (*MockSecret)(nil)
 
 // NewMock returns a simple secret getter.
 func NewMock() MockSecret {
-	return MockSecret{}
+	return MockSecret{secrets: map[string]map[string][]byte{}}
 }
 
 // MockSecret implements the SecretGetter interface.
 type MockSecret struct {
-	secrets map[string]string
+	secrets map[string]map[string][]byte
 }
 
-// Secret implements the SecretGetter interface.
+// SecretToken implements the SecretGetter interface.
 func (k MockSecret) SecretToken(ctx context.Context, authToken string, secretID types.NamespacedName, key string) (string, error) {
-	token, ok := k.secrets[mockKey(authToken, secretID, key)]
+	token, ok := k.secrets[mockKey(authToken, secretID)][key]
 	if !ok {
 		return "", fmt.Errorf("mock not found")
 	}
-	return token, nil
+	return string(token), nil
+}
+
+// Secret implements the SecretGetter interface.
+func (k MockSecret) Secret(ctx context.Context, authToken string, secretID types.NamespacedName) (map[string][]byte, error) {
+	data, ok := k.secrets[mockKey(authToken, secretID)]
+	if !ok {
+		return nil, fmt.Errorf("mock not found")
+	}
+	return data, nil
 }
 
 // AddStubResponse is a mock method that sets up a token to be returned.
 func (k MockSecret) AddStubResponse(authToken string, secretID types.NamespacedName, token, key string) {
-	k.secrets[mockKey(authToken, secretID, key)] = token
+	data, ok := k.secrets[mockKey(authToken, secretID)]
+	if !ok {
+		data = map[string][]byte{}
+		k.secrets[mockKey(authToken, secretID)] = data
+	}
+	data[key] = []byte(token)
+}
+
+// AddStubSecret is a mock method that sets up the data in a secret to be
+// returned.
+func (k MockSecret) AddStubSecret(authToken string, secretID types.NamespacedName, data map[string][]byte) {
+	k.secrets[mockKey(authToken, secretID)] = data
 }
 
-func mockKey(token string, n types.NamespacedName, key string) string {
-	return strings.Join([]string{token, n.Name, n.Namespace, key}, ":")
+func mockKey(token string, n types.NamespacedName) string {
+	return strings.Join([]string{token, n.Name, n.Namespace}, ":")
 }
@@ -34,22 +34,32 @@ func New(c RESTConfigFactory) *KubeSecretGetter {
 // SecretToken looks for a namespaced secret, and returns the 'token' key from
 // it, or an error if not found.
 func (k KubeSecretGetter) SecretToken(ctx context.Context, authToken string, id types.NamespacedName, key string) (string, error) {
+	data, err := k.Secret(ctx, authToken, id)
+	if err != nil {
+		return "", err
+	}
+	token, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("secret invalid, no 'token' key in %s/%s", id.Namespace, id.Name)
+	}
+	return string(token), nil
+}
+
+// Secret looks for a namespaced secret, and returns its data, or an error if
+// not found.
+func (k KubeSecretGetter) Secret(ctx context.Context, authToken string, id types.NamespacedName) (map[string][]byte, error) {
 	cfg, err := k.configFactory.Create(authToken)
 	if err != nil {
-		return "", fmt.Errorf("failed to create a REST config: %w", err)
+		return nil, fmt.Errorf("failed to create a REST config: %w", err)
 	}
 	coreClient, err := k.clientFactory(cfg)
 	if err != nil {
-		return "", fmt.Errorf("failed to create a client from the config: %w", err)
+		return nil, fmt.Errorf("failed to create a client from the config: %w", err)
 	}
 
 	secret, err := coreClient.CoreV1().Secrets(id.Namespace).Get(ctx, id.Name, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("error getting secret %s/%s: %w", id.Namespace, id.Name, err)
-	}
-	token, ok := secret.Data[key]
-	if !ok {
-		return "", fmt.Errorf("secret invalid, no 'token' key in %s/%s", id.Namespace, id.Name)
+		return nil, fmt.Errorf("error getting secret %s/%s: %w", id.Namespace, id.Name, err)
 	}
-	return string(token), nil
+	return secret.Data, nil
 }
@@ -36,6 +36,25 @@ func TestSecret(t *testing.T) {
 	}
 }
 
+func TestSecretData(t *testing.T) {
+	g := New(&stubConfigFactory{})
+	g.clientFactory = func(c *rest.Config) (kubernetes.Interface, error) {
+		if c.BearerToken == "auth token" {
+			return fake.NewSimpleClientset(createSecret(testID, "secret-token")), nil
+		}
+		return nil, errors.New("failed")
+	}
+
+	data, err := g.Secret(context.TODO(), "auth token", testID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data["token"]) != "secret-token" {
+		t.Fatalf("got %#v, want the token", data)
+	}
+}
+
 func TestSecretWithMissingSecret(t *testing.T) {
 	g := New(&stubConfigFactory{})
 	g.clientFactory = func(c *rest.Config) (kubernetes.Interface, error) {
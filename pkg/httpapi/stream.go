@@ -0,0 +1,312 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/gorilla/websocket"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/kinds"
+)
+
+// streamKeepAlive is how often a keep-alive is sent when there are no
+// changes, so that proxies don't close idle streams.
+const streamKeepAlive = 30 * time.Second
+
+// The AuthenticationMiddleware requires a bearer token, so requests from other
+// origins are accepted, the same as the CORS headers on the other routes.
+var upgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// StreamApplications streams changes to the Argo CD Applications deployed
+// from the GitOps repository in the "url" query parameter, optionally
+// filtered with the same query parameters as ListApplications.
+//
+// Events are sent as Server-Sent Events, or as JSON messages if the request
+// is a WebSocket upgrade.
+//
+// The first event for each application has its current sync status and
+// resources, later events only have the changes. The stream ends if the
+// watch is closed by the API server, clients are expected to reconnect.
+func (a *APIRouter) StreamApplications(w http.ResponseWriter, r *http.Request) {
+	filter, err := applicationFilterFromQuery(r.URL.Query(), a.argoCD)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	// The watch is started before listing, so that no changes are missed,
+	// changes that are seen twice don't generate events.
+	watcher, err := a.k8sClient.Watch(ctx, &argoV1aplha1.ApplicationList{}, filter.listOptions()...)
+	if err != nil {
+		log.Printf("ERROR: failed to watch applications: %v", err)
+		writeError(w, newAPIError("failed to watch applications", err))
+		return
+	}
+	defer watcher.Stop()
+	appList := &argoV1aplha1.ApplicationList{}
+	if err := a.k8sClient.List(ctx, appList, filter.listOptions()...); err != nil {
+		log.Printf("ERROR: failed to get application list: %v", err)
+		writeError(w, newAPIError("failed to get list of applications", err))
+		return
+	}
+
+	var sender eventSender
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// The upgrader has already written an error response.
+			log.Printf("ERROR: failed to upgrade to a WebSocket: %v", err)
+			return
+		}
+		defer conn.Close()
+		go discardMessages(conn, cancel)
+		sender = websocketSender{conn: conn}
+	} else {
+		s, err := newSSESender(w)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		sender = s
+	}
+
+	tracker := newApplicationTracker(filter, a.resourceKinds, func(app *argoV1aplha1.Application, h argoV1aplha1.RevisionHistory, envName string) apiv2.HistoryEntry {
+		return a.historyEntry(ctx, app, h, envName)
+	})
+	for i := range appList.Items {
+		if err := sender.send(tracker.update(&appList.Items[i])); err != nil {
+			log.Printf("ERROR: failed to send application event: %v", err)
+			return
+		}
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		var event *apiv2.ApplicationEvent
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sender.keepAlive(); err != nil {
+				return
+			}
+			continue
+		case e, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			app, ok := e.Object.(*argoV1aplha1.Application)
+			if !ok {
+				log.Printf("ERROR: unexpected watch event %s: %#v", e.Type, e.Object)
+				return
+			}
+			switch e.Type {
+			case watch.Added, watch.Modified:
+				event = tracker.update(app)
+			case watch.Deleted:
+				event = tracker.remove(app)
+			}
+		}
+		if err := sender.send(event); err != nil {
+			log.Printf("ERROR: failed to send application event: %v", err)
+			return
+		}
+	}
+}
+
+// discardMessages reads from the WebSocket until it is closed, this is
+// required to process control messages, and to detect the client going away.
+func discardMessages(conn *websocket.Conn, cancel context.CancelFunc) {
+	defer cancel()
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			return
+		}
+	}
+}
+
+// eventSender writes events to a streaming response.
+type eventSender interface {
+	// send writes an event, nil events are ignored.
+	send(*apiv2.ApplicationEvent) error
+	keepAlive() error
+}
+
+type sseSender struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+}
+
+func newSSESender(w http.ResponseWriter) (*sseSender, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, &APIError{
+			Status:  http.StatusInternalServerError,
+			Code:    CodeInternalError,
+			Message: "streaming is not supported",
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseSender{w: w, flusher: flusher}, nil
+}
+
+func (s *sseSender) send(e *apiv2.ApplicationEvent) error {
+	if e == nil {
+		return nil
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", e.Type, b); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSender) keepAlive() error {
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+type websocketSender struct {
+	conn *websocket.Conn
+}
+
+func (s websocketSender) send(e *apiv2.ApplicationEvent) error {
+	if e == nil {
+		return nil
+	}
+	return s.conn.WriteJSON(e)
+}
+
+func (s websocketSender) keepAlive() error {
+	return s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamKeepAlive))
+}
+
+// applicationState is the last state of an Application that was sent.
+type applicationState struct {
+	syncStatus string
+	resources  map[string][]apiv2.ResourceHealth
+	historyID  int64
+}
+
+// applicationTracker keeps the state of the Applications in a stream, and
+// generates events for the changes to them.
+type applicationTracker struct {
+	filter        applicationFilter
+	resourceKinds *kinds.Registry
+	historyEntry  func(*argoV1aplha1.Application, argoV1aplha1.RevisionHistory, string) apiv2.HistoryEntry
+	apps          map[types.NamespacedName]*applicationState
+}
+
+func newApplicationTracker(f applicationFilter, rk *kinds.Registry, h func(*argoV1aplha1.Application, argoV1aplha1.RevisionHistory, string) apiv2.HistoryEntry) *applicationTracker {
+	return &applicationTracker{
+		filter:        f,
+		resourceKinds: rk,
+		historyEntry:  h,
+		apps:          map[types.NamespacedName]*applicationState{},
+	}
+}
+
+// update records the new state of an Application, and returns an event with
+// the changes since the previous state, or nil if nothing has changed.
+//
+// The history is not sent for Applications that haven't been seen before,
+// only deployments that happen while streaming.
+func (t *applicationTracker) update(app *argoV1aplha1.Application) *apiv2.ApplicationEvent {
+	if !t.filter.matches(app) {
+		// The Application may have been changed so that it no longer matches.
+		return t.remove(app)
+	}
+	name := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	prev, seen := t.apps[name]
+	if !seen {
+		prev = &applicationState{historyID: maxHistoryID(app)}
+	}
+	env := app.Spec.Destination.Namespace
+	event := &apiv2.ApplicationEvent{
+		Type:        apiv2.EventUpdated,
+		Application: app.Name,
+		Environment: env,
+		RepoURL:     app.Spec.GetSource().RepoURL,
+	}
+	changed := !seen
+
+	state := &applicationState{
+		syncStatus: string(app.Status.Sync.Status),
+		resources:  applicationResources(t.resourceKinds, app),
+		historyID:  prev.historyID,
+	}
+	if state.syncStatus != prev.syncStatus {
+		event.SyncStatus = state.syncStatus
+		changed = true
+	}
+	for group, resources := range state.resources {
+		if !slices.Equal(resources, prev.resources[group]) || !seen {
+			if event.Resources == nil {
+				event.Resources = map[string][]apiv2.ResourceHealth{}
+			}
+			event.Resources[group] = resources
+			changed = true
+		}
+	}
+	for _, h := range app.Status.History {
+		if h.ID > prev.historyID {
+			event.History = append([]apiv2.HistoryEntry{t.historyEntry(app, h, env)}, event.History...)
+			state.historyID = max(state.historyID, h.ID)
+			changed = true
+		}
+	}
+	t.apps[name] = state
+	if !changed {
+		return nil
+	}
+	return event
+}
+
+// remove forgets an Application, and returns a deleted event if it had been
+// sent previously.
+func (t *applicationTracker) remove(app *argoV1aplha1.Application) *apiv2.ApplicationEvent {
+	name := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	if _, ok := t.apps[name]; !ok {
+		return nil
+	}
+	delete(t.apps, name)
+	return &apiv2.ApplicationEvent{
+		Type:        apiv2.EventDeleted,
+		Application: app.Name,
+		Environment: app.Spec.Destination.Namespace,
+		RepoURL:     app.Spec.GetSource().RepoURL,
+	}
+}
+
+func maxHistoryID(app *argoV1aplha1.Application) int64 {
+	var id int64
+	for _, h := range app.Status.History {
+		id = max(id, h.ID)
+	}
+	return id
+}
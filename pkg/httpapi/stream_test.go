@@ -0,0 +1,244 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	argoV1aplha1 "github.com/argoproj/argo-cd/v3/pkg/apis/application/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	"github.com/gorilla/websocket"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	apiv2 "github.com/redhat-developer/gitops-backend/pkg/api/v2"
+	"github.com/redhat-developer/gitops-backend/pkg/argocd"
+	"github.com/redhat-developer/gitops-backend/pkg/kinds"
+)
+
+func TestStreamApplications(t *testing.T) {
+	kc := makeTestClient()
+	ac := argocd.NewMock()
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+		router.argoCD = testInstances(ac)
+	})
+	for _, f := range []string{"testdata/application.yaml", "testdata/application3.yaml"} {
+		app, err := testArgoApplication(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := kc.Create(context.TODO(), app); err != nil {
+			t.Fatal(err)
+		}
+	}
+	options := url.Values{
+		"url": []string{"https://github.com/test-repo/gitops.git"},
+		"app": []string{"app-taxi"},
+	}
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/api/v2/stream/applications?%s", ts.URL, options.Encode()))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	assertHTTPHeader(t, res, "Content-Type", "text/event-stream")
+	events := bufio.NewReader(res.Body)
+
+	got := readServerSentEvent(t, events)
+	if got.Type != apiv2.EventUpdated || got.Application != "dev-app-taxi" || got.SyncStatus != "Synced" {
+		t.Fatalf("got initial event %#v", got)
+	}
+	if got.History != nil {
+		t.Fatalf("initial event should not include the history, got %#v", got.History)
+	}
+
+	app := &argoV1aplha1.Application{}
+	if err := kc.Get(context.TODO(), ctrlclient.ObjectKey{Name: "dev-app-taxi", Namespace: "test-namespace"}, app); err != nil {
+		t.Fatal(err)
+	}
+	deployedAt := metav1.NewTime(time.Date(2022, time.May, 1, 10, 0, 0, 0, time.UTC))
+	app.Status.Sync.Status = argoV1aplha1.SyncStatusCodeOutOfSync
+	app.Status.History = append(app.Status.History, argoV1aplha1.RevisionHistory{
+		ID:         100,
+		Revision:   "b5d3e8a0c2f1",
+		DeployedAt: deployedAt,
+		Source:     argoV1aplha1.ApplicationSource{RepoURL: "https://github.com/test-repo/gitops.git"},
+	})
+	ac.AddRevisionMetadata(ctrlclient.ObjectKeyFromObject(app), "b5d3e8a0c2f1", &argoV1aplha1.RevisionMetadata{Author: "test", Message: "testMessage"})
+	if err := kc.Update(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
+
+	got = readServerSentEvent(t, events)
+	want := &apiv2.ApplicationEvent{
+		Type:        apiv2.EventUpdated,
+		Application: "dev-app-taxi",
+		Environment: "dev",
+		RepoURL:     "https://github.com/test-repo/gitops.git",
+		SyncStatus:  "OutOfSync",
+		History: []apiv2.HistoryEntry{
+			{
+				Author:      "test",
+				Message:     "testMessage",
+				Revision:    "b5d3e8a0c2f1",
+				Environment: "dev",
+				RepoURL:     "https://github.com/test-repo/gitops.git",
+				DeployedAt:  timePtr(deployedAt.Time),
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got, cmpTimes()); diff != "" {
+		t.Fatalf("update event failed:\n%s", diff)
+	}
+
+	if err := kc.Delete(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
+	got = readServerSentEvent(t, events)
+	if got.Type != apiv2.EventDeleted || got.Application != "dev-app-taxi" {
+		t.Fatalf("got event %#v, want a deleted event", got)
+	}
+}
+
+func TestStreamApplicationsWebSocket(t *testing.T) {
+	kc := makeTestClient()
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = kc
+	})
+	app, err := testArgoApplication("testdata/application.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kc.Create(context.TODO(), app); err != nil {
+		t.Fatal(err)
+	}
+	dialer := websocket.Dialer{TLSClientConfig: ts.Client().Transport.(*http.Transport).TLSClientConfig}
+	options := url.Values{"url": []string{"https://github.com/test-repo/gitops.git"}}
+	conn, res, err := dialer.Dial(
+		fmt.Sprintf("wss%s/api/v2/stream/applications?%s", strings.TrimPrefix(ts.URL, "https"), options.Encode()),
+		http.Header{authHeader: []string{"Bearer testing"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	defer conn.Close()
+
+	got := &apiv2.ApplicationEvent{}
+	if err := conn.ReadJSON(got); err != nil {
+		t.Fatal(err)
+	}
+	want := &apiv2.ApplicationEvent{
+		Type:        apiv2.EventUpdated,
+		Application: "dev-test-app",
+		Environment: "dev",
+		RepoURL:     "https://github.com/test-repo/gitops.git",
+		SyncStatus:  "Synced",
+		Resources:   applicationResources(kinds.Default(), app),
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Fatalf("event failed:\n%s", diff)
+	}
+}
+
+func TestStreamApplicationsWithNoURL(t *testing.T) {
+	ts, _ := makeServer(t, func(router *APIRouter) {
+		router.k8sClient = makeTestClient()
+	})
+	req := makeClientRequest(t, "Bearer testing", fmt.Sprintf("%s/api/v2/stream/applications", ts.URL))
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAPIError(t, res, http.StatusBadRequest, CodeBadRequest, "please provide a valid GitOps repo URL")
+}
+
+func TestApplicationTracker(t *testing.T) {
+	app, err := testArgoApplication("testdata/application.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := newApplicationTracker(applicationFilter{repoURL: "https://github.com/test-repo/gitops.git"}, kinds.Default(), stubHistoryEntry)
+
+	if e := tracker.update(app); e == nil || e.Resources == nil {
+		t.Fatalf("got %#v, want an event with the resources", e)
+	}
+	if e := tracker.update(app); e != nil {
+		t.Fatalf("got %#v, want no event for an unchanged application", e)
+	}
+
+	app.Status.Resources[0].Health.Status = "Degraded"
+	e := tracker.update(app)
+	want := &apiv2.ApplicationEvent{
+		Type:        apiv2.EventUpdated,
+		Application: "dev-test-app",
+		Environment: "dev",
+		RepoURL:     "https://github.com/test-repo/gitops.git",
+		Resources: map[string][]apiv2.ResourceHealth{
+			"services": {{Name: "taxi", Health: "Degraded", Status: "Synced"}},
+		},
+	}
+	if diff := cmp.Diff(want, e); diff != "" {
+		t.Fatalf("resource event failed:\n%s", diff)
+	}
+
+	app.Spec.Source.RepoURL = "https://github.com/test-repo/other.git"
+	e = tracker.update(app)
+	if e == nil || e.Type != apiv2.EventDeleted {
+		t.Fatalf("got %#v, want a deleted event when the application no longer matches", e)
+	}
+	if e := tracker.update(app); e != nil {
+		t.Fatalf("got %#v, want no event for an application that doesn't match", e)
+	}
+}
+
+func stubHistoryEntry(app *argoV1aplha1.Application, h argoV1aplha1.RevisionHistory, envName string) apiv2.HistoryEntry {
+	return apiv2.HistoryEntry{Revision: h.Revision, Environment: envName}
+}
+
+// readServerSentEvent reads the next event from the stream, and validates it
+// against the specification.
+func readServerSentEvent(t *testing.T, r *bufio.Reader) *apiv2.ApplicationEvent {
+	t.Helper()
+	var eventType, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("failed to read event: %s", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && data != "" {
+			break
+		}
+		if v, ok := strings.CutPrefix(line, "event: "); ok {
+			eventType = v
+		}
+		if v, ok := strings.CutPrefix(line, "data: "); ok {
+			data = v
+		}
+	}
+
+	spec := loadSpec(t)
+	var body interface{}
+	if err := json.Unmarshal([]byte(data), &body); err != nil {
+		t.Fatalf("failed to parse %s: %s", data, err)
+	}
+	schema := map[string]interface{}{"$ref": "#/components/schemas/ApplicationEvent"}
+	if errs := spec.validate(schema, body, "$"); len(errs) > 0 {
+		t.Fatalf("event does not match the specification:\n%s\n%s", strings.Join(errs, "\n"), data)
+	}
+	event := &apiv2.ApplicationEvent{}
+	if err := json.Unmarshal([]byte(data), event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != eventType {
+		t.Fatalf("got event type %q, data has type %q", eventType, event.Type)
+	}
+	return event
+}
@@ -0,0 +1,141 @@
+package kinds
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/yaml"
+)
+
+// Kind describes how the resources of a kind in an Argo CD Application are
+// reported.
+type Kind struct {
+	// Group is the API group of the kind, empty for the core group.
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+	// ResponseGroup is the name of the group that the resources are reported
+	// in, kinds can share a group, e.g. Secrets and SealedSecrets.
+	ResponseGroup string `json:"responseGroup"`
+	// Health is true if Argo CD assesses the health of resources of the kind,
+	// the health is not reported for other kinds.
+	Health bool `json:"health,omitempty"`
+}
+
+// Registry is the set of kinds that are reported, resources of other kinds
+// are not reported.
+type Registry struct {
+	kinds map[schema.GroupKind]Kind
+	// builtin is the group and kind of the default kinds by their kind, and
+	// builtinGroups are their groups, see Lookup.
+	builtin       map[string]schema.GroupKind
+	builtinGroups map[string]bool
+}
+
+// NewRegistry creates and returns a Registry with the kinds.
+func NewRegistry(kinds ...Kind) *Registry {
+	r := &Registry{kinds: map[schema.GroupKind]Kind{}}
+	for _, k := range kinds {
+		r.Register(k)
+	}
+	return r
+}
+
+// Default returns a Registry with the workload, networking, configuration
+// and RBAC kinds that are commonly deployed.
+func Default() *Registry {
+	r := NewRegistry(
+		Kind{Kind: "Service", ResponseGroup: "services", Health: true},
+		Kind{Group: "apps", Kind: "Deployment", ResponseGroup: "deployments", Health: true},
+		Kind{Group: "apps", Kind: "StatefulSet", ResponseGroup: "statefulSets", Health: true},
+		Kind{Group: "apps", Kind: "DaemonSet", ResponseGroup: "daemonSets", Health: true},
+		Kind{Group: "batch", Kind: "CronJob", ResponseGroup: "cronJobs", Health: true},
+		Kind{Kind: "Secret", ResponseGroup: "secrets", Health: true},
+		Kind{Group: "bitnami.com", Kind: "SealedSecret", ResponseGroup: "secrets", Health: true},
+		Kind{Kind: "ConfigMap", ResponseGroup: "configMaps"},
+		Kind{Kind: "PersistentVolumeClaim", ResponseGroup: "persistentVolumeClaims", Health: true},
+		Kind{Group: "networking.k8s.io", Kind: "Ingress", ResponseGroup: "ingresses", Health: true},
+		Kind{Group: "route.openshift.io", Kind: "Route", ResponseGroup: "routes"},
+		Kind{Group: "rbac.authorization.k8s.io", Kind: "RoleBinding", ResponseGroup: "roleBindings"},
+		Kind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRole", ResponseGroup: "clusterRoles"},
+		Kind{Group: "rbac.authorization.k8s.io", Kind: "ClusterRoleBinding", ResponseGroup: "clusterRoleBindings"},
+	)
+	r.builtin = map[string]schema.GroupKind{}
+	r.builtinGroups = map[string]bool{}
+	for gk := range r.kinds {
+		r.builtin[gk.Kind] = gk
+		r.builtinGroups[gk.Group] = true
+	}
+	return r
+}
+
+// Register adds a kind to the registry, replacing the kind with the same
+// group and kind if it has already been registered.
+func (r *Registry) Register(k Kind) {
+	r.kinds[schema.GroupKind{Group: k.Group, Kind: k.Kind}] = k
+}
+
+// Lookup returns the registered kind, and false if it isn't registered.
+//
+// Resources of a default kind are matched by their kind alone if they're
+// reported in the group of another default kind, e.g. a Secret in the "apps"
+// group, as resources were matched by kind before kinds were registered by
+// group. Kinds in other groups, e.g. Knative Services, are never matched to a
+// default kind.
+func (r *Registry) Lookup(group, kind string) (Kind, bool) {
+	if k, ok := r.kinds[schema.GroupKind{Group: group, Kind: kind}]; ok {
+		return k, true
+	}
+	if gk, ok := r.builtin[kind]; ok && r.builtinGroups[group] {
+		k, ok := r.kinds[gk]
+		return k, ok
+	}
+	return Kind{}, false
+}
+
+// ResponseGroups returns the sorted names of the groups that the registered
+// kinds are reported in.
+func (r *Registry) ResponseGroups() []string {
+	seen := map[string]bool{}
+	groups := []string{}
+	for _, k := range r.kinds {
+		if !seen[k.ResponseGroup] {
+			seen[k.ResponseGroup] = true
+			groups = append(groups, k.ResponseGroup)
+		}
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+// reservedGroups are the fields of the v1 application details that the
+// response groups are reported alongside, a group can't replace them.
+var reservedGroups = map[string]bool{
+	"environment":  true,
+	"cluster":      true,
+	"lastDeployed": true,
+	"status":       true,
+	"revision":     true,
+}
+
+// LoadFile parses a YAML list of kinds from a file.
+func LoadFile(filename string) ([]Kind, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the resource kinds: %w", err)
+	}
+	var kinds []Kind
+	if err := yaml.UnmarshalStrict(b, &kinds); err != nil {
+		return nil, fmt.Errorf("failed to parse the resource kinds in %s: %w", filename, err)
+	}
+	for i, k := range kinds {
+		if k.Kind == "" || k.ResponseGroup == "" {
+			return nil, fmt.Errorf("resource kind %d in %s must have a kind and responseGroup", i, filename)
+		}
+		if reservedGroups[k.ResponseGroup] {
+			return nil, fmt.Errorf("resource kind %d in %s has the reserved responseGroup %q", i, filename, k.ResponseGroup)
+		}
+	}
+	return kinds, nil
+}
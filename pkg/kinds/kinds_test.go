@@ -0,0 +1,96 @@
+package kinds
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestLookup(t *testing.T) {
+	r := Default()
+
+	lookupTests := []struct {
+		group string
+		kind  string
+		want  Kind
+		found bool
+	}{
+		{"", "Service", Kind{Kind: "Service", ResponseGroup: "services", Health: true}, true},
+		{"apps", "StatefulSet", Kind{Group: "apps", Kind: "StatefulSet", ResponseGroup: "statefulSets", Health: true}, true},
+		{"bitnami.com", "SealedSecret", Kind{Group: "bitnami.com", Kind: "SealedSecret", ResponseGroup: "secrets", Health: true}, true},
+		{"serving.knative.dev", "Service", Kind{}, false},
+		{"apps", "Secret", Kind{Kind: "Secret", ResponseGroup: "secrets", Health: true}, true},
+		{"serving.knative.dev", "Secret", Kind{}, false},
+		{"apps", "Widget", Kind{}, false},
+	}
+
+	for _, tt := range lookupTests {
+		got, ok := r.Lookup(tt.group, tt.kind)
+		if ok != tt.found {
+			t.Errorf("Lookup(%q, %q) got %v, want %v", tt.group, tt.kind, ok, tt.found)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("Lookup(%q, %q) failed:\n%s", tt.group, tt.kind, diff)
+		}
+	}
+}
+
+func TestResponseGroups(t *testing.T) {
+	r := NewRegistry(
+		Kind{Kind: "Secret", ResponseGroup: "secrets"},
+		Kind{Group: "bitnami.com", Kind: "SealedSecret", ResponseGroup: "secrets"},
+		Kind{Kind: "ConfigMap", ResponseGroup: "configMaps"},
+	)
+
+	want := []string{"configMaps", "secrets"}
+	if diff := cmp.Diff(want, r.ResponseGroups()); diff != "" {
+		t.Fatalf("response groups failed:\n%s", diff)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	loaded, err := LoadFile("testdata/kinds.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := Default()
+	for _, k := range loaded {
+		r.Register(k)
+	}
+
+	if k, _ := r.Lookup("serving.knative.dev", "Service"); k.ResponseGroup != "knativeServices" || !k.Health {
+		t.Fatalf("got %#v for the Knative Service", k)
+	}
+	if k, _ := r.Lookup("", "ConfigMap"); k.ResponseGroup != "configs" {
+		t.Fatalf("got %#v, want the ConfigMap kind to be replaced", k)
+	}
+	if k, _ := r.Lookup("", "Service"); k.ResponseGroup != "services" {
+		t.Fatalf("got %#v, want the Service kind to be unchanged", k)
+	}
+}
+
+func TestLoadFileWithInvalidKinds(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		content string
+	}{
+		{"missing response group", "- kind: Widget\n"},
+		{"missing kind", "- responseGroup: widgets\n"},
+		{"unknown field", "- kind: Widget\n  responseGroup: widgets\n  healthy: true\n"},
+		{"reserved response group", "- kind: Widget\n  responseGroup: status\n"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "kinds.yaml")
+			if err := os.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadFile(filename); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
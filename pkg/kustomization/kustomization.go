@@ -0,0 +1,106 @@
+package kustomization
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/yaml"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+// File is the name of the kustomization file in a directory.
+const File = "kustomization.yaml"
+
+// Parse parses the body of a kustomization file.
+func Parse(body []byte) (*types.Kustomization, error) {
+	k := &types.Kustomization{}
+	if err := yaml.Unmarshal(body, k); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Dirs returns the directories in a repo that have a kustomization.yaml,
+// starting with the directory, and following the resources, components and
+// bases in each kustomization.yaml.
+//
+// Remote resources, files, and directories outside of the repo are not
+// followed.
+func Dirs(ctx context.Context, client git.SCM, repo, ref, dir string) ([]string, error) {
+	var dirs []string
+	seen := map[string]bool{}
+	pending := []string{path.Clean(dir)}
+	for len(pending) > 0 {
+		dir := pending[0]
+		pending = pending[1:]
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		filename := path.Join(dir, File)
+		body, err := client.FileContents(ctx, repo, filename, ref)
+		if git.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		k, err := Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+		}
+		dirs = append(dirs, dir)
+		//nolint:staticcheck // bases are deprecated, but still used.
+		for _, r := range append(append(k.Resources, k.Components...), k.Bases...) {
+			if child, ok := localDir(dir, r); ok {
+				pending = append(pending, child)
+			}
+		}
+	}
+	return dirs, nil
+}
+
+// Within returns true if the path is the directory, or is within it.
+func Within(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// localDir returns the directory in the repo that a kustomization resource
+// refers to, if it is a local directory.
+func localDir(dir, resource string) (string, bool) {
+	if strings.Contains(resource, "://") || strings.HasPrefix(resource, "github.com/") {
+		return "", false
+	}
+	switch path.Ext(resource) {
+	case ".yaml", ".yml", ".json":
+		return "", false
+	}
+	child := path.Join(dir, resource)
+	if child == ".." || strings.HasPrefix(child, "../") {
+		return "", false
+	}
+	return child, true
+}
+
+// ImageReference returns the image that a kustomization image resolves to,
+// e.g. "quay.io/example/taxi:v1".
+func ImageReference(image types.Image) string {
+	ref := image.Name
+	if image.NewName != "" {
+		ref = image.NewName
+	}
+	if image.NewTag != "" {
+		ref += ":" + image.NewTag
+	}
+	if image.TagSuffix != "" {
+		ref += ":*" + image.TagSuffix
+	}
+	if image.Digest != "" {
+		ref += "@" + image.Digest
+	}
+	return ref
+}
@@ -0,0 +1,101 @@
+package kustomization
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+func TestDirs(t *testing.T) {
+	client := stubSCM{files: map[string]string{
+		"environments/dev/apps/taxi/kustomization.yaml":      "resources:\n- base\n- namespace.yaml\n- https://github.com/example/shared//config\n",
+		"environments/dev/apps/taxi/base/kustomization.yaml": "resources:\n- ../../../../../apps/taxi\n- ../../../../../../outside\n",
+		"apps/taxi/kustomization.yaml":                       "bases:\n- ../../environments/dev/apps/taxi\ncomponents:\n- components/tls\n",
+		"apps/taxi/components/tls/kustomization.yaml":        "kind: Component\n",
+	}}
+
+	dirs, err := Dirs(context.TODO(), client, "example/gitops", "main", "environments/dev/apps/taxi/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"environments/dev/apps/taxi",
+		"environments/dev/apps/taxi/base",
+		"apps/taxi",
+		"apps/taxi/components/tls",
+	}
+	if diff := cmp.Diff(want, dirs); diff != "" {
+		t.Fatalf("incorrect dirs:\n%s", diff)
+	}
+}
+
+func TestDirsWithNoKustomization(t *testing.T) {
+	dirs, err := Dirs(context.TODO(), stubSCM{}, "example/gitops", "main", "environments/dev/apps/taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 0 {
+		t.Fatalf("got %v, want no dirs", dirs)
+	}
+}
+
+func TestLocalDir(t *testing.T) {
+	dirTests := []struct {
+		dir      string
+		resource string
+		want     string
+		wantOK   bool
+	}{
+		{"apps/taxi", "base", "apps/taxi/base", true},
+		{"apps/taxi/overlays/config", "../../base", "apps/taxi/base", true},
+		{"apps/taxi", "../../../shared", "", false},
+		{"apps/taxi", "deployment.yaml", "", false},
+		{"apps/taxi", "https://github.com/example/shared//config", "", false},
+		{"apps/taxi", "github.com/example/shared/config?ref=v1", "", false},
+	}
+
+	for _, tt := range dirTests {
+		got, ok := localDir(tt.dir, tt.resource)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("localDir(%q, %q) got (%q, %v), want (%q, %v)", tt.dir, tt.resource, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestImageReference(t *testing.T) {
+	imageTests := []struct {
+		image types.Image
+		want  string
+	}{
+		{types.Image{Name: "quay.io/example/taxi"}, "quay.io/example/taxi"},
+		{types.Image{Name: "quay.io/example/taxi", NewTag: "v1"}, "quay.io/example/taxi:v1"},
+		{types.Image{Name: "taxi", NewName: "quay.io/example/taxi", NewTag: "v1"}, "quay.io/example/taxi:v1"},
+		{types.Image{Name: "quay.io/example/taxi", Digest: "sha256:abc"}, "quay.io/example/taxi@sha256:abc"},
+	}
+
+	for _, tt := range imageTests {
+		if got := ImageReference(tt.image); got != tt.want {
+			t.Errorf("ImageReference(%#v) got %q, want %q", tt.image, got, tt.want)
+		}
+	}
+}
+
+// stubSCM serves the files from a map of path to contents.
+type stubSCM struct {
+	git.SCM
+	files map[string]string
+}
+
+func (s stubSCM) FileContents(ctx context.Context, repo, p, ref string) ([]byte, error) {
+	if body, ok := s.files[path.Clean(p)]; ok {
+		return []byte(body), nil
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
@@ -7,4 +7,8 @@ type Interface interface {
 
 	// CountFailedAPICall records failed API calls to the upstream hosting service.
 	CountFailedAPICall(name string)
+
+	// SetRateLimitRemaining records the number of requests remaining in the
+	// rate limit for the upstream hosting service.
+	SetRateLimitRemaining(host string, remaining int)
 }
@@ -9,6 +9,7 @@ import (
 type PrometheusMetrics struct {
 	apiCalls       *prometheus.CounterVec
 	failedAPICalls *prometheus.CounterVec
+	rateLimit      *prometheus.GaugeVec
 }
 
 // New creates and returns a PrometheusMetrics initialised with prometheus
@@ -31,8 +32,15 @@ func New(ns string, reg prometheus.Registerer) *PrometheusMetrics {
 		Help:      "Count of failed API Calls made",
 	}, []string{"kind"})
 
+	pm.rateLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: ns,
+		Name:      "api_rate_limit_remaining",
+		Help:      "Requests remaining in the rate limit of the upstream service",
+	}, []string{"host"})
+
 	reg.MustRegister(pm.apiCalls)
 	reg.MustRegister(pm.failedAPICalls)
+	reg.MustRegister(pm.rateLimit)
 	return pm
 }
 
@@ -45,3 +53,9 @@ func (m *PrometheusMetrics) CountAPICall(name string) {
 func (m *PrometheusMetrics) CountFailedAPICall(name string) {
 	m.failedAPICalls.With(prometheus.Labels{"kind": name}).Inc()
 }
+
+// SetRateLimitRemaining records the requests remaining in the rate limit of
+// upstream services, from the most recent response.
+func (m *PrometheusMetrics) SetRateLimitRemaining(host string, remaining int) {
+	m.rateLimit.With(prometheus.Labels{"host": host}).Set(float64(remaining))
+}
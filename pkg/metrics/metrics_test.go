@@ -37,3 +37,18 @@ dsl_failed_api_calls_total{kind="commit_status"} 1
 		t.Fatal(err)
 	}
 }
+
+func TestSetRateLimitRemaining(t *testing.T) {
+	m := New("dsl", prometheus.NewRegistry())
+	m.SetRateLimitRemaining("api.github.com", 4999)
+	m.SetRateLimitRemaining("api.github.com", 4998)
+
+	err := testutil.CollectAndCompare(m.rateLimit, strings.NewReader(`
+# HELP dsl_api_rate_limit_remaining Requests remaining in the rate limit of the upstream service
+# TYPE dsl_api_rate_limit_remaining gauge
+dsl_api_rate_limit_remaining{host="api.github.com"} 4998
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
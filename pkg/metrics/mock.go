@@ -7,11 +7,14 @@ var _ Interface = (*MockMetrics)(nil)
 type MockMetrics struct {
 	APICalls       int
 	FailedAPICalls int
+	// RateLimitRemaining is the last remaining rate limit recorded for each
+	// host.
+	RateLimitRemaining map[string]int
 }
 
 // NewMock creates and returns a MockMetrics.
 func NewMock() *MockMetrics {
-	return &MockMetrics{}
+	return &MockMetrics{RateLimitRemaining: map[string]int{}}
 }
 
 // CountAPICall records outgoing API calls to upstream services.
@@ -23,3 +26,8 @@ func (m *MockMetrics) CountAPICall(name string) {
 func (m *MockMetrics) CountFailedAPICall(name string) {
 	m.FailedAPICalls++
 }
+
+// SetRateLimitRemaining records the remaining rate limit for the host.
+func (m *MockMetrics) SetRateLimitRemaining(host string, remaining int) {
+	m.RateLimitRemaining[host] = remaining
+}
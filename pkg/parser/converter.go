@@ -41,6 +41,10 @@ func newUnstructuredConverter() (*unstructuredConverter, error) {
 //
 // If unable to convert using the Kind of obj, then an error is returned.
 func (c *unstructuredConverter) fromUnstructured(o *unstructured.Unstructured) (interface{}, error) {
+	if newCR, ok := customResources[o.GroupVersionKind()]; ok {
+		obj := newCR()
+		return obj, runtime.DefaultUnstructuredConverter.FromUnstructured(o.Object, obj)
+	}
 	newObj, err := c.scheme.New(o.GetObjectKind().GroupVersionKind())
 	if err != nil {
 		return nil, err
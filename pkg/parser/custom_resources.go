@@ -0,0 +1,63 @@
+package parser
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// customResources are the custom resources that images are extracted from.
+//
+// Rather than registering the types from their projects, they're converted to
+// types with only the fields that have the images.
+var customResources = map[schema.GroupVersionKind]func() interface{}{
+	{Group: "argoproj.io", Version: "v1alpha1", Kind: "Rollout"}:   func() interface{} { return &podTemplateResource{} },
+	{Group: "serving.knative.dev", Version: "v1", Kind: "Service"}: func() interface{} { return &podTemplateResource{} },
+	{Group: "tekton.dev", Version: "v1", Kind: "Task"}:             func() interface{} { return &tektonTask{} },
+	{Group: "tekton.dev", Version: "v1beta1", Kind: "Task"}:        func() interface{} { return &tektonTask{} },
+	{Group: "tekton.dev", Version: "v1", Kind: "Pipeline"}:         func() interface{} { return &tektonPipeline{} },
+	{Group: "tekton.dev", Version: "v1beta1", Kind: "Pipeline"}:    func() interface{} { return &tektonPipeline{} },
+}
+
+// podTemplateResource is a resource with a pod template in its spec, e.g. an
+// Argo Rollouts Rollout, or a Knative Service, where the template is for the
+// revisions of the service.
+//
+// A Rollout that refers to the template of a Deployment with a workloadRef
+// has no template.
+type podTemplateResource struct {
+	Spec struct {
+		Template corev1.PodTemplateSpec `json:"template"`
+	} `json:"spec"`
+}
+
+// tektonTask is a Tekton Task.
+type tektonTask struct {
+	Spec tektonTaskSpec `json:"spec"`
+}
+
+// tektonTaskSpec is the steps and sidecars in a Tekton Task, the steps can
+// inherit their image from the step template.
+type tektonTaskSpec struct {
+	StepTemplate *tektonContainer  `json:"stepTemplate"`
+	Steps        []tektonContainer `json:"steps"`
+	Sidecars     []tektonContainer `json:"sidecars"`
+}
+
+type tektonContainer struct {
+	Name  string `json:"name"`
+	Image string `json:"image"`
+}
+
+// tektonPipeline is a Tekton Pipeline, only the tasks that are embedded in
+// the pipeline have images, the tasks that it refers to are separate
+// resources.
+type tektonPipeline struct {
+	Spec struct {
+		Tasks   []tektonPipelineTask `json:"tasks"`
+		Finally []tektonPipelineTask `json:"finally"`
+	} `json:"spec"`
+}
+
+type tektonPipelineTask struct {
+	TaskSpec *tektonTaskSpec `json:"taskSpec"`
+}
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/argoproj/argo-cd/v3/util/glob"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/kustomize/api/konfig"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// manifestFile matches the names of the files that are read from a
+// directory of manifests.
+var manifestFile = regexp.MustCompile(`\.(yaml|yml|json)$`)
+
+// Directory configures how a directory of manifests is read, with the same
+// options as the directory source of an Argo CD Application.
+type Directory struct {
+	// Recurse reads the manifests in subdirectories.
+	Recurse bool `json:"recurse,omitempty"`
+	// Include is a glob of the paths of the files to read, relative to the
+	// directory, e.g. "{*.yaml,*.yml}".
+	Include string `json:"include,omitempty"`
+	// Exclude is a glob of the paths of the files not to read, relative to
+	// the directory.
+	Exclude string `json:"exclude,omitempty"`
+}
+
+// isKustomization returns true if the directory has a kustomization file in
+// it.
+func isKustomization(files fs.FileSystem, dir string) bool {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		if files.Exists(filepath.Join(dir, name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readDirectory reads the resources in the YAML and JSON files in the
+// directory, files can have more than one resource in them.
+//
+// If the options are nil, only the files in the directory, and not in its
+// subdirectories, are read.
+func readDirectory(files fs.FileSystem, dir string, opts *Directory) ([]*unstructured.Unstructured, error) {
+	if opts == nil {
+		opts = &Directory{}
+	}
+	var objs []*unstructured.Unstructured
+	// The paths are relative to the directory, as the file system reports
+	// it, which is walked first.
+	root := ""
+	err := files.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if root == "" {
+			root = name
+			return nil
+		}
+		if info.IsDir() {
+			if !opts.Recurse {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(name, root), string(filepath.Separator)))
+		switch {
+		case !manifestFile.MatchString(info.Name()) || !info.Mode().IsRegular():
+			return nil
+		case opts.Exclude != "" && glob.Match(opts.Exclude, rel):
+			return nil
+		case opts.Include != "" && !glob.Match(opts.Include, rel):
+			return nil
+		}
+		b, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		decoded, err := decodeManifests(rel, b)
+		if err != nil {
+			return err
+		}
+		objs = append(objs, decoded...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the manifests in %s: %w", dir, err)
+	}
+	return objs, nil
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-developer/gitops-backend/test"
+)
+
+func TestParseConfigWithDirectory(t *testing.T) {
+	directoryTests := []struct {
+		name string
+		dir  *Directory
+		want []string
+	}{
+		{
+			"defaults",
+			nil,
+			[]string{"ConfigMap/taxi-config", "Deployment/taxi", "Service/taxi", "ServiceAccount/taxi"},
+		},
+		{
+			"recurse",
+			&Directory{Recurse: true},
+			[]string{"ConfigMap/taxi-config", "Deployment/taxi", "Job/taxi-migrate", "Service/taxi", "ServiceAccount/taxi"},
+		},
+		{
+			"include",
+			&Directory{Recurse: true, Include: "{*.yml,*.json}"},
+			[]string{"ConfigMap/taxi-config", "Service/taxi", "ServiceAccount/taxi"},
+		},
+		{
+			"exclude",
+			&Directory{Recurse: true, Exclude: "nested/*"},
+			[]string{"ConfigMap/taxi-config", "Deployment/taxi", "Service/taxi", "ServiceAccount/taxi"},
+		},
+	}
+
+	for _, tt := range directoryTests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := New(nil).parseConfig("testdata/manifests", &Source{Directory: tt.dir}, fs.MakeFsOnDisk())
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertCmp(t, tt.want, resourceNames(res), "failed to match parsed resources")
+		})
+	}
+}
+
+func TestParseConfigWithDirectoryExtractsImages(t *testing.T) {
+	res, err := New(nil).parseConfig("testdata/manifests", &Source{Directory: &Directory{Recurse: true, Include: "*.yaml"}}, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []*Resource{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Name: "taxi",
+			Labels: map[string]string{nameLabel: "taxi"},
+			Images: []Image{
+				{
+					Reference: "quay.io/example/taxi:v1.0.0", Registry: "quay.io", Repository: "example/taxi", Tag: "v1.0.0",
+					Container: "taxi", ContainerType: RegularContainer,
+				},
+			},
+		},
+		{
+			Group: "batch", Version: "v1", Kind: "Job", Name: "taxi-migrate",
+			Images: []Image{
+				{
+					Reference: "quay.io/example/taxi-migrate:v1.0.0", Registry: "quay.io", Repository: "example/taxi-migrate", Tag: "v1.0.0",
+					Container: "migrate", ContainerType: RegularContainer,
+				},
+			},
+		},
+	}
+	assertCmp(t, want, res, "failed to match parsed resources")
+}
+
+func TestParseConfigWithInvalidManifest(t *testing.T) {
+	files := fs.MakeFsInMemory()
+	if err := files.WriteFile("manifests/values.yaml", []byte("replicas: 1\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(nil).parseConfig("manifests", nil, files)
+	if err == nil || !strings.Contains(err.Error(), "failed to decode values.yaml") {
+		t.Fatalf("got error %v, want a decoding error", err)
+	}
+}
+
+func TestParseFromGitWithDirectory(t *testing.T) {
+	res, err := ParseFromGit("pkg/parser/testdata/manifests", "", test.MakeCloneOptions(), &Source{Directory: &Directory{Recurse: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 5 {
+		t.Fatalf("got %d resources, want 5", len(res))
+	}
+}
+
+func resourceNames(res []*Resource) []string {
+	names := []string{}
+	for _, r := range res {
+		names = append(names, r.Kind+"/"+r.Name)
+	}
+	sort.Strings(names)
+	return names
+}
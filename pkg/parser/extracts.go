@@ -6,12 +6,12 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	batchv1beta1 "k8s.io/api/batch/v1beta1"
 	corev1 "k8s.io/api/core/v1"
-
-	"github.com/redhat-developer/gitops-backend/internal/sets"
 )
 
-// Deployments, DeploymentConfigs, StatefulSets, DaemonSets, Jobs, CronJobs
-func extractImages(v interface{}) []string {
+// Deployments, DeploymentConfigs, StatefulSets, DaemonSets, ReplicaSets,
+// Pods, Jobs, CronJobs, Argo Rollouts, Knative Services and Tekton Tasks and
+// Pipelines
+func extractImages(v interface{}) []Image {
 	switch k := v.(type) {
 	case *appsv1.Deployment:
 		return extractImagesFromPodTemplateSpec(k.Spec.Template)
@@ -19,23 +19,66 @@ func extractImages(v interface{}) []string {
 		return extractImagesFromPodTemplateSpec(k.Spec.Template)
 	case *appsv1.DaemonSet:
 		return extractImagesFromPodTemplateSpec(k.Spec.Template)
+	case *appsv1.ReplicaSet:
+		return extractImagesFromPodTemplateSpec(k.Spec.Template)
+	case *corev1.Pod:
+		return extractImagesFromPodSpec(k.Spec)
 	case *batchv1.Job:
 		return extractImagesFromPodTemplateSpec(k.Spec.Template)
+	case *batchv1.CronJob:
+		return extractImagesFromPodTemplateSpec(k.Spec.JobTemplate.Spec.Template)
 	case *batchv1beta1.CronJob:
 		return extractImagesFromPodTemplateSpec(k.Spec.JobTemplate.Spec.Template)
 	case *ocpappsv1.DeploymentConfig:
 		return extractImagesFromPodTemplateSpec(*k.Spec.Template)
+	case *podTemplateResource:
+		return extractImagesFromPodTemplateSpec(k.Spec.Template)
+	case *tektonTask:
+		return extractImagesFromTaskSpecs(&k.Spec)
+	case *tektonPipeline:
+		var specs []*tektonTaskSpec
+		for _, t := range append(k.Spec.Tasks, k.Spec.Finally...) {
+			if t.TaskSpec != nil {
+				specs = append(specs, t.TaskSpec)
+			}
+		}
+		return extractImagesFromTaskSpecs(specs...)
 	}
 	return nil
 }
 
-func extractImagesFromPodTemplateSpec(p corev1.PodTemplateSpec) []string {
-	images := sets.NewStringSet()
-	for _, c := range p.Spec.InitContainers {
-		images.Add(c.Image)
+func extractImagesFromPodTemplateSpec(p corev1.PodTemplateSpec) []Image {
+	return extractImagesFromPodSpec(p.Spec)
+}
+
+func extractImagesFromPodSpec(p corev1.PodSpec) []Image {
+	var images []Image
+	for _, c := range p.InitContainers {
+		images = appendImage(images, c.Image, c.Name, InitContainer)
+	}
+	for _, c := range p.Containers {
+		images = appendImage(images, c.Image, c.Name, RegularContainer)
 	}
-	for _, c := range p.Spec.Containers {
-		images.Add(c.Image)
+	return images
+}
+
+func extractImagesFromTaskSpecs(specs ...*tektonTaskSpec) []Image {
+	var images []Image
+	for _, s := range specs {
+		defaultImage := ""
+		if s.StepTemplate != nil {
+			defaultImage = s.StepTemplate.Image
+		}
+		for _, c := range s.Steps {
+			image := c.Image
+			if image == "" {
+				image = defaultImage
+			}
+			images = appendImage(images, image, c.Name, RegularContainer)
+		}
+		for _, c := range s.Sidecars {
+			images = appendImage(images, c.Image, c.Name, RegularContainer)
+		}
 	}
-	return images.Elements()
+	return images
 }
@@ -5,6 +5,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 func TestExtractImagesFromPodTemplateSpec(t *testing.T) {
@@ -22,8 +24,74 @@ func TestExtractImagesFromPodTemplateSpec(t *testing.T) {
 
 	images := extractImagesFromPodTemplateSpec(spec)
 
-	want := []string{"example/http-api", "redis:6-alpine"}
+	want := []Image{
+		{
+			Reference: "redis:6-alpine", Registry: "docker.io", Repository: "library/redis", Tag: "6-alpine",
+			Container: "redis", ContainerType: InitContainer,
+		},
+		{
+			Reference: "redis:6-alpine", Registry: "docker.io", Repository: "library/redis", Tag: "6-alpine",
+			Container: "redis-test", ContainerType: InitContainer,
+		},
+		{
+			Reference: "example/http-api", Registry: "docker.io", Repository: "example/http-api", Tag: "latest",
+			Container: "http", ContainerType: RegularContainer,
+		},
+	}
 	if diff := cmp.Diff(want, images); diff != "" {
 		t.Fatalf("set failed:\n%s", diff)
 	}
 }
+
+func TestParseConfigExtractsImagesFromWorkloads(t *testing.T) {
+	res, err := New(nil).parseConfig("testdata/workloads", nil, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images := map[string][]string{}
+	for _, r := range res {
+		images[r.Group+"/"+r.Version+"/"+r.Kind] = references(r.Images)
+	}
+	want := map[string][]string{
+		"/v1/Pod":                        {"busybox:1.36", "registry.access.redhat.com/ubi9/ubi:latest"},
+		"apps/v1/ReplicaSet":             {"quay.io/example/frontend:v3"},
+		"batch/v1/CronJob":               {"quay.io/example/report:v1.2.0"},
+		"argoproj.io/v1alpha1/Rollout":   {"quay.io/example/taxi:v2.0.0"},
+		"serving.knative.dev/v1/Service": {"quay.io/example/greeter:v1"},
+		"tekton.dev/v1/Task":             {"quay.io/buildah/stable:v1.35", "registry.access.redhat.com/ubi9/ubi-minimal:latest", "registry:2"},
+		"tekton.dev/v1beta1/Pipeline":    {"golang:1.22", "curlimages/curl:8.7.1"},
+	}
+	if diff := cmp.Diff(want, images); diff != "" {
+		t.Fatalf("failed to extract images:\n%s", diff)
+	}
+}
+
+func TestExtractImagesFromRolloutWithWorkloadRef(t *testing.T) {
+	conv, err := newUnstructuredConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rollout := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   map[string]interface{}{"name": "taxi"},
+		"spec": map[string]interface{}{
+			"workloadRef": map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "taxi"},
+		},
+	}}
+
+	r := extractResource(conv, nil, rollout)
+
+	if r.Images != nil {
+		t.Fatalf("got images %v, want none", r.Images)
+	}
+}
+
+func references(images []Image) []string {
+	var refs []string
+	for _, i := range images {
+		refs = append(refs, i.Reference)
+	}
+	return refs
+}
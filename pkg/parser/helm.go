@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/ignore"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// fileRepository is the prefix for the repository of a chart dependency that
+// is in the same repository as the chart.
+const fileRepository = "file://"
+
+// Helm configures how a Helm chart is rendered, with the same options as the
+// Helm source of an Argo CD Application.
+type Helm struct {
+	// ValueFiles are the values files to render the chart with, relative to
+	// the chart, e.g. "values-dev.yaml", the values in later files override
+	// the values in earlier files, and all of them override the chart's
+	// values.yaml.
+	ValueFiles []string `json:"valueFiles,omitempty"`
+}
+
+// isChart returns true if the directory has a Helm chart in it.
+func isChart(files fs.FileSystem, dir string) bool {
+	return files.Exists(path.Join(dir, chartutil.ChartfileName))
+}
+
+// renderChart renders the templates in the Helm chart in the directory, with
+// the values in the chart's values.yaml, and the values files in the options,
+// which can be nil, as `helm template` would, without access to a cluster.
+//
+// The release is named after the directory.
+//
+// Dependencies of the chart must be in its charts directory, or be in the
+// repository, with a file:// repository that is relative to the chart, this
+// allows an environment to have a chart that depends on a shared chart, with
+// the values for the environment.
+//
+// As with Argo CD, the values files and dependencies must be in the
+// repository, the paths in the file system are relative to its root.
+func renderChart(files fs.FileSystem, dir string, opts *Helm) ([]*unstructured.Unstructured, error) {
+	chrt, err := loadChart(files, dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := readValueFiles(files, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	values, err := chartutil.CoalesceValues(chrt, overrides)
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge the values for the chart in %s: %w", dir, err)
+	}
+	if err := chartutil.ProcessDependenciesWithMerge(chrt, values); err != nil {
+		return nil, fmt.Errorf("failed to process the dependencies of the chart in %s: %w", dir, err)
+	}
+	options := chartutil.ReleaseOptions{Name: path.Base(dir), Revision: 1, IsInstall: true}
+	renderValues, err := chartutil.ToRenderValues(chrt, values, options, chartutil.DefaultCapabilities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the values for the chart in %s: %w", dir, err)
+	}
+	rendered, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render the chart in %s: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(rendered))
+	for name := range rendered {
+		base := path.Base(name)
+		if strings.HasPrefix(base, "_") || base == "NOTES.txt" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var objs []*unstructured.Unstructured
+	for _, name := range names {
+		decoded, err := decodeManifests(name, []byte(rendered[name]))
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, decoded...)
+	}
+	return objs, nil
+}
+
+// readValueFiles reads the values files in the options, relative to the chart
+// in the directory, and merges them, with the values in later files taking
+// precedence.
+func readValueFiles(files fs.FileSystem, dir string, opts *Helm) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if opts == nil {
+		return values, nil
+	}
+	for _, name := range opts.ValueFiles {
+		filename, ok := repositoryPath(dir, name)
+		if !ok {
+			return nil, fmt.Errorf("the values file %s for the chart in %s is outside of the repository", name, dir)
+		}
+		b, err := files.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the values file %s for the chart in %s: %w", name, dir, err)
+		}
+		parsed, err := chartutil.ReadValues(b)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the values file %s for the chart in %s: %w", name, dir, err)
+		}
+		values = chartutil.MergeTables(parsed, values)
+	}
+	return values, nil
+}
+
+// loadChart loads the files in the chart in the directory, and the charts
+// that it depends on in the repository, the charts that are being loaded are
+// the charts that depend on it, which it must not depend on.
+func loadChart(files fs.FileSystem, dir string, loading []string) (*chart.Chart, error) {
+	dir = path.Clean(dir)
+	for i, d := range loading {
+		if d == dir {
+			return nil, fmt.Errorf("the chart in %s depends on itself: %s -> %s", dir, strings.Join(loading[i:], " -> "), dir)
+		}
+	}
+	loading = append(loading, dir)
+
+	rules := ignore.Empty()
+	if b, err := files.ReadFile(path.Join(dir, ignore.HelmIgnore)); err == nil {
+		rules, err = ignore.Parse(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s in %s: %w", ignore.HelmIgnore, dir, err)
+		}
+	}
+	rules.AddDefaults()
+
+	var loaded []*loader.BufferedFile
+	// The names are relative to the directory, as the file system reports
+	// it, which is walked first.
+	root := ""
+	err := files.Walk(dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if root == "" {
+			root = name
+			return nil
+		}
+		rel := filepath.ToSlash(strings.TrimPrefix(strings.TrimPrefix(name, root), string(filepath.Separator)))
+		switch {
+		case info.IsDir():
+			if rules.Ignore(rel, info) {
+				return filepath.SkipDir
+			}
+			return nil
+		case rules.Ignore(rel, info) || !info.Mode().IsRegular():
+			return nil
+		}
+		b, err := files.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		loaded = append(loaded, &loader.BufferedFile{Name: rel, Data: b})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the chart in %s: %w", dir, err)
+	}
+	chrt, err := loader.LoadFiles(loaded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the chart in %s: %w", dir, err)
+	}
+
+	for _, d := range chrt.Metadata.Dependencies {
+		if hasDependency(chrt, d.Name) {
+			continue
+		}
+		if !strings.HasPrefix(d.Repository, fileRepository) {
+			return nil, fmt.Errorf("dependency %s of the chart in %s must be in its charts directory", d.Name, dir)
+		}
+		depDir, ok := repositoryPath(dir, strings.TrimPrefix(d.Repository, fileRepository))
+		if !ok {
+			return nil, fmt.Errorf("dependency %s of the chart in %s is outside of the repository", d.Name, dir)
+		}
+		dep, err := loadChart(files, depDir, loading)
+		if err != nil {
+			return nil, err
+		}
+		chrt.AddDependency(dep)
+	}
+	return chrt, nil
+}
+
+// repositoryPath returns the path of the name relative to the directory, and
+// false if it's outside of the repository.
+func repositoryPath(dir, name string) (string, bool) {
+	p := path.Join(dir, name)
+	return p, p != ".." && !strings.HasPrefix(p, "../")
+}
+
+func hasDependency(c *chart.Chart, name string) bool {
+	for _, d := range c.Dependencies() {
+		if d.Name() == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/redhat-developer/gitops-backend/test"
+)
+
+func TestParseConfigWithChart(t *testing.T) {
+	res, err := New(nil).parseConfig("testdata/helm/taxi", nil, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{
+		nameLabel:   "taxi",
+		partOfLabel: "taxi",
+	}
+	want := []*Resource{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Name: "taxi-http",
+			Labels: labels,
+			Images: []Image{
+				{
+					Reference: "quay.io/example/taxi:v1.0.0", Registry: "quay.io", Repository: "example/taxi", Tag: "v1.0.0",
+					Container: "taxi", ContainerType: RegularContainer,
+				},
+			},
+		},
+		{
+			Version: "v1", Kind: "Service", Name: "taxi-http",
+			Labels: labels,
+		},
+	}
+	assertCmp(t, want, res, "failed to match parsed resources")
+}
+
+func TestParseConfigWithChartValueFiles(t *testing.T) {
+	valueFilesTests := []struct {
+		name       string
+		valueFiles []string
+		want       string
+	}{
+		{"chart values", nil, "quay.io/example/taxi:v1.0.0"},
+		{"dev", []string{"../values/dev.yaml"}, "quay.io/example/taxi:v2.0.0"},
+		{"stage", []string{"../values/stage.yaml"}, "quay.io/example/taxi-stage:v1.1.0"},
+		{"later files take precedence", []string{"../values/stage.yaml", "../values/dev.yaml"}, "quay.io/example/taxi-stage:v2.0.0"},
+	}
+	for _, tt := range valueFilesTests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &Source{Helm: &Helm{ValueFiles: tt.valueFiles}}
+			res, err := New(nil).parseConfig("testdata/helm/taxi", src, fs.MakeFsOnDisk())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(res) == 0 || len(res[0].Images) != 1 {
+				t.Fatalf("got %#v, want a Deployment with an image", res)
+			}
+			if got := res[0].Images[0].Reference; got != tt.want {
+				t.Fatalf("got image %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseConfigWithMissingChartValueFile(t *testing.T) {
+	src := &Source{Helm: &Helm{ValueFiles: []string{"values-prod.yaml"}}}
+
+	_, err := New(nil).parseConfig("testdata/helm/taxi", src, fs.MakeFsOnDisk())
+	if err == nil || !strings.Contains(err.Error(), "failed to read the values file values-prod.yaml for the chart in testdata/helm/taxi") {
+		t.Fatalf("got error %v, want a missing values file", err)
+	}
+}
+
+func TestParseConfigWithChartValueFileOutsideTheRepository(t *testing.T) {
+	src := &Source{Helm: &Helm{ValueFiles: []string{"../../../../../values.yaml"}}}
+
+	_, err := New(nil).parseConfig("testdata/helm/taxi", src, fs.MakeFsOnDisk())
+	if err == nil || !strings.Contains(err.Error(), "the values file ../../../../../values.yaml for the chart in testdata/helm/taxi is outside of the repository") {
+		t.Fatalf("got error %v, want a values file outside of the repository", err)
+	}
+}
+
+func TestParseConfigWithChartDependency(t *testing.T) {
+	res, err := New(nil).parseConfig("testdata/helm/dev", nil, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{
+		nameLabel:   "taxi",
+		partOfLabel: "dev",
+	}
+	want := []*Resource{
+		{
+			Group: "apps", Version: "v1", Kind: "Deployment", Name: "dev-http",
+			Labels: labels,
+			Images: []Image{
+				{
+					Reference: "quay.io/example/taxi:v2.0.0", Registry: "quay.io", Repository: "example/taxi", Tag: "v2.0.0",
+					Container: "taxi", ContainerType: RegularContainer,
+				},
+			},
+		},
+		{
+			Version: "v1", Kind: "Service", Name: "dev-http",
+			Labels: labels,
+		},
+	}
+	assertCmp(t, want, res, "failed to match parsed resources")
+}
+
+func TestParseConfigWithMissingChartDependency(t *testing.T) {
+	files := fs.MakeFsInMemory()
+	if err := files.WriteFile("chart/Chart.yaml", []byte(`apiVersion: v2
+name: chart
+version: 0.1.0
+dependencies:
+  - name: redis
+    version: 17.0.0
+    repository: https://charts.example.com
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(nil).parseConfig("chart", nil, files)
+	if err == nil || !strings.Contains(err.Error(), "dependency redis of the chart in chart must be in its charts directory") {
+		t.Fatalf("got error %v, want a missing dependency", err)
+	}
+}
+
+func TestParseConfigWithChartDependencyCycle(t *testing.T) {
+	files := fs.MakeFsInMemory()
+	for name, dep := range map[string]string{"first": "second", "second": "first"} {
+		if err := files.WriteFile("charts/"+name+"/Chart.yaml", []byte(`apiVersion: v2
+name: `+name+`
+version: 0.1.0
+dependencies:
+  - name: `+dep+`
+    version: 0.1.0
+    repository: file://../`+dep+`
+`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := New(nil).parseConfig("charts/first", nil, files)
+	if err == nil || !strings.Contains(err.Error(), "the chart in charts/first depends on itself: charts/first -> charts/second -> charts/first") {
+		t.Fatalf("got error %v, want a dependency cycle", err)
+	}
+}
+
+func TestParseConfigWithChartOutsideTheRepository(t *testing.T) {
+	files := fs.MakeFsInMemory()
+	if err := files.WriteFile("chart/Chart.yaml", []byte(`apiVersion: v2
+name: chart
+version: 0.1.0
+dependencies:
+  - name: shared
+    version: 0.1.0
+    repository: file://../../shared
+`)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := New(nil).parseConfig("chart", nil, files)
+	if err == nil || !strings.Contains(err.Error(), "dependency shared of the chart in chart is outside of the repository") {
+		t.Fatalf("got error %v, want a dependency outside of the repository", err)
+	}
+}
+
+func TestDecodeManifests(t *testing.T) {
+	objs, err := decodeManifests("test.yaml", []byte(`---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: first
+---
+# an empty document
+---
+{"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": "second"}}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, o := range objs {
+		names = append(names, o.GetName())
+	}
+	assertCmp(t, []string{"first", "second"}, names, "failed to decode manifests")
+}
+
+func TestDecodeManifestsWithInvalidResource(t *testing.T) {
+	_, err := decodeManifests("test.yaml", []byte("name: test\n"))
+	if err == nil || !strings.Contains(err.Error(), "failed to decode test.yaml: a resource has no apiVersion or kind") {
+		t.Fatalf("got error %v, want an invalid resource", err)
+	}
+}
+
+func TestParseFromGitWithChart(t *testing.T) {
+	res, err := ParseFromGit("pkg/parser/testdata/helm/dev", "", test.MakeCloneOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 {
+		t.Fatalf("got %d resources, want 2", len(res))
+	}
+}
@@ -0,0 +1,93 @@
+package parser
+
+import (
+	"github.com/distribution/reference"
+)
+
+// ContainerType is the type of the container that an image is for.
+type ContainerType string
+
+const (
+	// InitContainer is for the images of init containers, that run to
+	// completion before the other containers start.
+	InitContainer ContainerType = "init"
+	// RegularContainer is for the images of the other containers, including
+	// the steps and sidecars of Tekton Tasks.
+	RegularContainer ContainerType = "regular"
+)
+
+// Image is an image in a resource, with the container that it's for.
+//
+// The registry, repository, tag and digest are normalized as Docker does,
+// e.g. redis:6-alpine is in the docker.io registry, with the repository
+// library/redis, and an image without a tag or digest has the latest tag.
+//
+// If the reference can't be parsed, e.g. it's a Tekton parameter, only the
+// reference is set.
+type Image struct {
+	// Reference is the image as it is in the resource.
+	Reference  string `json:"reference"`
+	Registry   string `json:"registry,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest,omitempty"`
+	// Container is the name of the container, it's empty for the images
+	// that are extracted with image paths.
+	Container     string        `json:"container,omitempty"`
+	ContainerType ContainerType `json:"containerType,omitempty"`
+}
+
+// ParseImage parses an image reference.
+func ParseImage(ref string) (Image, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return Image{Reference: ref}, err
+	}
+	named = reference.TagNameOnly(named)
+	i := Image{
+		Reference:  ref,
+		Registry:   reference.Domain(named),
+		Repository: reference.Path(named),
+	}
+	if t, ok := named.(reference.Tagged); ok {
+		i.Tag = t.Tag()
+	}
+	if d, ok := named.(reference.Digested); ok {
+		i.Digest = d.Digest().String()
+	}
+	return i, nil
+}
+
+// Normalized returns the fully qualified reference, e.g.
+// docker.io/library/redis:6-alpine for redis:6-alpine, or the reference if
+// it couldn't be parsed.
+//
+// Images are the same if their normalized references are equal.
+func (i Image) Normalized() string {
+	if i.Repository == "" {
+		return i.Reference
+	}
+	s := i.Registry + "/" + i.Repository
+	if i.Tag != "" {
+		s += ":" + i.Tag
+	}
+	if i.Digest != "" {
+		s += "@" + i.Digest
+	}
+	return s
+}
+
+// appendImage appends the image for a container, images that can't be parsed
+// are appended with only their reference, and empty images are skipped.
+//
+// Each container has its own image, even if another container has the same
+// image, so that the images of every container are known.
+func appendImage(images []Image, ref, container string, t ContainerType) []Image {
+	if ref == "" {
+		return images
+	}
+	i, _ := ParseImage(ref)
+	i.Container = container
+	i.ContainerType = t
+	return append(images, i)
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/yaml"
+)
+
+// ImagePath is the JSONPath expressions for the images in resources of a
+// kind, e.g. spec.template.spec.containers[*].image.
+type ImagePath struct {
+	// Group is the API group of the kind, empty for the core group.
+	Group   string   `json:"group,omitempty"`
+	Version string   `json:"version"`
+	Kind    string   `json:"kind"`
+	Paths   []string `json:"paths"`
+}
+
+// ImagePaths is the image paths for kinds, e.g. the custom resources of
+// in-house operators.
+//
+// For the kinds that images are already extracted from, the images at the
+// paths are added to them.
+type ImagePaths struct {
+	paths map[schema.GroupVersionKind][]*jsonpath.JSONPath
+}
+
+// NewImagePaths creates and returns ImagePaths with the paths, which are
+// parsed once, an error is returned if an expression is invalid.
+func NewImagePaths(paths ...ImagePath) (*ImagePaths, error) {
+	p := &ImagePaths{paths: map[schema.GroupVersionKind][]*jsonpath.JSONPath{}}
+	for _, ip := range paths {
+		gvk := schema.GroupVersionKind{Group: ip.Group, Version: ip.Version, Kind: ip.Kind}
+		for _, s := range ip.Paths {
+			j, err := parseImagePath(gvk.String(), s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image path %q for %s: %w", s, gvk, err)
+			}
+			p.paths[gvk] = append(p.paths[gvk], j)
+		}
+	}
+	return p, nil
+}
+
+// parseImagePath parses the JSONPath expression for a path.
+//
+// Ranges aren't supported, finding the results of a range changes the parsed
+// expression, which is shared between resources.
+func parseImagePath(name, path string) (*jsonpath.JSONPath, error) {
+	expr := jsonPathExpression(path)
+	parsed, err := jsonpath.Parse(name, expr)
+	if err != nil {
+		return nil, err
+	}
+	if hasRange(parsed.Root) {
+		return nil, errors.New("range is not supported")
+	}
+	j := jsonpath.New(name).AllowMissingKeys(true)
+	if err := j.Parse(expr); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func hasRange(n jsonpath.Node) bool {
+	switch n := n.(type) {
+	case *jsonpath.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, c := range n.Nodes {
+			if hasRange(c) {
+				return true
+			}
+		}
+	case *jsonpath.FilterNode:
+		return hasRange(n.Left) || hasRange(n.Right)
+	case *jsonpath.IdentifierNode:
+		return n.Name == "range"
+	}
+	return false
+}
+
+// LoadImagePaths parses a YAML list of image paths from a file.
+func LoadImagePaths(filename string) (*ImagePaths, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the image paths: %w", err)
+	}
+	var paths []ImagePath
+	if err := yaml.UnmarshalStrict(b, &paths); err != nil {
+		return nil, fmt.Errorf("failed to parse the image paths in %s: %w", filename, err)
+	}
+	for i, p := range paths {
+		if p.Version == "" || p.Kind == "" || len(p.Paths) == 0 {
+			return nil, fmt.Errorf("image path %d in %s must have a version, kind and paths", i, filename)
+		}
+	}
+	return NewImagePaths(paths...)
+}
+
+// appendImages appends the images at the paths for the kind of the resource
+// that aren't already in the images.
+//
+// Paths that are missing from the resource, and values that aren't strings,
+// are ignored. The containers that the images are for aren't known.
+func (p *ImagePaths) appendImages(images []Image, u *unstructured.Unstructured) []Image {
+	if p == nil {
+		return images
+	}
+	seen := map[string]bool{}
+	for _, i := range images {
+		seen[i.Normalized()] = true
+	}
+	for _, parsed := range p.paths[u.GroupVersionKind()] {
+		// A JSONPath keeps state while it finds results, so each resource
+		// uses a copy, the parsed expression isn't changed without ranges.
+		j := *parsed
+		results, err := j.FindResults(u.Object)
+		if err != nil {
+			continue
+		}
+		for _, r := range results {
+			for _, v := range r {
+				s, ok := v.Interface().(string)
+				if !ok || s == "" {
+					continue
+				}
+				i, _ := ParseImage(s)
+				if !seen[i.Normalized()] {
+					seen[i.Normalized()] = true
+					images = append(images, i)
+				}
+			}
+		}
+	}
+	return images
+}
+
+// jsonPathExpression returns the path as a JSONPath template, the braces and
+// leading dot can be left out of the paths, as they are in kubectl's
+// custom-columns.
+func jsonPathExpression(path string) string {
+	if strings.HasPrefix(path, "{") {
+		return path
+	}
+	return "{." + strings.TrimPrefix(path, ".") + "}"
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	fs "sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+func TestParseConfigExtractsImagesWithImagePaths(t *testing.T) {
+	paths, err := LoadImagePaths("testdata/image_paths.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New(paths).parseConfig("testdata/custom", nil, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images := map[string][]string{}
+	for _, r := range res {
+		images[r.Version+"/"+r.Name] = references(r.Images)
+	}
+	want := map[string][]string{
+		"v1/taxi": {
+			"quay.io/example/taxi-migrations:v1.0.0",
+			"quay.io/example/taxi:v1.0.0",
+			"quay.io/example/proxy:v2",
+			"quay.io/example/monitor:latest",
+		},
+		"v1alpha1/legacy-taxi": nil,
+		"v1/empty":             nil,
+	}
+	if diff := cmp.Diff(want, images); diff != "" {
+		t.Fatalf("failed to extract images:\n%s", diff)
+	}
+}
+
+func TestParseConfigWithoutImagePaths(t *testing.T) {
+	res, err := New(nil).parseConfig("testdata/custom", nil, fs.MakeFsOnDisk())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range res {
+		if r.Images != nil {
+			t.Errorf("got images %v for %s, want none", r.Images, r.Name)
+		}
+	}
+}
+
+func TestParseConfigAddsImagePathsToKnownKinds(t *testing.T) {
+	files := fs.MakeFsInMemory()
+	if err := files.WriteFile("manifests/deployment.yaml", []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: taxi
+spec:
+  template:
+    metadata:
+      annotations:
+        sidecar-image: quay.io/example/sidecar:v1
+    spec:
+      containers:
+      - name: taxi
+        image: quay.io/example/taxi:v1.0.0
+`)); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := NewImagePaths(ImagePath{
+		Group:   "apps",
+		Version: "v1",
+		Kind:    "Deployment",
+		Paths:   []string{"spec.template.metadata.annotations.sidecar-image", "spec.template.spec.containers[*].image"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := New(paths).parseConfig("manifests", nil, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Image{
+		{
+			Reference: "quay.io/example/taxi:v1.0.0", Registry: "quay.io", Repository: "example/taxi", Tag: "v1.0.0",
+			Container: "taxi", ContainerType: RegularContainer,
+		},
+		{
+			Reference: "quay.io/example/sidecar:v1", Registry: "quay.io", Repository: "example/sidecar", Tag: "v1",
+		},
+	}
+	if diff := cmp.Diff(want, res[0].Images); diff != "" {
+		t.Fatalf("failed to extract images:\n%s", diff)
+	}
+}
+
+func TestParseConfigWithImagePathsConcurrently(t *testing.T) {
+	paths, err := LoadImagePaths("testdata/image_paths.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(paths)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := p.parseConfig("testdata/custom", nil, fs.MakeFsOnDisk())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if len(res[0].Images) != 4 {
+				t.Errorf("got images %v, want 4", res[0].Images)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestLoadImagePathsWithInvalidPaths(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		content string
+	}{
+		{"missing version", "- kind: Workload\n  paths: [spec.image]\n"},
+		{"missing kind", "- version: v1\n  paths: [spec.image]\n"},
+		{"missing paths", "- version: v1\n  kind: Workload\n"},
+		{"invalid path", "- version: v1\n  kind: Workload\n  paths: ['spec.containers[*.image']\n"},
+		{"unknown field", "- version: v1\n  kind: Workload\n  path: spec.image\n"},
+		{"range", "- version: v1\n  kind: Workload\n  paths: ['{range .spec.containers[*]}{.image}{end}']\n"},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "image_paths.yaml")
+			if err := os.WriteFile(filename, []byte(tt.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadImagePaths(filename); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestJSONPathExpression(t *testing.T) {
+	expressionTests := []struct {
+		path string
+		want string
+	}{
+		{"spec.template.spec.containers[*].image", "{.spec.template.spec.containers[*].image}"},
+		{".spec.image", "{.spec.image}"},
+		{"{.spec.image}", "{.spec.image}"},
+	}
+
+	for _, tt := range expressionTests {
+		if got := jsonPathExpression(tt.path); got != tt.want {
+			t.Errorf("jsonPathExpression(%q) got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testDigest = "sha256:4b1e4f5d5b2c1a0a8b3e3b4a5f6c7d8e9f0a1b2c3d4e5f6a7b8c9d0e1f2a3b4c"
+
+func TestParseImage(t *testing.T) {
+	parseTests := []struct {
+		ref  string
+		want Image
+	}{
+		{
+			"redis:6-alpine",
+			Image{Reference: "redis:6-alpine", Registry: "docker.io", Repository: "library/redis", Tag: "6-alpine"},
+		},
+		{
+			"docker.io/library/redis:6-alpine",
+			Image{Reference: "docker.io/library/redis:6-alpine", Registry: "docker.io", Repository: "library/redis", Tag: "6-alpine"},
+		},
+		{
+			"bigkevmcd/go-demo",
+			Image{Reference: "bigkevmcd/go-demo", Registry: "docker.io", Repository: "bigkevmcd/go-demo", Tag: "latest"},
+		},
+		{
+			"quay.io/example/taxi:v1.0.0",
+			Image{Reference: "quay.io/example/taxi:v1.0.0", Registry: "quay.io", Repository: "example/taxi", Tag: "v1.0.0"},
+		},
+		{
+			"localhost:5000/taxi@" + testDigest,
+			Image{Reference: "localhost:5000/taxi@" + testDigest, Registry: "localhost:5000", Repository: "taxi", Digest: testDigest},
+		},
+		{
+			"quay.io/example/taxi:v1.0.0@" + testDigest,
+			Image{Reference: "quay.io/example/taxi:v1.0.0@" + testDigest, Registry: "quay.io", Repository: "example/taxi", Tag: "v1.0.0", Digest: testDigest},
+		},
+	}
+
+	for _, tt := range parseTests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseImage(tt.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Fatalf("ParseImage(%q) failed:\n%s", tt.ref, diff)
+			}
+		})
+	}
+}
+
+func TestParseImageWithInvalidReference(t *testing.T) {
+	for _, ref := range []string{"$(params.image)", "Example/Taxi", "taxi:"} {
+		got, err := ParseImage(ref)
+		if err == nil {
+			t.Errorf("ParseImage(%q) expected an error", ref)
+		}
+		if diff := cmp.Diff(Image{Reference: ref}, got); diff != "" {
+			t.Errorf("ParseImage(%q) failed:\n%s", ref, diff)
+		}
+		if n := got.Normalized(); n != ref {
+			t.Errorf("Normalized() got %q, want %q", n, ref)
+		}
+	}
+}
+
+func TestImageNormalized(t *testing.T) {
+	normalizedTests := []struct {
+		refs []string
+		want string
+	}{
+		{[]string{"redis:6-alpine", "library/redis:6-alpine", "docker.io/library/redis:6-alpine"}, "docker.io/library/redis:6-alpine"},
+		{[]string{"alpine", "alpine:latest", "docker.io/library/alpine:latest"}, "docker.io/library/alpine:latest"},
+		{[]string{"quay.io/example/taxi@" + testDigest}, "quay.io/example/taxi@" + testDigest},
+	}
+
+	for _, tt := range normalizedTests {
+		for _, ref := range tt.refs {
+			i, err := ParseImage(ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := i.Normalized(); n != tt.want {
+				t.Errorf("Normalized() for %q got %q, want %q", ref, n, tt.want)
+			}
+		}
+	}
+}
@@ -5,5 +5,34 @@ import (
 )
 
 // ResourceParser implementations should fetch the source using the CloneOptions and
-// parse the resources in the path into a set of resource.Resource values.
-type ResourceParser func(path string, opts *git.CloneOptions) ([]*Resource, error)
+// parse the resources in the path at the revision into a set of
+// resource.Resource values.
+//
+// The path is read with the source options, which can be nil.
+type ResourceParser func(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error)
+
+// Source configures how the resources in a path are read, with the same
+// options as the source of an Argo CD Application.
+type Source struct {
+	// Directory configures how the path is read, if it's a directory of
+	// manifests.
+	Directory *Directory `json:"directory,omitempty"`
+	// Helm configures how the path is rendered, if it's a Helm chart.
+	Helm *Helm `json:"helm,omitempty"`
+}
+
+// directory returns the directory options, or nil if there are none.
+func (s *Source) directory() *Directory {
+	if s == nil {
+		return nil
+	}
+	return s.Directory
+}
+
+// helm returns the Helm options, or nil if there are none.
+func (s *Source) helm() *Helm {
+	if s == nil {
+		return nil
+	}
+	return s.Helm
+}
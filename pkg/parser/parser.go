@@ -1,9 +1,15 @@
 package parser
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
 	"github.com/go-git/go-git/v5"
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"sigs.k8s.io/kustomize/api/krusty"
 	"sigs.k8s.io/kustomize/api/resource"
 	fs "sigs.k8s.io/kustomize/kyaml/filesys"
@@ -11,28 +17,82 @@ import (
 	"github.com/redhat-developer/gitops-backend/pkg/gitfs"
 )
 
-// ParseFromGit takes a go-git CloneOptions struct and a filepath, and extracts
-// the service configuration from there.
-func ParseFromGit(path string, opts *git.CloneOptions) ([]*Resource, error) {
-	gfs, err := gitfs.NewInMemoryFromOptions(opts)
+// Parser parses the resources in a path, extracting the images from the
+// kinds that are known, and from the kinds with image paths.
+type Parser struct {
+	imagePaths *ImagePaths
+}
+
+// New creates and returns a Parser that extracts images from the kinds with
+// image paths, in addition to the kinds that are known, the paths can be nil.
+func New(paths *ImagePaths) *Parser {
+	return &Parser{imagePaths: paths}
+}
+
+// ParseFromGit takes a go-git CloneOptions struct, a revision and a filepath,
+// and extracts the service configuration from there.
+//
+// The revision can be a branch, tag or commit SHA, or empty for the default
+// branch.
+//
+// The directory options are used if the path is a directory of manifests,
+// and can be nil.
+func ParseFromGit(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error) {
+	return New(nil).ParseFromGit(path, revision, opts, src)
+}
+
+// ParseFromSparseFetch is like ParseFromGit, but fetches only the files that
+// are read, without the history, rather than cloning the repository.
+func ParseFromSparseFetch(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error) {
+	return New(nil).ParseFromSparseFetch(path, revision, opts, src)
+}
+
+// ParseFromCache returns a ResourceParser that reads the source from clones in
+// the cache, rather than cloning the repository for each parse.
+func ParseFromCache(c *gitfs.RepositoryCache) ResourceParser {
+	return New(nil).ParseFromCache(c)
+}
+
+// ParseFromGit is like the ParseFromGit function, but extracts the images
+// with the image paths of the parser.
+func (p *Parser) ParseFromGit(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error) {
+	gfs, err := gitfs.NewInMemoryFromOptions(opts, revision)
 	if err != nil {
 		return nil, err
 	}
-	return parseConfig(path, gfs)
+	return p.parseConfig(path, src, gfs)
 }
 
-func parseConfig(path string, files fs.FileSystem) ([]*Resource, error) {
+// ParseFromSparseFetch is like the ParseFromSparseFetch function, but
+// extracts the images with the image paths of the parser.
+func (p *Parser) ParseFromSparseFetch(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error) {
+	gfs, err := gitfs.NewSparseFromOptions(opts, revision)
+	if err != nil {
+		return nil, err
+	}
+	return p.parseConfig(path, src, gfs)
+}
 
-	// Run performs a kustomization.
-	// It reads given path from the given file system, interprets it as
-	// a kustomization.yaml file, perform the kustomization it represents,
-	// and return the resulting resources.
-	kt := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
-	r, err := kt.Run(files, path)
+// ParseFromCache is like the ParseFromCache function, but extracts the images
+// with the image paths of the parser.
+func (p *Parser) ParseFromCache(c *gitfs.RepositoryCache) ResourceParser {
+	return func(path, revision string, opts *git.CloneOptions, src *Source) ([]*Resource, error) {
+		var resources []*Resource
+		err := c.Read(opts, revision, func(files fs.FileSystem) error {
+			var err error
+			resources, err = p.parseConfig(path, src, files)
+			return err
+		})
+		return resources, err
+	}
+}
+
+func (p *Parser) parseConfig(path string, src *Source, files fs.FileSystem) ([]*Resource, error) {
+	objs, err := render(path, src, files)
 	if err != nil {
 		return nil, err
 	}
-	if len(r.Resources()) == 0 {
+	if len(objs) == 0 {
 		return nil, nil
 	}
 
@@ -41,18 +101,69 @@ func parseConfig(path string, files fs.FileSystem) ([]*Resource, error) {
 		return nil, err
 	}
 	resources := []*Resource{}
-	for _, v := range r.Resources() {
-		resources = append(resources, extractResource(conv, v))
+	for _, v := range objs {
+		resources = append(resources, extractResource(conv, p.imagePaths, v))
 	}
 	return resources, nil
 }
 
-// convert the Kustomize Resource into an internal representation, extracting
-// the images if possible.
+// render returns the resources in the path, which is a Helm chart if it has
+// a Chart.yaml, a kustomization if it has a kustomization.yaml, and a
+// directory of manifests otherwise.
+func render(path string, src *Source, files fs.FileSystem) ([]*unstructured.Unstructured, error) {
+	if isChart(files, path) {
+		return renderChart(files, path, src.helm())
+	}
+	if !isKustomization(files, path) {
+		return readDirectory(files, path, src.directory())
+	}
+
+	// Run performs a kustomization.
+	// It reads given path from the given file system, interprets it as
+	// a kustomization.yaml file, perform the kustomization it represents,
+	// and return the resulting resources.
+	kt := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	r, err := kt.Run(files, path)
+	if err != nil {
+		return nil, err
+	}
+	objs := []*unstructured.Unstructured{}
+	for _, v := range r.Resources() {
+		objs = append(objs, convert(v))
+	}
+	return objs, nil
+}
+
+// decodeManifests decodes the YAML or JSON documents in a file into
+// resources, empty documents are skipped.
+func decodeManifests(name string, b []byte) ([]*unstructured.Unstructured, error) {
+	var objs []*unstructured.Unstructured
+	d := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(b), 4096)
+	for {
+		obj := map[string]interface{}{}
+		if err := d.Decode(&obj); err != nil {
+			if errors.Is(err, io.EOF) {
+				return objs, nil
+			}
+			return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		u := &unstructured.Unstructured{Object: obj}
+		if u.GetKind() == "" || u.GetAPIVersion() == "" {
+			return nil, fmt.Errorf("failed to decode %s: a resource has no apiVersion or kind", name)
+		}
+		objs = append(objs, u)
+	}
+}
+
+// convert the resource into an internal representation, extracting the images
+// if possible.
 //
-// If this is an unknown type (to the converter) no images will be extracted.
-func extractResource(conv *unstructuredConverter, res *resource.Resource) *Resource {
-	c := convert(res)
+// The images at the image paths for its kind, if there are any, are added to
+// the images that are extracted from the types that the converter knows.
+func extractResource(conv *unstructuredConverter, paths *ImagePaths, c *unstructured.Unstructured) *Resource {
 	g := c.GroupVersionKind()
 	r := &Resource{
 		Name:      c.GetName(),
@@ -62,11 +173,10 @@ func extractResource(conv *unstructuredConverter, res *resource.Resource) *Resou
 		Kind:      g.Kind,
 		Labels:    c.GetLabels(),
 	}
-	t, err := conv.fromUnstructured(c)
-	if err != nil {
-		return r
+	if t, err := conv.fromUnstructured(c); err == nil {
+		r.Images = extractImages(t)
 	}
-	r.Images = extractImages(t)
+	r.Images = paths.appendImages(r.Images, c)
 	return r
 }
 
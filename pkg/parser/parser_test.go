@@ -8,6 +8,7 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/google/go-cmp/cmp"
 
+	"github.com/redhat-developer/gitops-backend/pkg/gitfs"
 	"github.com/redhat-developer/gitops-backend/test"
 )
 
@@ -18,11 +19,11 @@ const (
 
 func TestParseNoFile(t *testing.T) {
 	res, err := ParseFromGit(
-		"testdata",
+		"testdata", "",
 		&git.CloneOptions{
 			URL:   "../..",
 			Depth: 1,
-		})
+		}, nil)
 
 	if res != nil {
 		t.Errorf("did not expect to parse resources: %#v", res)
@@ -38,8 +39,8 @@ func resKey(r *Resource) string {
 
 func TestParseFromGit(t *testing.T) {
 	res, err := ParseFromGit(
-		"pkg/parser/testdata/go-demo",
-		test.MakeCloneOptions())
+		"pkg/parser/testdata/go-demo", "",
+		test.MakeCloneOptions(), nil)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,7 +53,12 @@ func TestParseFromGit(t *testing.T) {
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: []Image{
+				{
+					Reference: "bigkevmcd/go-demo:876ecb3", Registry: "docker.io", Repository: "bigkevmcd/go-demo", Tag: "876ecb3",
+					Container: "http", ContainerType: RegularContainer,
+				},
+			},
 		},
 		{
 			Version: "v1", Kind: "Service", Name: "go-demo-http",
@@ -80,7 +86,12 @@ func TestParseFromGit(t *testing.T) {
 				nameLabel:   "redis",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"redis:6-alpine"},
+			Images: []Image{
+				{
+					Reference: "redis:6-alpine", Registry: "docker.io", Repository: "library/redis", Tag: "6-alpine",
+					Container: "redis", ContainerType: RegularContainer,
+				},
+			},
 		},
 		{
 			Group:   "apps",
@@ -91,7 +102,12 @@ func TestParseFromGit(t *testing.T) {
 				partOfLabel: "go-demo",
 				nameLabel:   "go-demo",
 			},
-			Images: []string{"bigkevmcd/go-demo-api:v0.0.1"},
+			Images: []Image{
+				{
+					Reference: "bigkevmcd/go-demo-api:v0.0.1", Registry: "docker.io", Repository: "bigkevmcd/go-demo-api", Tag: "v0.0.1",
+					Container: "go-demo", ContainerType: RegularContainer,
+				},
+			},
 		},
 		{
 			Group:   "batch",
@@ -102,7 +118,12 @@ func TestParseFromGit(t *testing.T) {
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo",
 			},
-			Images: []string{"bigkevmcd/go-demo:876ecb3"},
+			Images: []Image{
+				{
+					Reference: "bigkevmcd/go-demo:876ecb3", Registry: "docker.io", Repository: "bigkevmcd/go-demo", Tag: "876ecb3",
+					Container: "demo-job-executor", ContainerType: RegularContainer,
+				},
+			},
 		},
 		{
 			Group:   "batch",
@@ -112,7 +133,12 @@ func TestParseFromGit(t *testing.T) {
 			Labels: map[string]string{
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo"},
-			Images: []string{"alpine:latest"},
+			Images: []Image{
+				{
+					Reference: "alpine:latest", Registry: "docker.io", Repository: "library/alpine", Tag: "latest",
+					Container: "hello", ContainerType: RegularContainer,
+				},
+			},
 		},
 		{
 			Version: "v1",
@@ -122,7 +148,12 @@ func TestParseFromGit(t *testing.T) {
 			Labels: map[string]string{
 				nameLabel:   "go-demo",
 				partOfLabel: "go-demo"},
-			Images: []string{"demo/demo-config:v5"},
+			Images: []Image{
+				{
+					Reference: "demo/demo-config:v5", Registry: "docker.io", Repository: "demo/demo-config", Tag: "v5",
+					Container: "demo-service", ContainerType: RegularContainer,
+				},
+			},
 		},
 	}
 	sort.SliceStable(want, func(i, j int) bool { return resKey(want[i]) < resKey(want[j]) })
@@ -135,3 +166,31 @@ func assertCmp(t *testing.T, want, got interface{}, msg string) {
 		t.Fatalf(msg+":\n%s", diff)
 	}
 }
+
+func TestParseFromSparseFetch(t *testing.T) {
+	res, err := ParseFromSparseFetch("pkg/parser/testdata/go-demo", "", test.MakeCloneOptions(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) == 0 {
+		t.Fatal("failed to parse any resources")
+	}
+}
+
+func TestParseFromCache(t *testing.T) {
+	c := gitfs.NewRepositoryCache(1, 0)
+	parse := ParseFromCache(c)
+
+	for i := 0; i < 2; i++ {
+		res, err := parse("pkg/parser/testdata/go-demo", "", test.MakeCloneOptions(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) == 0 {
+			t.Fatal("failed to parse any resources")
+		}
+	}
+	if c.Len() != 1 {
+		t.Fatalf("got %d cached repositories, want 1", c.Len())
+	}
+}
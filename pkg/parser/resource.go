@@ -8,5 +8,5 @@ type Resource struct {
 	Name      string            `json:"name"`
 	Namespace string            `json:"namespace"`
 	Labels    map[string]string `json:"-"`
-	Images    []string          `json:"-"`
+	Images    []Image           `json:"images,omitempty"`
 }
@@ -0,0 +1,4 @@
+{{- define "taxi.labels" -}}
+app.kubernetes.io/name: {{ .Chart.Name }}
+app.kubernetes.io/part-of: {{ .Release.Name }}
+{{- end }}
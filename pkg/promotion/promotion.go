@@ -0,0 +1,168 @@
+package promotion
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/api/types"
+	kyaml "sigs.k8s.io/kustomize/kyaml/yaml"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+	"github.com/redhat-developer/gitops-backend/pkg/kustomization"
+)
+
+// Change is an updated kustomization.yaml in the target environment.
+type Change struct {
+	Path    string
+	Content []byte
+	Images  []ImageChange
+}
+
+// ImageChange is an image that is changed by a promotion, From is empty if
+// the image wasn't set in the target environment.
+type ImageChange struct {
+	Name string
+	From string
+	To   string
+}
+
+// Changes compares the kustomizations of an application in two environments
+// and returns the changes to the target environment's kustomizations that
+// promote the images from the source environment.
+//
+// The kustomization.yaml files are found by following the resources,
+// components and bases in the application's kustomization.yaml, only those
+// within the application's path are promoted. Each kustomization in the
+// source is compared with the kustomization at the same path in the target,
+// kustomizations that aren't in both environments are not changed.
+//
+// The images in the source replace the images with the same name in the
+// target, images that are only in the target are kept.
+func Changes(ctx context.Context, client git.SCM, repo, ref, fromPath, toPath string) ([]Change, error) {
+	fromPath = path.Clean(fromPath)
+	dirs, err := kustomization.Dirs(ctx, client, repo, ref, fromPath)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	for _, dir := range dirs {
+		if !kustomization.Within(dir, fromPath) {
+			continue
+		}
+		from, err := client.FileContents(ctx, repo, path.Join(dir, kustomization.File), ref)
+		if err != nil {
+			return nil, err
+		}
+		toFile := path.Join(toPath, strings.TrimPrefix(dir, fromPath), kustomization.File)
+		to, err := client.FileContents(ctx, repo, toFile, ref)
+		if git.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, images, err := promoteImages(from, to)
+		if err != nil {
+			return nil, fmt.Errorf("failed to promote the images in %s: %w", toFile, err)
+		}
+		if len(images) > 0 {
+			changes = append(changes, Change{Path: toFile, Content: content, Images: images})
+		}
+	}
+	return changes, nil
+}
+
+// promoteImages sets the images from the source kustomization in the target
+// kustomization, and returns the updated target with the images that were
+// changed.
+//
+// The target is edited in place, so the formatting and comments in the rest
+// of the file are kept.
+func promoteImages(from, to []byte) ([]byte, []ImageChange, error) {
+	source, err := kustomization.Parse(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	target, err := kustomization.Parse(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	current := map[string]types.Image{}
+	for _, image := range target.Images {
+		current[image.Name] = image
+	}
+
+	node, err := kyaml.Parse(string(to))
+	if err != nil {
+		return nil, nil, err
+	}
+	var changes []ImageChange
+	for _, image := range source.Images {
+		previous, ok := current[image.Name]
+		if ok && previous == image {
+			continue
+		}
+		if err := setImage(node, image); err != nil {
+			return nil, nil, err
+		}
+		change := ImageChange{Name: image.Name, To: kustomization.ImageReference(image)}
+		if ok {
+			change.From = kustomization.ImageReference(previous)
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return to, nil, nil
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	s, err := node.String()
+	if err != nil {
+		return nil, nil, err
+	}
+	return []byte(s), changes, nil
+}
+
+// setImage updates the fields of the image with the same name in the
+// kustomization, or appends the image if there isn't one.
+//
+// The fields are updated individually, so comments on the image are kept.
+func setImage(node *kyaml.RNode, image types.Image) error {
+	images, err := node.Pipe(kyaml.LookupCreate(kyaml.SequenceNode, "images"))
+	if err != nil {
+		return err
+	}
+	element, err := images.Pipe(kyaml.MatchElement("name", image.Name))
+	if err != nil {
+		return err
+	}
+	if element == nil {
+		element = kyaml.NewMapRNode(&map[string]string{"name": image.Name})
+		if err := images.PipeE(kyaml.Append(element.YNode())); err != nil {
+			return err
+		}
+	}
+	for _, field := range []struct{ name, value string }{
+		{"newName", image.NewName},
+		{"newTag", image.NewTag},
+		{"tagSuffix", image.TagSuffix},
+		{"digest", image.Digest},
+	} {
+		if field.value == "" {
+			if _, err := element.Pipe(kyaml.Clear(field.name)); err != nil {
+				return err
+			}
+			continue
+		}
+		if existing := element.Field(field.name); existing != nil {
+			existing.Value.YNode().Value = field.value
+			continue
+		}
+		if err := element.PipeE(kyaml.SetField(field.name, kyaml.NewScalarRNode(field.value))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
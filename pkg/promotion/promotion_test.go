@@ -0,0 +1,124 @@
+package promotion
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/redhat-developer/gitops-backend/pkg/git"
+)
+
+const testRepo = "example/gitops"
+
+func TestChanges(t *testing.T) {
+	client := newStubSCM()
+	client.addFile(t, "environments/dev/apps/taxi/kustomization.yaml", "testdata/dev-kustomization.yaml")
+	client.addFile(t, "environments/dev/apps/taxi/base/kustomization.yaml", "testdata/base-kustomization.yaml")
+	client.addFile(t, "environments/dev/apps/taxi/overlays/config/kustomization.yaml", "testdata/dev-config-kustomization.yaml")
+	client.addFile(t, "environments/dev/apps/shared/kustomization.yaml", "testdata/dev-kustomization.yaml")
+	client.addFile(t, "environments/stage/apps/taxi/kustomization.yaml", "testdata/stage-kustomization.yaml")
+	client.addFile(t, "environments/stage/apps/taxi/overlays/config/kustomization.yaml", "testdata/stage-config-kustomization.yaml")
+
+	changes, err := Changes(context.TODO(), client, testRepo, "main", "environments/dev/apps/taxi", "environments/stage/apps/taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Change{
+		{
+			Path:    "environments/stage/apps/taxi/kustomization.yaml",
+			Content: readFile(t, "testdata/want-stage-kustomization.yaml"),
+			Images: []ImageChange{
+				{Name: "quay.io/example/bus", To: "quay.io/example/bus-service:v3"},
+				{Name: "quay.io/example/taxi", From: "quay.io/example/taxi:v1", To: "quay.io/example/taxi:v2"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Fatalf("incorrect changes:\n%s", diff)
+	}
+	if p := "environments/stage/apps/shared/kustomization.yaml"; client.requested[p] {
+		t.Errorf("%s was requested, want it skipped as it is outside the application", p)
+	}
+}
+
+func TestChangesWithNoDifferences(t *testing.T) {
+	client := newStubSCM()
+	client.addFile(t, "environments/dev/apps/taxi/kustomization.yaml", "testdata/dev-config-kustomization.yaml")
+	client.addFile(t, "environments/stage/apps/taxi/kustomization.yaml", "testdata/stage-config-kustomization.yaml")
+
+	changes, err := Changes(context.TODO(), client, testRepo, "main", "environments/dev/apps/taxi", "environments/stage/apps/taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("got %d changes, want none", len(changes))
+	}
+}
+
+func TestChangesWithMissingTarget(t *testing.T) {
+	client := newStubSCM()
+	client.addFile(t, "environments/dev/apps/taxi/kustomization.yaml", "testdata/dev-kustomization.yaml")
+
+	changes, err := Changes(context.TODO(), client, testRepo, "main", "environments/dev/apps/taxi", "environments/stage/apps/taxi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("got %d changes, want none", len(changes))
+	}
+}
+
+func TestPromoteImagesClearsUnsetFields(t *testing.T) {
+	from := []byte("images:\n- name: quay.io/example/taxi\n  digest: sha256:abc\n")
+	to := []byte("images:\n- name: quay.io/example/taxi\n  newTag: v1\n")
+
+	content, changes, err := promoteImages(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("images:\n- name: quay.io/example/taxi\n  digest: sha256:abc\n", string(content)); diff != "" {
+		t.Fatalf("incorrect content:\n%s", diff)
+	}
+	want := []ImageChange{{Name: "quay.io/example/taxi", From: "quay.io/example/taxi:v1", To: "quay.io/example/taxi@sha256:abc"}}
+	if diff := cmp.Diff(want, changes); diff != "" {
+		t.Fatalf("incorrect changes:\n%s", diff)
+	}
+}
+
+func readFile(t *testing.T, filename string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// stubSCM serves files from the main branch of the test repo.
+type stubSCM struct {
+	git.SCM
+	files     map[string][]byte
+	requested map[string]bool
+}
+
+func newStubSCM() *stubSCM {
+	return &stubSCM{files: map[string][]byte{}, requested: map[string]bool{}}
+}
+
+func (s *stubSCM) addFile(t *testing.T, p, filename string) {
+	s.files[path.Join(testRepo, p)] = readFile(t, filename)
+}
+
+func (s *stubSCM) FileContents(ctx context.Context, repo, p, ref string) ([]byte, error) {
+	s.requested[p] = true
+	if b, ok := s.files[path.Join(repo, p)]; ok && ref == "main" {
+		return b, nil
+	}
+	return nil, git.SCMError{Status: http.StatusNotFound}
+}
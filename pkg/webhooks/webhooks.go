@@ -0,0 +1,234 @@
+// Package webhooks receives push webhooks from Git hosts, so that the data
+// cached for the repositories that were pushed to can be refreshed.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jenkins-x/go-scm/scm"
+	"github.com/jenkins-x/go-scm/scm/driver/bitbucket"
+	"github.com/jenkins-x/go-scm/scm/driver/github"
+	"github.com/jenkins-x/go-scm/scm/driver/gitlab"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	// maxPushCommits is the number of commits that GitHub and GitLab include
+	// in a push event, if there are more, the files that were changed aren't
+	// known.
+	maxPushCommits = 20
+
+	// maxPayloadSize is the largest webhook body that is read, which is the
+	// same limit as go-scm.
+	maxPayloadSize = 10000000
+)
+
+// Push is a push to a branch or tag in a repository.
+type Push struct {
+	// Host is the host of the repository, e.g. github.com.
+	Host string
+	// Repo is the full name of the repository, e.g. org/repo.
+	Repo string
+	// Ref is the ref that was pushed to, e.g. refs/heads/main.
+	Ref string
+	// DefaultBranch is the default branch of the repository, this is empty
+	// if the Git host doesn't report it.
+	DefaultBranch string
+	// SHA is the commit that the ref points to after the push, this is empty
+	// if the ref was deleted.
+	SHA string
+	// Files are the files that were added, modified or removed by the push,
+	// this is nil if the Git host doesn't report all of the files.
+	Files []string
+}
+
+// Updates returns true if the push changed the commit that a branch, tag or
+// commit SHA refers to.
+//
+// HEAD is updated by a push to the default branch, or to any branch if the
+// default branch isn't known.
+func (p *Push) Updates(ref string) bool {
+	switch ref {
+	case p.Ref, strings.TrimPrefix(p.Ref, "refs/heads/"), strings.TrimPrefix(p.Ref, "refs/tags/"):
+		return true
+	case "HEAD":
+		branch := strings.TrimPrefix(p.Ref, "refs/heads/")
+		return branch != p.Ref && (p.DefaultBranch == "" || p.DefaultBranch == branch)
+	}
+	return false
+}
+
+// Changed returns true if the push changed any files in the directory, or if
+// the files that were changed aren't known.
+func (p *Push) Changed(dir string) bool {
+	if p.Files == nil {
+		return true
+	}
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	for _, f := range p.Files {
+		if strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Listener is notified of pushes, it must not block.
+type Listener interface {
+	Pushed(p *Push)
+}
+
+// ListenerFunc is a function that is a Listener.
+type ListenerFunc func(p *Push)
+
+// Pushed implements the Listener interface.
+func (f ListenerFunc) Pushed(p *Push) {
+	f(p)
+}
+
+// Handler receives push webhooks from GitHub, GitLab and Bitbucket at
+// /webhooks/github, /webhooks/gitlab and /webhooks/bitbucket, and notifies
+// the listeners of pushes.
+//
+// Webhooks are authenticated with a secret that is shared with the Git host,
+// GitHub signs the body with the secret, GitLab sends it as a token in the
+// X-Gitlab-Token header, and Bitbucket signs the body with the secret, or
+// for webhooks without a secret, must be configured to send it in the secret
+// query parameter, which puts it in the logs of any proxies.
+type Handler struct {
+	*httprouter.Router
+	secret    string
+	listeners []Listener
+}
+
+// NewHandler creates and returns a new Handler, the secret must not be empty.
+func NewHandler(secret string, listeners ...Listener) *Handler {
+	h := &Handler{Router: httprouter.New(), secret: secret, listeners: listeners}
+	h.HandlerFunc(http.MethodPost, "/webhooks/github", h.handler(github.NewWebHookService(), secret))
+	h.HandlerFunc(http.MethodPost, "/webhooks/gitlab", h.handler(gitlab.NewWebHookService(), secret))
+	// go-scm compares the secret in the query parameter in variable time,
+	// and doesn't verify signatures, so Bitbucket webhooks are verified
+	// before they're parsed.
+	h.HandlerFunc(http.MethodPost, "/webhooks/bitbucket", h.verifyBitbucket(h.handler(bitbucket.NewWebHookService(), "")))
+	return h
+}
+
+// Paths returns the paths that webhooks are received at.
+func Paths() []string {
+	return []string{"/webhooks/bitbucket", "/webhooks/github", "/webhooks/gitlab"}
+}
+
+// handler parses webhooks with the service, which verifies them with the
+// secret, an empty secret isn't verified.
+func (h *Handler) handler(s scm.WebhookService, secret string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		hook, err := s.Parse(r, func(scm.Webhook) (string, error) {
+			return secret, nil
+		})
+		if errors.Is(err, scm.ErrSignatureInvalid) {
+			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+		var unknown scm.UnknownWebhook
+		if errors.As(err, &unknown) || (err == nil && hook == nil) {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err != nil {
+			log.Printf("ERROR: failed to parse webhook: %s", err)
+			http.Error(w, "Invalid webhook", http.StatusBadRequest)
+			return
+		}
+		push, ok := hook.(*scm.PushHook)
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		p, err := pushFromHook(push)
+		if err != nil {
+			log.Printf("ERROR: failed to parse push webhook: %s", err)
+			http.Error(w, "Invalid webhook", http.StatusBadRequest)
+			return
+		}
+		log.Printf("received a push to %s in %s/%s", p.Ref, p.Host, p.Repo)
+		for _, l := range h.listeners {
+			l.Pushed(p)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// verifyBitbucket verifies the X-Hub-Signature of a webhook, the HMAC-SHA256
+// of the body with the secret, or the secret query parameter if the webhook
+// isn't signed.
+func (h *Handler) verifyBitbucket(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxPayloadSize))
+		if err != nil {
+			log.Printf("ERROR: failed to read webhook: %s", err)
+			http.Error(w, "Invalid webhook", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		if !validBitbucketSecret(r, body, h.secret) {
+			http.Error(w, "Invalid webhook signature", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func validBitbucketSecret(r *http.Request, body []byte, secret string) bool {
+	if signature := r.Header.Get("X-Hub-Signature"); signature != "" {
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body)
+		return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+	}
+	return subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("secret")), []byte(secret)) == 1
+}
+
+func pushFromHook(h *scm.PushHook) (*Push, error) {
+	link := h.Repo.Clone
+	if link == "" {
+		link = h.Repo.Link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Host == "" || h.Repo.FullName == "" {
+		return nil, errors.New("push has no repository")
+	}
+	p := &Push{
+		Host:          parsed.Host,
+		Repo:          h.Repo.FullName,
+		Ref:           h.Ref,
+		DefaultBranch: h.Repo.Branch,
+	}
+	if !h.Deleted && strings.Trim(h.After, "0") != "" {
+		p.SHA = h.After
+	}
+	if len(h.Commits) > 0 && len(h.Commits) < maxPushCommits {
+		files := []string{}
+		for _, c := range h.Commits {
+			files = append(files, c.Added...)
+			files = append(files, c.Modified...)
+			files = append(files, c.Removed...)
+		}
+		// Bitbucket doesn't report the files.
+		if len(files) > 0 {
+			p.Files = files
+		}
+	}
+	return p, nil
+}
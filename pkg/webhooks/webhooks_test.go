@@ -0,0 +1,241 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const testSecret = "test-secret"
+
+func TestHandler(t *testing.T) {
+	handlerTests := []struct {
+		name    string
+		path    string
+		payload string
+		headers map[string]string
+		want    *Push
+	}{
+		{
+			"GitHub",
+			"/webhooks/github",
+			"testdata/github_push.json",
+			map[string]string{"X-GitHub-Event": "push", "X-GitHub-Delivery": "test-delivery"},
+			&Push{
+				Host:          "github.com",
+				Repo:          "Codertocat/Hello-World",
+				Ref:           "refs/heads/master",
+				DefaultBranch: "master",
+				SHA:           "199eddf46df50de8d02e99bf1c5fdb4101338224",
+				Files:         []string{"environments/dev/apps/taxi/kustomization.yaml"},
+			},
+		},
+		{
+			"GitLab",
+			"/webhooks/gitlab",
+			"testdata/gitlab_push.json",
+			map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": testSecret},
+			&Push{
+				Host:          "gitlab.com",
+				Repo:          "gitlab-org/hello-world",
+				Ref:           "refs/heads/master",
+				DefaultBranch: "master",
+				SHA:           "2adc9465c4edfc33834e173fe89436a7cb899a1d",
+				Files:         []string{"README.md"},
+			},
+		},
+		{
+			"Bitbucket",
+			"/webhooks/bitbucket",
+			"testdata/bitbucket_push.json",
+			map[string]string{"X-Event-Key": "repo:push"},
+			&Push{
+				Host: "bitbucket.org",
+				Repo: "brydzewski/foo",
+				Ref:  "refs/heads/master",
+				SHA:  "141977fedf5cf35aa290ac87d4b5177ac4cd9de1",
+			},
+		},
+		{
+			"Bitbucket with the secret in the query",
+			"/webhooks/bitbucket?secret=" + testSecret,
+			"testdata/bitbucket_push.json",
+			map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": ""},
+			&Push{
+				Host: "bitbucket.org",
+				Repo: "brydzewski/foo",
+				Ref:  "refs/heads/master",
+				SHA:  "141977fedf5cf35aa290ac87d4b5177ac4cd9de1",
+			},
+		},
+	}
+
+	for _, tt := range handlerTests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &recordingListener{}
+			h := NewHandler(testSecret, l)
+			req := makeWebhookRequest(t, tt.path, tt.payload, tt.headers)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != http.StatusAccepted {
+				t.Fatalf("got status %d, want %d: %s", w.Code, http.StatusAccepted, w.Body)
+			}
+			if diff := cmp.Diff([]*Push{tt.want}, l.pushes); diff != "" {
+				t.Fatalf("incorrect pushes:\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestHandlerWithInvalidSecrets(t *testing.T) {
+	invalidTests := []struct {
+		name    string
+		path    string
+		payload string
+		headers map[string]string
+	}{
+		{
+			"GitHub without a signature",
+			"/webhooks/github",
+			"testdata/github_push.json",
+			map[string]string{"X-GitHub-Event": "push", "X-GitHub-Delivery": "test-delivery", "X-Hub-Signature": ""},
+		},
+		{
+			"GitHub with an invalid signature",
+			"/webhooks/github",
+			"testdata/github_push.json",
+			map[string]string{"X-GitHub-Event": "push", "X-GitHub-Delivery": "test-delivery", "X-Hub-Signature": "sha256=1234"},
+		},
+		{
+			"GitLab with an invalid token",
+			"/webhooks/gitlab",
+			"testdata/gitlab_push.json",
+			map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": "unknown"},
+		},
+		{
+			"Bitbucket without a secret",
+			"/webhooks/bitbucket",
+			"testdata/bitbucket_push.json",
+			map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": ""},
+		},
+		{
+			"Bitbucket with an invalid secret",
+			"/webhooks/bitbucket?secret=unknown",
+			"testdata/bitbucket_push.json",
+			map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": ""},
+		},
+		{
+			"Bitbucket with an invalid signature",
+			"/webhooks/bitbucket?secret=" + testSecret,
+			"testdata/bitbucket_push.json",
+			map[string]string{"X-Event-Key": "repo:push", "X-Hub-Signature": "sha256=1234"},
+		},
+	}
+
+	for _, tt := range invalidTests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &recordingListener{}
+			h := NewHandler(testSecret, l)
+			req := makeWebhookRequest(t, tt.path, tt.payload, tt.headers)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			if len(l.pushes) != 0 {
+				t.Fatalf("listener was notified of %d pushes", len(l.pushes))
+			}
+		})
+	}
+}
+
+func TestHandlerIgnoresOtherEvents(t *testing.T) {
+	l := &recordingListener{}
+	h := NewHandler(testSecret, l)
+	req := makeWebhookRequest(t, "/webhooks/gitlab", "testdata/gitlab_push.json",
+		map[string]string{"X-Gitlab-Event": "Issue Hook", "X-Gitlab-Token": testSecret})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want %d", w.Code, http.StatusNoContent)
+	}
+	if len(l.pushes) != 0 {
+		t.Fatalf("listener was notified of %d pushes", len(l.pushes))
+	}
+}
+
+func TestPushUpdates(t *testing.T) {
+	updateTests := []struct {
+		push *Push
+		ref  string
+		want bool
+	}{
+		{&Push{Ref: "refs/heads/main"}, "main", true},
+		{&Push{Ref: "refs/heads/main"}, "refs/heads/main", true},
+		{&Push{Ref: "refs/heads/main"}, "dev", false},
+		{&Push{Ref: "refs/heads/main"}, "HEAD", true},
+		{&Push{Ref: "refs/heads/main", DefaultBranch: "main"}, "HEAD", true},
+		{&Push{Ref: "refs/heads/dev", DefaultBranch: "main"}, "HEAD", false},
+		{&Push{Ref: "refs/tags/v1"}, "v1", true},
+		{&Push{Ref: "refs/tags/v1"}, "HEAD", false},
+	}
+
+	for _, tt := range updateTests {
+		if got := tt.push.Updates(tt.ref); got != tt.want {
+			t.Errorf("Updates(%q) for a push to %s got %v, want %v", tt.ref, tt.push.Ref, got, tt.want)
+		}
+	}
+}
+
+func TestPushChanged(t *testing.T) {
+	p := &Push{Files: []string{"environments/dev/apps/taxi/kustomization.yaml"}}
+
+	if !p.Changed("environments/dev") {
+		t.Fatal("Changed() returned false for a changed directory")
+	}
+	if p.Changed("environments/de") {
+		t.Fatal("Changed() returned true for a prefix of a changed directory")
+	}
+	if !(&Push{}).Changed("environments/stage") {
+		t.Fatal("Changed() returned false when the files aren't known")
+	}
+}
+
+type recordingListener struct {
+	pushes []*Push
+}
+
+func (l *recordingListener) Pushed(p *Push) {
+	l.pushes = append(l.pushes, p)
+}
+
+// makeWebhookRequest creates a request with the payload signed with the test
+// secret, unless the headers have a signature.
+func makeWebhookRequest(t *testing.T, path, payload string, headers map[string]string) *http.Request {
+	t.Helper()
+	body, err := os.ReadFile(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(body)
+	req.Header.Set("X-Hub-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req
+}